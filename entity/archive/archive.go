@@ -0,0 +1,443 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const defaultQueryLimit = 100
+
+const schema = `
+CREATE TABLE IF NOT EXISTS messages (
+	id           TEXT    NOT NULL,
+	group_topic  TEXT    NOT NULL,
+	sender       TEXT    NOT NULL,
+	sent_at      INTEGER NOT NULL,
+	content_type TEXT    NOT NULL,
+	text         TEXT    NOT NULL DEFAULT '',
+	media_ref    TEXT    NOT NULL DEFAULT '',
+	mime_type    TEXT    NOT NULL DEFAULT '',
+	file_name    TEXT    NOT NULL DEFAULT '',
+	media_size   INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (group_topic, id)
+);
+CREATE INDEX IF NOT EXISTS messages_group_time ON messages (group_topic, sent_at);
+CREATE INDEX IF NOT EXISTS messages_sender_time ON messages (sender, sent_at);
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts4 (body);
+
+CREATE TABLE IF NOT EXISTS summaries (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	group_topic   TEXT    NOT NULL,
+	created_at    INTEGER NOT NULL,
+	first_msg_at  INTEGER NOT NULL DEFAULT 0,
+	last_msg_at   INTEGER NOT NULL DEFAULT 0,
+	message_count INTEGER NOT NULL DEFAULT 0,
+	text          TEXT    NOT NULL,
+	prompt_hash   TEXT    NOT NULL DEFAULT '',
+	model         TEXT    NOT NULL DEFAULT '',
+	scope         TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS summaries_group_time ON summaries (group_topic, created_at);
+CREATE VIRTUAL TABLE IF NOT EXISTS summaries_fts USING fts4 (body);
+`
+
+// Store is a SQLite-backed archive of every monitored message and every
+// generated summary. Unlike the message buffer it is never cleared.
+type Store struct {
+	db       *sql.DB
+	mediaDir string
+	log      *zap.Logger
+}
+
+type MessageRecord struct {
+	ID          string
+	GroupTopic  string
+	Sender      string
+	Timestamp   time.Time
+	ContentType chat.ContentType
+	Text        string
+	MediaRef    string
+	MimeType    string
+	FileName    string
+	MediaSize   int64
+}
+
+type SummaryRecord struct {
+	ID           int64
+	GroupTopic   string
+	CreatedAt    time.Time
+	FirstMsgTime time.Time
+	LastMsgTime  time.Time
+	MessageCount int
+	Text         string
+	PromptHash   string
+	Model        string
+	// Scope describes the window of a summary covering only part of the
+	// buffer, e.g. "最近2小时 · @张三"; empty for a full summary.
+	Scope string
+}
+
+// Query filters archive lookups. Zero-valued fields are ignored. Results are
+// returned newest first.
+type Query struct {
+	GroupTopic string
+	Sender     string
+	Since      time.Time
+	Until      time.Time
+	Text       string
+	Limit      int
+	// FullOnly skips summaries with a Scope. Messages ignore it.
+	FullOnly bool
+}
+
+// Open opens (or creates) the archive database at path. When mediaDir is not
+// empty, media payloads are written there keyed by their content hash. Open
+// fails in builds without cgo.
+func Open(path, mediaDir string) (*Store, error) {
+	if errNoDriver != nil {
+		return nil, errNoDriver
+	}
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize archive schema: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if mediaDir != "" {
+		if err := os.MkdirAll(mediaDir, 0755); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create archive media directory: %w", err)
+		}
+	}
+
+	log := logging.Named("archive")
+	log.Info("Archive opened", zap.String("path", path), zap.String("mediaDir", mediaDir))
+
+	return &Store{
+		db:       db,
+		mediaDir: mediaDir,
+		log:      log,
+	}, nil
+}
+
+// migrate adds the columns introduced after the first release to archives
+// created before them.
+func migrate(db *sql.DB) error {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('summaries') WHERE name = 'scope'`).Scan(&n); err != nil {
+		return fmt.Errorf("failed to inspect archive schema: %w", err)
+	}
+	if n == 0 {
+		if _, err := db.Exec(`ALTER TABLE summaries ADD COLUMN scope TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("failed to migrate archive schema: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// RecordMessage archives msg. Messages already archived are ignored.
+func (s *Store) RecordMessage(msg chat.Message) error {
+	rec := MessageRecord{
+		ID:          msg.ID,
+		GroupTopic:  msg.GroupTopic,
+		Sender:      msg.Sender,
+		Timestamp:   msg.Timestamp,
+		ContentType: chat.ContentTypeText,
+	}
+
+	if c := msg.Content; c != nil {
+		rec.ContentType = c.Type
+		rec.Text = c.Text
+		if rec.Text == "" {
+			rec.Text = c.Transcript
+		}
+		if rec.Text == "" && c.Caption != "" {
+			rec.Text = strings.TrimSpace(c.Caption + "\n" + c.ImageText)
+		}
+		rec.MimeType = c.MimeType
+		rec.FileName = c.FileName
+		if len(c.Data) > 0 {
+			ref, err := s.storeMedia(c.Data)
+			if err != nil {
+				return err
+			}
+			rec.MediaRef = ref
+			rec.MediaSize = int64(len(c.Data))
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin archive transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT OR IGNORE INTO messages
+		(id, group_topic, sender, sent_at, content_type, text, media_ref, mime_type, file_name, media_size)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.ID, rec.GroupTopic, rec.Sender, rec.Timestamp.UnixMilli(), string(rec.ContentType),
+		rec.Text, rec.MediaRef, rec.MimeType, rec.FileName, rec.MediaSize)
+	if err != nil {
+		return fmt.Errorf("failed to archive message: %w", err)
+	}
+
+	if inserted, _ := res.RowsAffected(); inserted == 0 {
+		return nil
+	}
+
+	rowID, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to read archived message id: %w", err)
+	}
+
+	body := segment(strings.Join([]string{rec.Sender, rec.Text, rec.FileName}, " "))
+	if _, err := tx.Exec(`INSERT INTO messages_fts (docid, body) VALUES (?, ?)`, rowID, body); err != nil {
+		return fmt.Errorf("failed to index archived message: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RecordSummary archives a generated summary and returns its ID.
+func (s *Store) RecordSummary(rec SummaryRecord) (int64, error) {
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin archive transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO summaries
+		(group_topic, created_at, first_msg_at, last_msg_at, message_count, text, prompt_hash, model, scope)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.GroupTopic, rec.CreatedAt.UnixMilli(), unixMilli(rec.FirstMsgTime), unixMilli(rec.LastMsgTime),
+		rec.MessageCount, rec.Text, rec.PromptHash, rec.Model, rec.Scope)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive summary: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read archived summary id: %w", err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO summaries_fts (docid, body) VALUES (?, ?)`, id, segment(rec.Text)); err != nil {
+		return 0, fmt.Errorf("failed to index archived summary: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit archived summary: %w", err)
+	}
+	return id, nil
+}
+
+// Messages returns archived messages matching q, newest first.
+func (s *Store) Messages(q Query) ([]MessageRecord, error) {
+	where, args := q.filters("m.group_topic", "m.sent_at", "m.sender")
+	if q.Text != "" {
+		where = append(where, "m.rowid IN (SELECT docid FROM messages_fts WHERE body MATCH ?)")
+		args = append(args, matchPhrase(q.Text))
+	}
+
+	rows, err := s.db.Query(`SELECT m.id, m.group_topic, m.sender, m.sent_at, m.content_type,
+		m.text, m.media_ref, m.mime_type, m.file_name, m.media_size
+		FROM messages m`+whereClause(where)+` ORDER BY m.sent_at DESC LIMIT ?`,
+		append(args, q.limit())...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var records []MessageRecord
+	for rows.Next() {
+		var rec MessageRecord
+		var sentAt int64
+		var contentType string
+		if err := rows.Scan(&rec.ID, &rec.GroupTopic, &rec.Sender, &sentAt, &contentType,
+			&rec.Text, &rec.MediaRef, &rec.MimeType, &rec.FileName, &rec.MediaSize); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		rec.Timestamp = time.UnixMilli(sentAt)
+		rec.ContentType = chat.ContentType(contentType)
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// Summaries returns archived summaries matching q, newest first. Sender is
+// ignored since summaries have no single author.
+func (s *Store) Summaries(q Query) ([]SummaryRecord, error) {
+	q.Sender = ""
+	where, args := q.filters("s.group_topic", "s.created_at", "")
+	if q.Text != "" {
+		where = append(where, "s.id IN (SELECT docid FROM summaries_fts WHERE body MATCH ?)")
+		args = append(args, matchPhrase(q.Text))
+	}
+	if q.FullOnly {
+		where = append(where, "s.scope = ''")
+	}
+
+	rows, err := s.db.Query(`SELECT s.id, s.group_topic, s.created_at, s.first_msg_at, s.last_msg_at,
+		s.message_count, s.text, s.prompt_hash, s.model, s.scope
+		FROM summaries s`+whereClause(where)+` ORDER BY s.created_at DESC LIMIT ?`,
+		append(args, q.limit())...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var records []SummaryRecord
+	for rows.Next() {
+		var rec SummaryRecord
+		var createdAt, firstAt, lastAt int64
+		if err := rows.Scan(&rec.ID, &rec.GroupTopic, &createdAt, &firstAt, &lastAt,
+			&rec.MessageCount, &rec.Text, &rec.PromptHash, &rec.Model, &rec.Scope); err != nil {
+			return nil, fmt.Errorf("failed to scan summary: %w", err)
+		}
+		rec.CreatedAt = time.UnixMilli(createdAt)
+		rec.FirstMsgTime = fromUnixMilli(firstAt)
+		rec.LastMsgTime = fromUnixMilli(lastAt)
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// MediaPath returns the on-disk location of an archived media reference, if
+// media payloads are being kept.
+func (s *Store) MediaPath(ref string) (string, error) {
+	if s.mediaDir == "" {
+		return "", errors.New("archive media directory not configured")
+	}
+	sum, ok := strings.CutPrefix(ref, "sha256:")
+	if !ok || sum == "" {
+		return "", fmt.Errorf("invalid media reference %q", ref)
+	}
+	return filepath.Join(s.mediaDir, sum), nil
+}
+
+func (s *Store) storeMedia(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	ref := "sha256:" + hex.EncodeToString(sum[:])
+	if s.mediaDir == "" {
+		return ref, nil
+	}
+
+	path, _ := s.MediaPath(ref)
+	if _, err := os.Stat(path); err == nil {
+		return ref, nil
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write archived media: %w", err)
+	}
+	s.log.Debug("Archived media", zap.String("ref", ref), zap.Int("size", len(data)))
+	return ref, nil
+}
+
+func (q Query) filters(groupCol, timeCol, senderCol string) ([]string, []any) {
+	var where []string
+	var args []any
+	if q.GroupTopic != "" {
+		where = append(where, groupCol+" = ?")
+		args = append(args, q.GroupTopic)
+	}
+	if q.Sender != "" && senderCol != "" {
+		where = append(where, senderCol+" = ?")
+		args = append(args, q.Sender)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, timeCol+" >= ?")
+		args = append(args, q.Since.UnixMilli())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, timeCol+" < ?")
+		args = append(args, q.Until.UnixMilli())
+	}
+	return where, args
+}
+
+func (q Query) limit() int {
+	if q.Limit <= 0 {
+		return defaultQueryLimit
+	}
+	return q.Limit
+}
+
+func whereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(where, " AND ")
+}
+
+// segment splits CJK text into one token per character. FTS4's tokenizer
+// only breaks on whitespace and punctuation, so without this a Chinese
+// sentence would be indexed as a single unsearchable token.
+func segment(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if isCJK(r) {
+			sb.WriteRune(' ')
+			sb.WriteRune(r)
+			sb.WriteRune(' ')
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// matchPhrase turns a free-text query into an FTS phrase, so a Chinese
+// query matches its characters consecutively.
+func matchPhrase(text string) string {
+	text = strings.ReplaceAll(text, `"`, " ")
+	return `"` + strings.Join(strings.Fields(segment(text)), " ") + `"`
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,209 @@
+//go:build cgo
+
+package archive
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+)
+
+func openTestStore(t *testing.T, mediaDir string) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "archive.db"), mediaDir)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRecordAndQueryMessages(t *testing.T) {
+	store := openTestStore(t, "")
+	base := time.Date(2025, 3, 10, 9, 0, 0, 0, time.Local)
+
+	messages := []chat.Message{
+		{ID: "1", Timestamp: base, Sender: "张三", GroupTopic: "产品群", Content: &chat.Content{Type: chat.ContentTypeText, Text: "我们决定下周一发布新版本"}},
+		{ID: "2", Timestamp: base.Add(time.Hour), Sender: "李四", GroupTopic: "产品群", Content: &chat.Content{Type: chat.ContentTypeText, Text: "收到"}},
+		{ID: "3", Timestamp: base.Add(2 * time.Hour), Sender: "张三", GroupTopic: "研发群", Content: &chat.Content{Type: chat.ContentTypeText, Text: "release the build on Monday"}},
+		{ID: "4", Timestamp: base.Add(3 * time.Hour), Sender: "李四", GroupTopic: "产品群", Content: &chat.Content{Type: chat.ContentTypeImage, Data: []byte{1, 2, 3}, MimeType: "image/png"}},
+	}
+	for _, msg := range messages {
+		if err := store.RecordMessage(msg); err != nil {
+			t.Fatalf("RecordMessage(%s) failed: %v", msg.ID, err)
+		}
+	}
+
+	// Re-recording the same message must not duplicate it
+	if err := store.RecordMessage(messages[0]); err != nil {
+		t.Fatalf("RecordMessage() duplicate failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		query   Query
+		wantIDs []string
+	}{
+		{"by group", Query{GroupTopic: "产品群"}, []string{"4", "2", "1"}},
+		{"by sender", Query{Sender: "张三"}, []string{"3", "1"}},
+		{"by time range", Query{Since: base.Add(30 * time.Minute), Until: base.Add(150 * time.Minute)}, []string{"3", "2"}},
+		{"chinese full text", Query{Text: "发布"}, []string{"1"}},
+		{"english full text", Query{Text: "monday"}, []string{"3"}},
+		{"full text is a phrase", Query{Text: "布发"}, nil},
+		{"combined", Query{GroupTopic: "产品群", Sender: "张三", Text: "决定"}, []string{"1"}},
+		{"limit", Query{GroupTopic: "产品群", Limit: 1}, []string{"4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Messages(tt.query)
+			if err != nil {
+				t.Fatalf("Messages() failed: %v", err)
+			}
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("Messages() returned %d records, want %d", len(got), len(tt.wantIDs))
+			}
+			for i, rec := range got {
+				if rec.ID != tt.wantIDs[i] {
+					t.Errorf("record[%d].ID = %q, want %q", i, rec.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMediaReference(t *testing.T) {
+	mediaDir := t.TempDir()
+	store := openTestStore(t, mediaDir)
+
+	err := store.RecordMessage(chat.Message{
+		ID:         "img",
+		Timestamp:  time.Now(),
+		Sender:     "Alice",
+		GroupTopic: "Group",
+		Content:    &chat.Content{Type: chat.ContentTypePDF, Data: []byte("%PDF-1.4"), MimeType: "application/pdf", FileName: "spec.pdf"},
+	})
+	if err != nil {
+		t.Fatalf("RecordMessage() failed: %v", err)
+	}
+
+	got, err := store.Messages(Query{Text: "spec"})
+	if err != nil || len(got) != 1 {
+		t.Fatalf("Messages() = %d records, err %v", len(got), err)
+	}
+
+	rec := got[0]
+	if rec.ContentType != chat.ContentTypePDF || rec.MediaSize != 8 || rec.FileName != "spec.pdf" {
+		t.Errorf("Unexpected media record: %+v", rec)
+	}
+
+	path, err := store.MediaPath(rec.MediaRef)
+	if err != nil {
+		t.Fatalf("MediaPath() failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "%PDF-1.4" {
+		t.Errorf("Archived media = %q, err %v", data, err)
+	}
+}
+
+func TestRecordAndQuerySummaries(t *testing.T) {
+	store := openTestStore(t, "")
+	base := time.Date(2025, 3, 10, 18, 0, 0, 0, time.Local)
+
+	records := []SummaryRecord{
+		{GroupTopic: "产品群", CreatedAt: base, MessageCount: 12, Text: "决定：周一发布", PromptHash: "abc", Model: "gemini-2.5-flash"},
+		{GroupTopic: "研发群", CreatedAt: base.Add(time.Hour), MessageCount: 30, Text: "待办：修复登录问题", PromptHash: "abc", Model: "gpt-4o"},
+	}
+	for _, rec := range records {
+		if _, err := store.RecordSummary(rec); err != nil {
+			t.Fatalf("RecordSummary() failed: %v", err)
+		}
+	}
+
+	got, err := store.Summaries(Query{Text: "登录"})
+	if err != nil {
+		t.Fatalf("Summaries() failed: %v", err)
+	}
+	if len(got) != 1 || got[0].GroupTopic != "研发群" || got[0].Model != "gpt-4o" {
+		t.Fatalf("Unexpected full-text result: %+v", got)
+	}
+
+	got, err = store.Summaries(Query{Since: base.Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("Summaries() failed: %v", err)
+	}
+	if len(got) != 2 || got[0].GroupTopic != "研发群" {
+		t.Fatalf("Expected 2 summaries newest first, got %+v", got)
+	}
+	if !got[1].CreatedAt.Equal(base) || got[1].MessageCount != 12 || got[1].PromptHash != "abc" {
+		t.Errorf("Summary fields not round-tripped: %+v", got[1])
+	}
+}
+
+func TestFullOnlySummaries(t *testing.T) {
+	store := openTestStore(t, "")
+	base := time.Date(2025, 3, 10, 18, 0, 0, 0, time.Local)
+
+	records := []SummaryRecord{
+		{GroupTopic: "产品群", CreatedAt: base, Text: "全量纪要"},
+		{GroupTopic: "产品群", CreatedAt: base.Add(time.Hour), Text: "张三的发言", Scope: "@张三"},
+	}
+	for _, rec := range records {
+		if _, err := store.RecordSummary(rec); err != nil {
+			t.Fatalf("RecordSummary() failed: %v", err)
+		}
+	}
+
+	got, err := store.Summaries(Query{GroupTopic: "产品群", Limit: 1})
+	if err != nil {
+		t.Fatalf("Summaries() failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Scope != "@张三" {
+		t.Fatalf("Expected the windowed summary first, got %+v", got)
+	}
+
+	got, err = store.Summaries(Query{GroupTopic: "产品群", FullOnly: true, Limit: 1})
+	if err != nil {
+		t.Fatalf("Summaries() failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Text != "全量纪要" {
+		t.Fatalf("Expected the full summary, got %+v", got)
+	}
+}
+
+func TestMigrateSummaryScope(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("sql.Open() failed: %v", err)
+	}
+	// The summaries table as created before scopes were archived
+	if _, err := db.Exec(`CREATE TABLE summaries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, group_topic TEXT NOT NULL, created_at INTEGER NOT NULL,
+		first_msg_at INTEGER NOT NULL DEFAULT 0, last_msg_at INTEGER NOT NULL DEFAULT 0,
+		message_count INTEGER NOT NULL DEFAULT 0, text TEXT NOT NULL,
+		prompt_hash TEXT NOT NULL DEFAULT '', model TEXT NOT NULL DEFAULT '');
+		INSERT INTO summaries (group_topic, created_at, text) VALUES ('产品群', 1, '旧纪要')`); err != nil {
+		t.Fatalf("Failed to create old schema: %v", err)
+	}
+	db.Close()
+
+	store, err := Open(path, "")
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer store.Close()
+
+	got, err := store.Summaries(Query{FullOnly: true})
+	if err != nil {
+		t.Fatalf("Summaries() failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Text != "旧纪要" {
+		t.Fatalf("Expected the old summary to count as full, got %+v", got)
+	}
+}
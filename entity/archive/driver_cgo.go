@@ -0,0 +1,8 @@
+//go:build cgo
+
+package archive
+
+import _ "github.com/mattn/go-sqlite3"
+
+// errNoDriver is nil when the SQLite driver is linked in.
+var errNoDriver error
@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package archive
+
+import "errors"
+
+// errNoDriver is returned by Open in builds without cgo, which the SQLite
+// driver needs.
+var errNoDriver = errors.New("archive requires a cgo build (CGO_ENABLED=1 and a C compiler)")
@@ -1,6 +1,7 @@
 package chat
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -17,11 +18,18 @@ type groupData struct {
 	count           int
 	capacity        int
 	lastSummaryTime time.Time
-	messageIDs      map[string]struct{}
+	lastMessageTime time.Time
+	idleTimer       *time.Timer
+	messageIDs      map[string]bool // true while the media is pending, see AddPending
 }
 
 type MessageBuffer struct {
 	groups *haxmap.Map[string, *groupData]
+	onIdle func(groupTopic string)
+	wal    *wal
+	// walMu is held for reading by every mutation that writes a WAL record
+	// and exclusively by compaction, so a snapshot never misses a record.
+	walMu sync.RWMutex
 }
 
 func New() *MessageBuffer {
@@ -30,19 +38,202 @@ func New() *MessageBuffer {
 	}
 }
 
+// Open returns a buffer backed by the write-ahead log at path, restoring any
+// pending messages and summary times recorded before the last shutdown.
+func Open(path string) (*MessageBuffer, error) {
+	b := New()
+	w := newWAL(path, config.GetConfig().BufferWALCompactThreshold)
+
+	restored, err := w.replay(b.apply)
+	if err != nil {
+		logging.Warn("Buffer WAL replay stopped early",
+			zap.String("path", path),
+			zap.Int("records", restored),
+			zap.Error(err))
+	}
+
+	b.wal = w
+	if err := b.compact(); err != nil {
+		return nil, err
+	}
+
+	messages := 0
+	groups := 0
+	b.groups.ForEach(func(_ string, group *groupData) bool {
+		messages += group.count
+		groups++
+		return true
+	})
+	logging.Info("Message buffer restored from WAL",
+		zap.String("path", path),
+		zap.Int("groups", groups),
+		zap.Int("messages", messages))
+
+	return b, nil
+}
+
+// OnIdle registers a callback for groups that stop receiving messages for
+// SUMMARY_IDLE_MINUTES. It must be set before messages are added. The callback
+// runs on its own goroutine and should confirm with ShouldSummarize.
+func (b *MessageBuffer) OnIdle(fn func(groupTopic string)) {
+	b.onIdle = fn
+}
+
+// Close stops idle timers and flushes and closes the WAL, if any. The buffer
+// stays usable in memory.
+func (b *MessageBuffer) Close() error {
+	b.groups.ForEach(func(_ string, group *groupData) bool {
+		group.mu.Lock()
+		if group.idleTimer != nil {
+			group.idleTimer.Stop()
+		}
+		group.mu.Unlock()
+		return true
+	})
+
+	if b.wal == nil {
+		return nil
+	}
+	b.walMu.Lock()
+	defer b.walMu.Unlock()
+	return b.wal.close()
+}
+
+func (b *MessageBuffer) apply(rec walRecord) {
+	switch rec.Op {
+	case walOpAdd:
+		if rec.Message != nil {
+			b.add(*rec.Message, false)
+		}
+	case walOpPatch:
+		if rec.Message != nil {
+			b.patch(rec.Group, rec.Message.ID, rec.Message.Content)
+		}
+	case walOpClear:
+		group := b.getOrCreateGroup(rec.Group)
+		group.mu.Lock()
+		group.reset(rec.Time)
+		group.mu.Unlock()
+	}
+}
+
+func (b *MessageBuffer) persist(rec walRecord) {
+	if b.wal == nil {
+		return
+	}
+	if err := b.wal.append(rec); err != nil {
+		logging.Error("Failed to write buffer WAL",
+			zap.String("group", rec.Group),
+			zap.String("op", string(rec.Op)),
+			zap.Error(err))
+	}
+}
+
+func (b *MessageBuffer) maybeCompact() {
+	if b.wal == nil || !b.wal.needsCompaction() {
+		return
+	}
+	if err := b.compact(); err != nil {
+		logging.Error("Failed to compact buffer WAL", zap.Error(err))
+	}
+}
+
+// compact rewrites the WAL as the minimal set of records that reproduces the
+// current buffer contents.
+func (b *MessageBuffer) compact() error {
+	b.walMu.Lock()
+	defer b.walMu.Unlock()
+
+	var records []walRecord
+	b.groups.ForEach(func(topic string, group *groupData) bool {
+		group.mu.RLock()
+		defer group.mu.RUnlock()
+
+		records = append(records, walRecord{Op: walOpClear, Group: topic, Time: group.lastSummaryTime})
+		for _, msg := range group.ordered() {
+			records = append(records, walRecord{Op: walOpAdd, Group: topic, Message: walMessage(msg, group.messageIDs[msg.ID])})
+		}
+		return true
+	})
+
+	if err := b.wal.rewrite(records); err != nil {
+		return err
+	}
+	logging.Debug("Buffer WAL compacted", zap.Int("records", len(records)))
+	return nil
+}
+
+// walMessage returns msg as the WAL records it: without its media bytes
+// while they are pending.
+func walMessage(msg Message, pending bool) *Message {
+	if pending && msg.Content != nil && msg.Content.Data != nil {
+		c := *msg.Content
+		c.Data = nil
+		msg.Content = &c
+	}
+	return &msg
+}
+
 func (b *MessageBuffer) getOrCreateGroup(groupTopic string) *groupData {
 	group, _ := b.groups.GetOrCompute(groupTopic, func() *groupData {
-		cap := config.GetConfig().MaxBufferSize
+		cap := config.ForGroup(groupTopic).MaxBufferSize
 		return &groupData{
 			messages:   make([]Message, cap),
 			capacity:   cap,
-			messageIDs: make(map[string]struct{}),
+			messageIDs: make(map[string]bool),
 		}
 	})
 	return group
 }
 
 func (b *MessageBuffer) Add(msg Message) {
+	b.walMu.RLock()
+	added := b.add(msg, false)
+	b.walMu.RUnlock()
+
+	if added {
+		b.resetIdleTimer(msg.GroupTopic)
+		b.maybeCompact()
+	}
+}
+
+// AddPending adds a message whose media is still being processed, e.g.
+// transcribed. Until Patch supplies the result, the WAL holds the message
+// without its media bytes, so a crash in between restores it as its
+// placeholder.
+func (b *MessageBuffer) AddPending(msg Message) {
+	b.walMu.RLock()
+	added := b.add(msg, true)
+	b.walMu.RUnlock()
+
+	if added {
+		b.resetIdleTimer(msg.GroupTopic)
+		b.maybeCompact()
+	}
+}
+
+// resetIdleTimer restarts the quiet-period countdown of a group.
+func (b *MessageBuffer) resetIdleTimer(groupTopic string) {
+	idle := config.ForGroup(groupTopic).SummaryTrigger.IdleMinutes
+	if b.onIdle == nil || idle <= 0 {
+		return
+	}
+
+	group := b.getOrCreateGroup(groupTopic)
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	d := time.Duration(idle) * time.Minute
+	if group.idleTimer != nil {
+		group.idleTimer.Reset(d)
+		return
+	}
+	group.idleTimer = time.AfterFunc(d, func() {
+		b.onIdle(groupTopic)
+	})
+}
+
+func (b *MessageBuffer) add(msg Message, pending bool) bool {
 	group := b.getOrCreateGroup(msg.GroupTopic)
 	group.mu.Lock()
 	defer group.mu.Unlock()
@@ -51,7 +242,11 @@ func (b *MessageBuffer) Add(msg Message) {
 		logging.Debug("Duplicate message ID detected, skipping",
 			zap.String("id", msg.ID),
 			zap.String("group", msg.GroupTopic))
-		return
+		return false
+	}
+	// Follow MAX_BUFFER_SIZE and groups.json changes made since the ring was created
+	if capacity := config.ForGroup(msg.GroupTopic).MaxBufferSize; capacity != group.capacity {
+		group.resize(capacity)
 	}
 
 	firstMsg := group.writeIndex
@@ -61,16 +256,71 @@ func (b *MessageBuffer) Add(msg Message) {
 	}
 
 	group.messages[group.writeIndex] = msg
-	group.messageIDs[msg.ID] = struct{}{}
+	group.messageIDs[msg.ID] = pending
 	group.writeIndex = (group.writeIndex + 1) % group.capacity
 
 	if group.count < group.capacity {
 		group.count++
 	}
+	if msg.Timestamp.After(group.lastMessageTime) {
+		group.lastMessageTime = msg.Timestamp
+	}
+
+	b.persist(walRecord{Op: walOpAdd, Group: msg.GroupTopic, Message: walMessage(msg, pending)})
 
 	logging.Debug("Message added to buffer",
 		zap.String("group", msg.GroupTopic),
 		zap.Int("count", group.count))
+	return true
+}
+
+// Patch replaces the content of a buffered message, e.g. once its
+// transcript arrives, and writes c to the WAL in full, ending the pending
+// state of AddPending. Messages no longer buffered are left alone.
+func (b *MessageBuffer) Patch(groupTopic, id string, c *Content) {
+	b.walMu.RLock()
+	defer b.walMu.RUnlock()
+	b.patch(groupTopic, id, c)
+}
+
+func (b *MessageBuffer) patch(groupTopic, id string, c *Content) {
+	group, ok := b.groups.Get(groupTopic)
+	if !ok {
+		return
+	}
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	if _, ok := group.messageIDs[id]; !ok {
+		return
+	}
+	startIndex := 0
+	if group.count == group.capacity {
+		startIndex = group.writeIndex
+	}
+	for i := 0; i < group.count; i++ {
+		msg := &group.messages[(startIndex+i)%group.capacity]
+		if msg.ID != id {
+			continue
+		}
+		msg.Content = c
+		group.messageIDs[id] = false
+		patched := *msg
+		b.persist(walRecord{Op: walOpPatch, Group: groupTopic, Message: &patched})
+		return
+	}
+}
+
+// Count returns the number of messages buffered for a group.
+func (b *MessageBuffer) Count(groupTopic string) int {
+	group, ok := b.groups.Get(groupTopic)
+	if !ok {
+		return 0
+	}
+
+	group.mu.RLock()
+	defer group.mu.RUnlock()
+	return group.count
 }
 
 func (b *MessageBuffer) GetGroupTopics() []string {
@@ -88,16 +338,55 @@ func (b *MessageBuffer) Clear(groupTopic string) {
 		return
 	}
 
+	b.walMu.RLock()
+	defer b.walMu.RUnlock()
+
 	group.mu.Lock()
 	defer group.mu.Unlock()
 
 	logging.Info("Buffered messages cleared",
 		zap.Int("count", group.count),
 		zap.String("group", groupTopic))
-	group.writeIndex = 0
-	group.count = 0
-	group.messageIDs = make(map[string]struct{})
-	group.lastSummaryTime = time.Now()
+	now := time.Now()
+	group.reset(now)
+	b.persist(walRecord{Op: walOpClear, Group: groupTopic, Time: now})
+}
+
+func (g *groupData) reset(lastSummaryTime time.Time) {
+	g.writeIndex = 0
+	g.count = 0
+	g.messageIDs = make(map[string]bool)
+	g.lastSummaryTime = lastSummaryTime
+}
+
+// resize changes the capacity of the ring, keeping the newest messages that
+// still fit.
+func (g *groupData) resize(capacity int) {
+	messages := g.ordered()
+	if drop := len(messages) - capacity; drop > 0 {
+		for _, msg := range messages[:drop] {
+			delete(g.messageIDs, msg.ID)
+		}
+		messages = messages[drop:]
+	}
+	g.messages = make([]Message, capacity)
+	copy(g.messages, messages)
+	g.count = len(messages)
+	g.writeIndex = g.count % capacity
+	g.capacity = capacity
+}
+
+// ordered returns the buffered messages from oldest to newest.
+func (g *groupData) ordered() []Message {
+	messages := make([]Message, 0, g.count)
+	startIndex := 0
+	if g.count == g.capacity {
+		startIndex = g.writeIndex
+	}
+	for i := 0; i < g.count; i++ {
+		messages = append(messages, g.messages[(startIndex+i)%g.capacity])
+	}
+	return messages
 }
 
 func (b *MessageBuffer) ShouldSummarize(groupTopic string, triggeredByKeyword bool) bool {
@@ -109,7 +398,7 @@ func (b *MessageBuffer) ShouldSummarize(groupTopic string, triggeredByKeyword bo
 	group.mu.RLock()
 	defer group.mu.RUnlock()
 
-	cfg := config.GetConfig()
+	cfg := config.ForGroup(groupTopic)
 
 	if group.count < cfg.SummaryTrigger.MinMessagesForSummary {
 		logging.Debug("Not enough messages for summary",
@@ -133,6 +422,17 @@ func (b *MessageBuffer) ShouldSummarize(groupTopic string, triggeredByKeyword bo
 		return true
 	}
 
+	if cfg.SummaryTrigger.IdleMinutes > 0 && !group.lastMessageTime.IsZero() {
+		quietMinutes := time.Since(group.lastMessageTime).Minutes()
+		if quietMinutes >= float64(cfg.SummaryTrigger.IdleMinutes) {
+			logging.Info("Summary triggered by idle period",
+				zap.String("group", groupTopic),
+				zap.Float64("quietMinutes", quietMinutes),
+				zap.Int("idle", cfg.SummaryTrigger.IdleMinutes))
+			return true
+		}
+	}
+
 	if cfg.SummaryTrigger.IntervalMinutes > 0 {
 		if !group.lastSummaryTime.IsZero() {
 			minutesSinceLast := time.Since(group.lastSummaryTime).Minutes()
@@ -157,7 +457,57 @@ type Snapshot struct {
 	Contents     []*Content
 }
 
+// Window narrows a snapshot to part of the buffer. Zero fields select
+// everything.
+type Window struct {
+	Since  time.Time // only messages at or after Since
+	Sender string    // only messages from this sender, case-insensitive
+	Last   int       // only the last N messages remaining after the other filters
+}
+
+func (w Window) IsZero() bool {
+	return w.Since.IsZero() && w.Sender == "" && w.Last <= 0
+}
+
+func (w Window) apply(messages []Message) []Message {
+	if w.IsZero() {
+		return messages
+	}
+
+	selected := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		if !w.Since.IsZero() && msg.Timestamp.Before(w.Since) {
+			continue
+		}
+		if w.Sender != "" && !strings.EqualFold(msg.Sender, w.Sender) {
+			continue
+		}
+		selected = append(selected, msg)
+	}
+	if w.Last > 0 && len(selected) > w.Last {
+		selected = selected[len(selected)-w.Last:]
+	}
+	return selected
+}
+
+// GetMessages returns the buffered messages selected by w, oldest first.
+func (b *MessageBuffer) GetMessages(groupTopic string, w Window) []Message {
+	group, ok := b.groups.Get(groupTopic)
+	if !ok {
+		return nil
+	}
+
+	group.mu.RLock()
+	defer group.mu.RUnlock()
+	return w.apply(group.ordered())
+}
+
 func (b *MessageBuffer) GetSnapshot(groupTopic string) Snapshot {
+	return b.GetWindowSnapshot(groupTopic, Window{})
+}
+
+// GetWindowSnapshot returns a snapshot of the buffered messages selected by w.
+func (b *MessageBuffer) GetWindowSnapshot(groupTopic string, w Window) Snapshot {
 	group, ok := b.groups.Get(groupTopic)
 	if !ok {
 		return Snapshot{Participants: make(map[string]struct{})}
@@ -166,30 +516,24 @@ func (b *MessageBuffer) GetSnapshot(groupTopic string) Snapshot {
 	group.mu.RLock()
 	defer group.mu.RUnlock()
 
+	messages := w.apply(group.ordered())
 	snapshot := Snapshot{
-		Count:        group.count,
+		Count:        len(messages),
 		Participants: make(map[string]struct{}),
 	}
 
-	if group.count == 0 {
+	if len(messages) == 0 {
 		return snapshot
 	}
 
-	startIndex := 0
-	if group.count == group.capacity {
-		startIndex = group.writeIndex
-	}
-
-	firstMsg := group.messages[startIndex]
-	lastMsg := group.messages[(startIndex+group.count-1)%group.capacity]
+	firstMsg := messages[0]
+	lastMsg := messages[len(messages)-1]
 
 	snapshot.FirstMsgTime = &firstMsg.Timestamp
 	snapshot.LastMsgTime = &lastMsg.Timestamp
-	snapshot.Contents = make([]*Content, 0, group.count*2)
+	snapshot.Contents = make([]*Content, 0, len(messages)*2)
 
-	for i := 0; i < group.count; i++ {
-		msgIndex := (startIndex + i) % group.capacity
-		msg := group.messages[msgIndex]
+	for _, msg := range messages {
 		snapshot.Participants[msg.Sender] = struct{}{}
 
 		snapshot.Contents = append(snapshot.Contents, msg.ToContentParts()...)
@@ -191,3 +191,140 @@ func TestShouldSummarize(t *testing.T) {
 		t.Error("Should summarize when message count reaches limit (5)")
 	}
 }
+
+func TestShouldSummarizeWhenIdle(t *testing.T) {
+	os.Setenv("SUMMARY_IDLE_MINUTES", "10")
+	os.Setenv("MIN_MESSAGES_FOR_SUMMARY", "2")
+	defer func() {
+		os.Unsetenv("SUMMARY_IDLE_MINUTES")
+		os.Unsetenv("MIN_MESSAGES_FOR_SUMMARY")
+		_ = config.Parse()
+	}()
+	_ = config.Parse()
+
+	buf := New()
+	add := func(group, id string, ago time.Duration) {
+		buf.Add(Message{
+			ID:         id,
+			Timestamp:  time.Now().Add(-ago),
+			Sender:     "Alice",
+			GroupTopic: group,
+			Content:    &Content{Type: ContentTypeText, Text: "msg"},
+		})
+	}
+
+	add("Active", "msg1", 30*time.Minute)
+	if buf.ShouldSummarize("Active", false) {
+		t.Error("Should not summarize a quiet group below the minimum")
+	}
+
+	add("Active", "msg2", 3*time.Minute)
+	if buf.ShouldSummarize("Active", false) {
+		t.Error("Should not summarize while the discussion is still active")
+	}
+
+	add("Quiet", "msg3", 12*time.Minute)
+	add("Quiet", "msg4", 11*time.Minute)
+	if !buf.ShouldSummarize("Quiet", false) {
+		t.Error("Should summarize after 10 quiet minutes")
+	}
+}
+
+func TestWindowSnapshot(t *testing.T) {
+	buf := New()
+	group := "WindowGroup"
+	now := time.Now()
+
+	senders := []string{"Alice", "Bob", "alice", "Carol", "Bob"}
+	for i, sender := range senders {
+		buf.Add(Message{
+			ID:         fmt.Sprintf("msg%d", i),
+			Timestamp:  now.Add(time.Duration(i-len(senders)) * time.Hour),
+			Sender:     sender,
+			GroupTopic: group,
+			Content:    &Content{Type: ContentTypeText, Text: fmt.Sprintf("text%d", i)},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		window   Window
+		expected []string
+	}{
+		{"everything", Window{}, []string{"text0", "text1", "text2", "text3", "text4"}},
+		{"since", Window{Since: now.Add(-2*time.Hour - time.Minute)}, []string{"text3", "text4"}},
+		{"last", Window{Last: 2}, []string{"text3", "text4"}},
+		{"sender", Window{Sender: "ALICE"}, []string{"text0", "text2"}},
+		{"combined", Window{Sender: "bob", Last: 1}, []string{"text4"}},
+		{"no match", Window{Sender: "Dave"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot := buf.GetWindowSnapshot(group, tt.window)
+			if snapshot.Count != len(tt.expected) {
+				t.Fatalf("Count = %d, want %d", snapshot.Count, len(tt.expected))
+			}
+			var texts []string
+			for _, c := range snapshot.Contents {
+				if strings.Contains(c.Text, "text") {
+					texts = append(texts, c.Text[strings.Index(c.Text, "text"):])
+				}
+			}
+			if strings.Join(texts, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Selected %v, want %v", texts, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBufferResize(t *testing.T) {
+	os.Setenv("MAX_BUFFER_SIZE", "4")
+	defer func() {
+		os.Unsetenv("MAX_BUFFER_SIZE")
+		_ = config.Parse()
+	}()
+	_ = config.Parse()
+
+	buf := New()
+	group := "ResizeGroup"
+	add := func(i int) {
+		buf.Add(Message{
+			ID:         fmt.Sprintf("msg%d", i),
+			Timestamp:  time.Now(),
+			Sender:     "Sender",
+			GroupTopic: group,
+			Content:    &Content{Type: ContentTypeText, Text: fmt.Sprintf("Message %d", i)},
+		})
+	}
+	for i := 1; i <= 4; i++ {
+		add(i)
+	}
+
+	// Shrinking keeps the newest messages that fit, plus the new one
+	os.Setenv("MAX_BUFFER_SIZE", "2")
+	_ = config.Parse()
+	add(5)
+	snapshot := buf.GetSnapshot(group)
+	if snapshot.Count != 2 {
+		t.Fatalf("Expected count 2 after shrinking, got %d", snapshot.Count)
+	}
+	if !strings.Contains(snapshot.Contents[0].Text, "Message 4") || !strings.Contains(snapshot.Contents[1].Text, "Message 5") {
+		t.Errorf("Expected messages 4 and 5, got %q and %q", snapshot.Contents[0].Text, snapshot.Contents[1].Text)
+	}
+
+	// Dropped messages are no longer duplicates
+	add(1)
+	if got := buf.Count(group); got != 2 {
+		t.Errorf("Expected count 2, got %d", got)
+	}
+
+	// Growing keeps every message
+	os.Setenv("MAX_BUFFER_SIZE", "5")
+	_ = config.Parse()
+	add(6)
+	add(7)
+	if got := buf.Count(group); got != 4 {
+		t.Errorf("Expected count 4 after growing, got %d", got)
+	}
+}
@@ -30,12 +30,30 @@ type Content struct {
 	Data     []byte
 	MimeType string
 	FileName string
+	// Transcript is the text of a voice message, set once on arrival when
+	// transcription is enabled
+	Transcript string
+	// Caption describes an image and ImageText holds the text read from it,
+	// both set once on arrival when captioning is enabled
+	Caption   string
+	ImageText string
 }
 
 func (c *Content) IsMedia() bool {
 	return c.Type != ContentTypeText && c.Data != nil
 }
 
+// Compact returns c without the media bytes its transcript or caption
+// stands in for, or c itself when there is nothing to drop.
+func (c *Content) Compact() *Content {
+	if c.Data == nil || (c.Transcript == "" && c.Caption == "") {
+		return c
+	}
+	compact := *c
+	compact.Data = nil
+	return &compact
+}
+
 func (c *Content) Description() string {
 	switch c.Type {
 	case ContentTypeText:
@@ -80,6 +98,16 @@ func ExtractFromMessage(msg *openwechat.Message) (*Content, error) {
 		return extractMedia(msg, ContentTypeAudio, msg.GetVoice)
 	}
 
+	// A quoted reply is an appmsg, but only its reply text is content
+	if msg.MsgType == openwechat.MsgTypeApp {
+		if text, _, ok := parseQuote(msg.Content); ok {
+			return &Content{
+				Type: ContentTypeText,
+				Text: text,
+			}, nil
+		}
+	}
+
 	if msg.IsMedia() {
 		log.Debug("Extracting file/media content")
 		return extractFileContent(msg)
@@ -157,6 +185,10 @@ func extractMedia(msg *openwechat.Message, contentType ContentType, getter media
 	if mimeType == "" {
 		mimeType = detectMimeType(data, contentType)
 	}
+	// WeChat serves SILK voice under a generic type, so check the bytes
+	if contentType == ContentTypeAudio && isSilk(data) {
+		data, mimeType = convertSilk(data)
+	}
 
 	log.Debug("Media extracted",
 		zap.String("type", string(contentType)),
@@ -226,6 +258,9 @@ func detectMimeType(data []byte, contentType ContentType) string {
 		return "video/mp4"
 	}
 
+	if isSilk(data) {
+		return "audio/silk"
+	}
 	if bytes.HasPrefix(data, []byte("#!AMR")) {
 		return "audio/amr"
 	}
@@ -114,6 +114,18 @@ func TestDetectMimeType(t *testing.T) {
 			contentType: ContentTypePDF,
 			expected:    "application/pdf",
 		},
+		{
+			name:        "silk magic bytes",
+			data:        []byte("#!SILK_V3\x0a\x00..."),
+			contentType: ContentTypeAudio,
+			expected:    "audio/silk",
+		},
+		{
+			name:        "wechat silk with prefix byte",
+			data:        []byte("\x02#!SILK_V3\x0a\x00..."),
+			contentType: ContentTypeAudio,
+			expected:    "audio/silk",
+		},
 		{
 			name:        "amr magic bytes",
 			data:        []byte("#!AMR\n....."),
@@ -2,6 +2,7 @@ package chat
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -11,12 +12,39 @@ type Message struct {
 	Sender     string
 	GroupTopic string
 	Content    *Content
+	ReplyTo    *ReplyTo // set when the message quotes an earlier one
 }
 
 func (m Message) ToContentParts() []*Content {
+	header := fmt.Sprintf("[%s] %s", m.Timestamp.Format("15:04"), m.Sender)
+	if m.ReplyTo != nil {
+		header += fmt.Sprintf(" (回复 %s: %q)", m.ReplyTo.Sender, m.ReplyTo.Text)
+	}
+	header += ":"
+
 	// If content is text, merge it with header for better LLM context
 	if m.Content != nil && m.Content.Type == ContentTypeText {
-		text := fmt.Sprintf("[%s] %s: %s", m.Timestamp.Format("15:04"), m.Sender, m.Content.Text)
+		return []*Content{{
+			Type: ContentTypeText,
+			Text: header + " " + m.Content.Text,
+		}}
+	}
+
+	// A transcribed voice message is sent as its text
+	if m.Content != nil && m.Content.Type == ContentTypeAudio && m.Content.Transcript != "" {
+		return []*Content{{
+			Type: ContentTypeText,
+			Text: strings.TrimSuffix(header, ":") + " (语音转写): " + m.Content.Transcript,
+		}}
+	}
+
+	// A captioned image is sent as its description, which models without
+	// vision can read too
+	if m.Content != nil && m.Content.Type == ContentTypeImage && m.Content.Caption != "" {
+		text := strings.TrimSuffix(header, ":") + " (图片描述): " + m.Content.Caption
+		if m.Content.ImageText != "" {
+			text += "\n图中文字: " + m.Content.ImageText
+		}
 		return []*Content{{
 			Type: ContentTypeText,
 			Text: text,
@@ -24,7 +52,6 @@ func (m Message) ToContentParts() []*Content {
 	}
 
 	// For media, we must keep header separate to attribute the media to the sender
-	header := fmt.Sprintf("[%s] %s:", m.Timestamp.Format("15:04"), m.Sender)
 	parts := []*Content{{
 		Type: ContentTypeText,
 		Text: header,
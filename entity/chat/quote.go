@@ -0,0 +1,109 @@
+package chat
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eatmoreapple/openwechat"
+)
+
+// appMsgTypeQuote is the appmsg type of a quoted reply.
+const appMsgTypeQuote = 57
+
+// maxQuoteRunes bounds the quoted text kept with a reply.
+const maxQuoteRunes = 60
+
+// ReplyTo references the message a quoted reply answers.
+type ReplyTo struct {
+	ID     string // server ID of the quoted message
+	Sender string
+	Text   string // the quoted text, or a placeholder such as [图片]
+}
+
+type quoteXML struct {
+	AppMsg struct {
+		Title    string `xml:"title"`
+		Type     int    `xml:"type"`
+		ReferMsg *struct {
+			Type        int    `xml:"type"`
+			SvrID       string `xml:"svrid"`
+			ChatUser    string `xml:"chatusr"`
+			DisplayName string `xml:"displayname"`
+			Content     string `xml:"content"`
+		} `xml:"refermsg"`
+	} `xml:"appmsg"`
+}
+
+// ExtractReplyTo returns what a quoted reply answers, or nil if msg is not
+// a quoted reply.
+func ExtractReplyTo(msg *openwechat.Message) *ReplyTo {
+	if msg.MsgType != openwechat.MsgTypeApp {
+		return nil
+	}
+	_, reply, ok := parseQuote(msg.Content)
+	if !ok {
+		return nil
+	}
+	return reply
+}
+
+// parseQuote parses the appmsg XML of a quoted reply into the reply text and
+// the quoted message.
+func parseQuote(content string) (string, *ReplyTo, bool) {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "&lt;") {
+		content = html.UnescapeString(content)
+	}
+	if !strings.Contains(content, "<refermsg>") {
+		return "", nil, false
+	}
+
+	var q quoteXML
+	if err := xml.Unmarshal([]byte(content), &q); err != nil {
+		return "", nil, false
+	}
+	ref := q.AppMsg.ReferMsg
+	if q.AppMsg.Type != appMsgTypeQuote || ref == nil {
+		return "", nil, false
+	}
+
+	return q.AppMsg.Title, &ReplyTo{
+		ID:     ref.SvrID,
+		Sender: ref.DisplayName,
+		Text:   quotedText(ref.Type, ref.ChatUser, ref.Content),
+	}, true
+}
+
+// quotedText describes the quoted message by its WeChat message type.
+func quotedText(msgType int, chatUser, content string) string {
+	switch openwechat.MessageType(msgType) {
+	case openwechat.MsgTypeText:
+		// Quotes of group messages may carry the sender's user name
+		content = strings.TrimPrefix(content, chatUser+":\n")
+		return truncateRunes(strings.TrimSpace(content), maxQuoteRunes)
+	case openwechat.MsgTypeImage:
+		return "[图片]"
+	case openwechat.MsgTypeVoice:
+		return "[语音]"
+	case openwechat.MsgTypeVideo:
+		return "[视频]"
+	case openwechat.MsgTypeApp:
+		var nested quoteXML
+		if err := xml.Unmarshal([]byte(html.UnescapeString(content)), &nested); err == nil && nested.AppMsg.Title != "" {
+			return fmt.Sprintf("[%s]", truncateRunes(nested.AppMsg.Title, maxQuoteRunes))
+		}
+		return "[链接]"
+	default:
+		return "[消息]"
+	}
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
@@ -0,0 +1,118 @@
+package chat
+
+import (
+	"html"
+	"testing"
+	"time"
+)
+
+const quoteXMLSample = `<msg><appmsg appid="" sdkver="0"><title>周五可以</title><des></des><type>57</type>
+<refermsg><type>1</type><svrid>7013</svrid><fromusr>123@chatroom</fromusr><chatusr>wxid_b</chatusr>
+<displayname>李四</displayname><content>wxid_b:
+v2 什么时候发布？</content></refermsg></appmsg></msg>`
+
+func TestParseQuote(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		text    string
+		reply   *ReplyTo
+	}{
+		{
+			name:    "text quote",
+			content: quoteXMLSample,
+			text:    "周五可以",
+			reply:   &ReplyTo{ID: "7013", Sender: "李四", Text: "v2 什么时候发布？"},
+		},
+		{
+			name:    "escaped xml",
+			content: html.EscapeString(quoteXMLSample),
+			text:    "周五可以",
+			reply:   &ReplyTo{ID: "7013", Sender: "李四", Text: "v2 什么时候发布？"},
+		},
+		{
+			name: "image quote",
+			content: `<msg><appmsg><title>这张图里的数据不对</title><type>57</type>
+<refermsg><type>3</type><svrid>1</svrid><displayname>王五</displayname><content>&lt;msg&gt;&lt;img/&gt;&lt;/msg&gt;</content></refermsg></appmsg></msg>`,
+			text:  "这张图里的数据不对",
+			reply: &ReplyTo{ID: "1", Sender: "王五", Text: "[图片]"},
+		},
+		{
+			name: "file quote",
+			content: `<msg><appmsg><title>看过了</title><type>57</type>
+<refermsg><type>49</type><svrid>2</svrid><displayname>王五</displayname><content>&lt;msg&gt;&lt;appmsg&gt;&lt;title&gt;方案.pdf&lt;/title&gt;&lt;type&gt;6&lt;/type&gt;&lt;/appmsg&gt;&lt;/msg&gt;</content></refermsg></appmsg></msg>`,
+			text:  "看过了",
+			reply: &ReplyTo{ID: "2", Sender: "王五", Text: "[方案.pdf]"},
+		},
+		{
+			name:    "file message",
+			content: `<msg><appmsg><title>方案.pdf</title><type>6</type></appmsg></msg>`,
+		},
+		{
+			name:    "plain text",
+			content: "hello <refermsg>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, reply, ok := parseQuote(tt.content)
+			if ok != (tt.reply != nil) {
+				t.Fatalf("parseQuote() ok = %v", ok)
+			}
+			if !ok {
+				return
+			}
+			if text != tt.text || *reply != *tt.reply {
+				t.Errorf("parseQuote() = %q, %+v; want %q, %+v", text, reply, tt.text, tt.reply)
+			}
+		})
+	}
+}
+
+func TestToContentPartsWithReply(t *testing.T) {
+	msg := Message{
+		Timestamp: time.Date(2025, 3, 5, 10, 2, 0, 0, time.Local),
+		Sender:    "张三",
+		Content:   &Content{Type: ContentTypeText, Text: "周五可以"},
+		ReplyTo:   &ReplyTo{Sender: "李四", Text: "v2 什么时候发布？"},
+	}
+
+	parts := msg.ToContentParts()
+	want := `[10:02] 张三 (回复 李四: "v2 什么时候发布？"): 周五可以`
+	if len(parts) != 1 || parts[0].Text != want {
+		t.Errorf("ToContentParts() = %+v, want %q", parts, want)
+	}
+}
+
+func TestToContentPartsWithTranscript(t *testing.T) {
+	msg := Message{
+		Timestamp: time.Date(2025, 3, 5, 10, 2, 0, 0, time.Local),
+		Sender:    "张三",
+		Content:   &Content{Type: ContentTypeAudio, Data: []byte("voice"), MimeType: "audio/wav", Transcript: "周五发布"},
+	}
+
+	parts := msg.ToContentParts()
+	want := "[10:02] 张三 (语音转写): 周五发布"
+	if len(parts) != 1 || parts[0].Type != ContentTypeText || parts[0].Text != want {
+		t.Errorf("ToContentParts() = %+v, want %q", parts, want)
+	}
+}
+
+func TestToContentPartsWithCaption(t *testing.T) {
+	image := &Content{Type: ContentTypeImage, Data: []byte("png"), MimeType: "image/png", Caption: "登录页报错截图", ImageText: "用户名或密码错误"}
+	msg := Message{
+		Timestamp: time.Date(2025, 3, 5, 10, 3, 0, 0, time.Local),
+		Sender:    "李四",
+		Content:   image.Compact(),
+	}
+	if msg.Content.Data != nil || image.Data == nil {
+		t.Error("Compact() should drop the bytes of a copy")
+	}
+
+	parts := msg.ToContentParts()
+	want := "[10:03] 李四 (图片描述): 登录页报错截图\n图中文字: 用户名或密码错误"
+	if len(parts) != 1 || parts[0].Type != ContentTypeText || parts[0].Text != want {
+		t.Errorf("ToContentParts() = %+v, want %q", parts, want)
+	}
+}
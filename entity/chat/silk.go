@@ -0,0 +1,59 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"github.com/soaringk/msg-asst/pkg/silk"
+	"go.uber.org/zap"
+)
+
+func isSilk(data []byte) bool {
+	return silk.IsSilk(data)
+}
+
+// convertSilk decodes a SILK voice message to WAV, and returns it as it is
+// when decoding fails.
+func convertSilk(data []byte) ([]byte, string) {
+	log := logging.Named("content")
+	pcm, err := silk.Decode(data)
+	if err != nil {
+		log.Warn("Failed to decode SILK voice", zap.Error(err))
+		return data, "audio/silk"
+	}
+	wav := pcmToWAV(pcm, silk.SampleRate)
+	log.Debug("SILK voice decoded",
+		zap.Duration("duration", time.Duration(len(pcm)/2)*time.Second/silk.SampleRate),
+		zap.Int("silkSize", len(data)),
+		zap.Int("wavSize", len(wav)))
+	return wav, "audio/wav"
+}
+
+// pcmToWAV wraps 16-bit mono little-endian PCM in a WAV container.
+func pcmToWAV(pcm []byte, sampleRate int) []byte {
+	const channels, bitsPerSample = 1, 16
+	blockAlign := channels * bitsPerSample / 8
+
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	for _, v := range []any{
+		uint32(16), // fmt chunk size
+		uint16(1),  // PCM
+		uint16(channels),
+		uint32(sampleRate),
+		uint32(sampleRate * blockAlign),
+		uint16(blockAlign),
+		uint16(bitsPerSample),
+	} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
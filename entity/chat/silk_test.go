@@ -0,0 +1,33 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestPCMToWAV(t *testing.T) {
+	wav := pcmToWAV([]byte{1, 2, 3, 4}, 24000)
+	if len(wav) != 48 || detectMimeType(wav, ContentTypeAudio) != "audio/wav" {
+		t.Fatalf("pcmToWAV() = %d bytes, %s", len(wav), detectMimeType(wav, ContentTypeAudio))
+	}
+	if rate := binary.LittleEndian.Uint32(wav[24:]); rate != 24000 {
+		t.Errorf("sample rate = %d", rate)
+	}
+	if size := binary.LittleEndian.Uint32(wav[40:]); size != 4 || !bytes.Equal(wav[44:], []byte{1, 2, 3, 4}) {
+		t.Errorf("data chunk = %d %v", size, wav[44:])
+	}
+}
+
+func TestConvertSilk(t *testing.T) {
+	// A single lost packet decodes to 20ms of concealment
+	data, mimeType := convertSilk([]byte("\x02#!SILK_V3\x00\x00"))
+	if mimeType != "audio/wav" || len(data) != 44+2*480 {
+		t.Errorf("convertSilk() = %d bytes, %s", len(data), mimeType)
+	}
+
+	data, mimeType = convertSilk([]byte("#!SILK_V3\x10\x00abc"))
+	if mimeType != "audio/silk" || !isSilk(data) {
+		t.Errorf("convertSilk() of a truncated stream = %s", mimeType)
+	}
+}
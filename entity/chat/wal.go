@@ -0,0 +1,188 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type walOp string
+
+const (
+	walOpAdd   walOp = "add"
+	walOpClear walOp = "clear"
+	walOpPatch walOp = "patch"
+)
+
+// walRecord is one line of the write-ahead log. A clear record resets the
+// group and carries its lastSummaryTime; add records replay messages in order
+// and patch records replace the content of a message added earlier.
+type walRecord struct {
+	Op      walOp     `json:"op"`
+	Group   string    `json:"group"`
+	Time    time.Time `json:"time,omitzero"`
+	Message *Message  `json:"message,omitempty"`
+}
+
+// renameFile replaces the log with its snapshot; tests swap it to fail.
+var renameFile = os.Rename
+
+type wal struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	threshold int
+	records   int
+	compactAt int
+}
+
+func newWAL(path string, threshold int) *wal {
+	if threshold <= 0 {
+		threshold = 1000
+	}
+	return &wal{
+		path:      path,
+		threshold: threshold,
+	}
+}
+
+// replay feeds every intact record to fn. Reading stops at the first torn or
+// corrupt line (e.g. a crash mid-write); the records before it are kept.
+func (w *wal) replay(fn func(walRecord)) (int, error) {
+	f, err := os.Open(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	count := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return count, fmt.Errorf("truncated WAL record after %d records", count)
+			}
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to read WAL: %w", err)
+		}
+
+		var rec walRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return count, fmt.Errorf("corrupt WAL record after %d records: %w", count, err)
+		}
+		fn(rec)
+		count++
+	}
+}
+
+func (w *wal) append(rec walRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal WAL record: %w", err)
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("WAL is closed")
+	}
+	if _, err := w.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write WAL: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync WAL: %w", err)
+	}
+	w.records++
+	return nil
+}
+
+func (w *wal) needsCompaction() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file != nil && w.records >= w.compactAt
+}
+
+// rewrite atomically replaces the log with the given records and reopens it
+// for appending.
+func (w *wal) rewrite(records []walRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	tmpPath := w.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create WAL snapshot: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(writer)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write WAL snapshot: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to flush WAL snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync WAL snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close WAL snapshot: %w", err)
+	}
+
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	if err := renameFile(tmpPath, w.path); err != nil {
+		os.Remove(tmpPath)
+		// Keep appending to the old log and retry after another threshold
+		if file, openErr := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600); openErr == nil {
+			w.file = file
+		}
+		w.compactAt = w.records + w.threshold
+		return fmt.Errorf("failed to replace WAL: %w", err)
+	}
+
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen WAL: %w", err)
+	}
+	w.file = file
+	w.records = len(records)
+	w.compactAt = len(records) + w.threshold
+	return nil
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
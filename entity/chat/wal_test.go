@@ -0,0 +1,311 @@
+package chat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/config"
+)
+
+func TestWALRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer.wal")
+
+	buf, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	buf.Add(Message{
+		ID:         "a1",
+		Timestamp:  time.Now(),
+		Sender:     "Alice",
+		GroupTopic: "GroupA",
+		Content:    &Content{Type: ContentTypeText, Text: "pending in A"},
+	})
+	buf.Add(Message{
+		ID:         "b1",
+		Timestamp:  time.Now(),
+		Sender:     "Bob",
+		GroupTopic: "GroupB",
+		Content:    &Content{Type: ContentTypeText, Text: "summarized in B"},
+	})
+	buf.Clear("GroupB")
+	buf.Add(Message{
+		ID:         "b2",
+		Timestamp:  time.Now(),
+		Sender:     "Bob",
+		GroupTopic: "GroupB",
+		Content:    &Content{Type: ContentTypeImage, Data: []byte{1, 2, 3}, MimeType: "image/png"},
+	})
+
+	group, _ := buf.groups.Get("GroupB")
+	wantSummaryTime := group.lastSummaryTime
+
+	if err := buf.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	restored, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer restored.Close()
+
+	snapA := restored.GetSnapshot("GroupA")
+	if snapA.Count != 1 || !strings.Contains(snapA.Contents[0].Text, "pending in A") {
+		t.Errorf("GroupA not restored, got count %d", snapA.Count)
+	}
+
+	snapB := restored.GetSnapshot("GroupB")
+	if snapB.Count != 1 {
+		t.Fatalf("Expected 1 message in GroupB after clear, got %d", snapB.Count)
+	}
+	media := snapB.Contents[len(snapB.Contents)-1]
+	if media.Type != ContentTypeImage || !bytes.Equal(media.Data, []byte{1, 2, 3}) {
+		t.Errorf("Image content not restored: %+v", media)
+	}
+
+	restoredGroup, _ := restored.groups.Get("GroupB")
+	if !restoredGroup.lastSummaryTime.Equal(wantSummaryTime) {
+		t.Errorf("lastSummaryTime = %v, want %v", restoredGroup.lastSummaryTime, wantSummaryTime)
+	}
+
+	// Duplicate IDs must still be rejected after a restore
+	restored.Add(Message{
+		ID:         "a1",
+		Timestamp:  time.Now(),
+		Sender:     "Alice",
+		GroupTopic: "GroupA",
+		Content:    &Content{Type: ContentTypeText, Text: "pending in A"},
+	})
+	if got := restored.GetSnapshot("GroupA").Count; got != 1 {
+		t.Errorf("Duplicate message re-added after restore, count %d", got)
+	}
+}
+
+func TestWALPatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer.wal")
+	buf, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	voice := &Content{Type: ContentTypeAudio, Data: []byte("voice"), MimeType: "audio/wav"}
+	buf.Add(Message{ID: "v1", Timestamp: time.Now(), Sender: "Alice", GroupTopic: "GroupA", Content: voice})
+	transcribed := *voice
+	transcribed.Transcript = "周三联调"
+	buf.Patch("GroupA", "v1", transcribed.Compact())
+	// Messages that are not buffered are left alone
+	buf.Patch("GroupA", "missing", &Content{Type: ContentTypeText, Text: "stray"})
+	buf.Patch("GroupB", "v1", &Content{Type: ContentTypeText, Text: "stray"})
+
+	if got := buf.GetMessages("GroupA", Window{}); len(got) != 1 || got[0].Content.Transcript != "周三联调" {
+		t.Fatalf("Patch() not applied: %+v", got)
+	}
+	if voice.Transcript != "" {
+		t.Error("Patch() modified the content it replaced")
+	}
+	buf.Close()
+
+	restored, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer restored.Close()
+
+	got := restored.GetMessages("GroupA", Window{})
+	if len(got) != 1 || got[0].Content.Transcript != "周三联调" || got[0].Content.Data != nil {
+		t.Errorf("Patch not restored: %+v", got[0].Content)
+	}
+	if restored.Count("GroupB") != 0 {
+		t.Error("Patch of an unknown group added messages")
+	}
+}
+
+func TestWALPending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer.wal")
+	buf, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	image := &Content{Type: ContentTypeImage, Data: []byte("png"), MimeType: "image/png"}
+	voice := &Content{Type: ContentTypeAudio, Data: []byte("voice"), MimeType: "audio/wav"}
+	buf.AddPending(Message{ID: "i1", Timestamp: time.Now(), Sender: "Alice", GroupTopic: "GroupA", Content: image})
+	buf.AddPending(Message{ID: "v1", Timestamp: time.Now(), Sender: "Bob", GroupTopic: "GroupA", Content: voice})
+	if got := buf.GetMessages("GroupA", Window{}); got[0].Content.Data == nil {
+		t.Fatal("AddPending() dropped the media from the buffer")
+	}
+	// Captioned image, and voice that failed to transcribe
+	captioned := *image
+	captioned.Caption = "登录页报错截图"
+	buf.Patch("GroupA", "i1", captioned.Compact())
+	buf.Patch("GroupA", "v1", voice.Compact())
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "cG5n" is base64 of the image bytes
+	if strings.Contains(string(data), "cG5n") {
+		t.Error("WAL holds the bytes of a captioned image")
+	}
+	if err := buf.compact(); err != nil {
+		t.Fatalf("compact() failed: %v", err)
+	}
+	buf.Close()
+
+	restored, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	got := restored.GetMessages("GroupA", Window{})
+	if len(got) != 2 || got[0].Content.Caption != "登录页报错截图" || got[0].Content.Data != nil {
+		t.Errorf("captioned image restored as %+v", got[0].Content)
+	}
+	if len(got) == 2 && string(got[1].Content.Data) != "voice" {
+		t.Errorf("failed voice restored as %+v", got[1].Content)
+	}
+
+	// A crash before the patch restores the message without its media
+	restored.AddPending(Message{ID: "i2", Timestamp: time.Now(), Sender: "Alice", GroupTopic: "GroupA", Content: image})
+	if err := restored.compact(); err != nil {
+		t.Fatalf("compact() failed: %v", err)
+	}
+	restored.Close()
+
+	crashed, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer crashed.Close()
+	got = crashed.GetMessages("GroupA", Window{})
+	if len(got) != 3 || got[2].Content.Data != nil || got[2].Content.Description() != "[图片]" {
+		t.Errorf("pending image restored as %+v", got[len(got)-1].Content)
+	}
+}
+
+func TestWALCompaction(t *testing.T) {
+	os.Setenv("BUFFER_WAL_COMPACT_THRESHOLD", "4")
+	defer func() {
+		os.Unsetenv("BUFFER_WAL_COMPACT_THRESHOLD")
+		_ = config.Parse()
+	}()
+	_ = config.Parse()
+
+	path := filepath.Join(t.TempDir(), "buffer.wal")
+	buf, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	for i := 1; i <= 10; i++ {
+		buf.Add(Message{
+			ID:         fmt.Sprintf("msg%d", i),
+			Timestamp:  time.Now(),
+			Sender:     "User",
+			GroupTopic: "CompactGroup",
+			Content:    &Content{Type: ContentTypeText, Text: fmt.Sprintf("Message %d", i)},
+		})
+		if i == 6 {
+			buf.Clear("CompactGroup")
+		}
+	}
+	buf.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 clear record + 4 live messages, plus fewer than threshold appends
+	lines := strings.Count(string(data), "\n")
+	if lines >= 5+4 {
+		t.Errorf("Expected WAL to be compacted, got %d records", lines)
+	}
+
+	restored, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer restored.Close()
+
+	snapshot := restored.GetSnapshot("CompactGroup")
+	if snapshot.Count != 4 {
+		t.Fatalf("Expected 4 messages after compaction, got %d", snapshot.Count)
+	}
+	if !strings.Contains(snapshot.Contents[0].Text, "Message 7") {
+		t.Errorf("Expected first message to be 'Message 7', got %q", snapshot.Contents[0].Text)
+	}
+}
+
+func TestWALRenameFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer.wal")
+	buf, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	add := func(id string) {
+		buf.Add(Message{ID: id, Timestamp: time.Now(), Sender: "User", GroupTopic: "GroupA",
+			Content: &Content{Type: ContentTypeText, Text: id}})
+	}
+	add("msg1")
+
+	renameFile = func(string, string) error { return errors.New("device busy") }
+	err = buf.compact()
+	renameFile = os.Rename
+	if err == nil {
+		t.Fatal("compact() should report the failed rename")
+	}
+	// The old log stays open for appending
+	add("msg2")
+	buf.Close()
+
+	restored, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer restored.Close()
+	if got := restored.Count("GroupA"); got != 2 {
+		t.Errorf("Expected 2 messages after a failed compaction, got %d", got)
+	}
+}
+
+func TestWALTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buffer.wal")
+
+	buf, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	buf.Add(Message{
+		ID:         "msg1",
+		Timestamp:  time.Now(),
+		Sender:     "Alice",
+		GroupTopic: "TornGroup",
+		Content:    &Content{Type: ContentTypeText, Text: "survives"},
+	})
+	buf.Close()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"add","group":"TornGroup","mess`)
+	f.Close()
+
+	restored, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() with torn record failed: %v", err)
+	}
+	defer restored.Close()
+
+	if got := restored.GetSnapshot("TornGroup").Count; got != 1 {
+		t.Errorf("Expected 1 message before torn record, got %d", got)
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -29,25 +31,198 @@ type MediaSupportConfig struct {
 type SummaryTriggerConfig struct {
 	IntervalMinutes       int
 	MessageCount          int
+	IdleMinutes           int // summarize once a group has been quiet this long
 	Keyword               string
 	MinMessagesForSummary int
+	Schedules             []GroupRule // cron expression per group
+	ScheduleStateFile     string
+	Schedule              string // resolved for one group by ForGroup
+	// Rolling summaries pass the previous minutes as context, so only new
+	// developments are reported
+	RollingEnabled     bool
+	RollingMaxAgeHours int
+}
+
+type DeliveryConfig struct {
+	Targets []string    // default targets, e.g. "filehelper", "group", "contact:Alice", "dir:minutes"
+	Routes  []GroupRule // per-group targets, comma-separated in Value
+}
+
+// GroupRule maps groups whose name contains Pattern to Value. Pattern "*"
+// matches every group.
+type GroupRule struct {
+	Pattern string
+	Value   string
+}
+
+type WebhookConfig struct {
+	URL              string
+	Secret           string // HMAC-SHA256 signing key; requests are unsigned when empty
+	MaxRetries       int
+	RetryBaseSeconds int
+	TimeoutSeconds   int
+	DeadLetterDir    string
+}
+
+type DigestConfig struct {
+	Schedule string   // cron expression; the digest is off when empty
+	Targets  []string // delivery targets; DELIVERY_TARGETS when empty
+}
+
+type TodoConfig struct {
+	Enabled           bool
+	File              string
+	RemindBeforeHours int      // remind this long before an item is due
+	RemindTargets     []string // delivery targets of reminders
+}
+
+type UsageConfig struct {
+	File   string       // token and cost ledger
+	Prices []ModelPrice // LLM_PRICES
+	// DailyBudgets caps a group's spend per day, in the currency of Prices
+	DailyBudgets []GroupRule
+	DailyBudget  float64 // resolved for one group by ForGroup, 0 for none
+	BudgetAction string  // "text_only" or "pause" once the budget is spent
+}
+
+// Budget actions applied to a group that spent its daily budget.
+const (
+	BudgetTextOnly = "text_only" // summarize without media
+	BudgetPause    = "pause"     // keep messages buffered until the next day
+)
+
+// TranscribeConfig selects the speech-to-text service that transcribes voice
+// messages on arrival.
+type TranscribeConfig struct {
+	Provider       string // "openai", "whisper" or "gemini"; off when empty
+	APIKey         string
+	BaseURL        string
+	Model          string
+	Language       string // ISO 639-1 hint, e.g. "zh"
+	TimeoutSeconds int
+	Local          bool // the service runs on premises, so audio may be sent to it from any group
+}
+
+// Transcription providers. Whisper is a self-hosted server with an
+// OpenAI-compatible /audio/transcriptions endpoint.
+const (
+	TranscribeOpenAI  = "openai"
+	TranscribeWhisper = "whisper"
+	TranscribeGemini  = "gemini"
+)
+
+// CaptionConfig selects the vision model that describes images on arrival,
+// so the buffer holds text instead of image bytes.
+type CaptionConfig struct {
+	Provider       string // an LLM_PROVIDERS or LLM_FALLBACKS name; off when empty
+	Model          string // overrides the provider's model
+	TimeoutSeconds int
+}
+
+// ModelPrice is the price of one million tokens of models whose name starts
+// with Model. Cached is the price of cached prompt tokens, Input if zero.
+type ModelPrice struct {
+	Model  string
+	Input  float64
+	Output float64
+	Cached float64
+}
+
+// ProviderConfig describes one LLM backend: the primary provider or an
+// LLM_FALLBACKS or LLM_PROVIDERS entry.
+type ProviderConfig struct {
+	Name            string
+	Provider        string // "openai", "gemini", "anthropic", "ollama" or "llamacpp"
+	APIKey          string
+	BaseURL         string
+	Model           string
+	MaxOutputTokens int
+	ContextTokens   int // context window of local models, 0 for the default
+}
+
+// isLocalURL reports whether rawURL names this machine or an address on a
+// private network.
+func isLocalURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate())
+}
+
+// isLocalProvider reports whether provider is a local model server, which
+// needs no API key.
+func isLocalProvider(provider string) bool {
+	return provider == "ollama" || provider == "llamacpp"
+}
+
+type ArchiveConfig struct {
+	Enabled  bool
+	DBFile   string
+	MediaDir string // media payloads are only hashed when empty
 }
 
 type Config struct {
-	LLMAPIKey        string
-	LLMBaseURL       string
-	LLMModel         string
-	LLMProvider      string // "openai" or "gemini"
-	SystemPromptFile string
-	BotName          string
-	SummaryTrigger   SummaryTriggerConfig
-	MediaSupport     MediaSupportConfig
-	MaxBufferSize    int
+	LLMAPIKey   string
+	LLMBaseURL  string
+	LLMModel    string
+	LLMProvider string // "openai", "gemini", "anthropic", "ollama" or "llamacpp"
+	// LLMMaxOutputTokens caps the response length where the API requires it
+	// (Anthropic)
+	LLMMaxOutputTokens int
+	LLMContextTokens   int // context window of a local primary model, 0 for the default
+	// LLMFallbacks are tried in order when the primary provider fails
+	LLMFallbacks []ProviderConfig
+	// LLMProviders are named providers that groups can select instead of the
+	// primary provider
+	LLMProviders []ProviderConfig
+	// LLMGroupProvider is resolved for one group by ForGroup: the name of the
+	// provider that alone summarizes the group, with no fallbacks
+	LLMGroupProvider string
+	// LLMBreaker* control the circuit breaker of each provider in the chain
+	LLMBreakerFailures        int
+	LLMBreakerCooldownSeconds int
+	// LLMMaxRetries retries rate-limited and unavailable requests with
+	// jittered exponential backoff from LLMRetryBaseSeconds, capped at
+	// LLMRetryMaxSeconds
+	LLMMaxRetries       int
+	LLMRetryBaseSeconds int
+	LLMRetryMaxSeconds  int
+	LLMTimeoutSeconds   int // per request attempt, 0 for none
+	// LLMChunk* override the provider's per-request limits; 0 keeps the default
+	LLMChunkMaxTokens int
+	LLMChunkMaxBytes  int64
+	// LLMStructuredOutput requests schema-constrained JSON minutes from
+	// providers that support it
+	LLMStructuredOutput bool
+	SystemPromptFile    string
+	PromptDir           string // named prompt templates selectable by command
+	BotName             string
+	SummaryTrigger      SummaryTriggerConfig
+	MediaSupport        MediaSupportConfig
+	MaxBufferSize       int
+	// BufferWAL persists unsummarized messages across restarts
+	BufferWALEnabled          bool
+	BufferWALFile             string
+	BufferWALCompactThreshold int
+	Archive                   ArchiveConfig
+	Delivery                  DeliveryConfig
+	Webhook                   WebhookConfig
+	Digest                    DigestConfig
+	Todo                      TodoConfig
+	Usage                     UsageConfig
+	Transcribe                TranscribeConfig
+	Caption                   CaptionConfig
 }
 
 var (
 	configPtr       atomic.Pointer[Config]
-	targetGroups    atomic.Pointer[[]string]
+	groupConfigs    atomic.Pointer[[]GroupConfig]
 	configWatcher   *fsnotify.Watcher
 	groupsWatcher   *fsnotify.Watcher
 	callbacksMu     sync.RWMutex
@@ -62,12 +237,35 @@ func GetConfig() *Config {
 	return configPtr.Load()
 }
 
+// GetTargetGroups returns the group names from groups.json
 func GetTargetGroups() []string {
-	groups := targetGroups.Load()
+	groups := GetGroupConfigs()
 	if groups == nil {
 		return nil
 	}
-	return *groups
+	names := make([]string, len(groups))
+	for i, group := range groups {
+		names[i] = group.Name
+	}
+	return names
+}
+
+// MatchGroup reports whether groupName is selected by pattern, using the same
+// case-insensitive substring match as groups.json.
+func MatchGroup(pattern, groupName string) bool {
+	if pattern == "*" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(groupName), strings.ToLower(pattern))
+}
+
+func matchRule(rules []GroupRule, groupTopic string) (GroupRule, bool) {
+	for _, rule := range rules {
+		if MatchGroup(rule.Pattern, groupTopic) {
+			return rule, true
+		}
+	}
+	return GroupRule{}, false
 }
 
 // OnConfigChange registers a callback to be called when config changes
@@ -108,24 +306,181 @@ func Load() error {
 	return nil
 }
 
+// defaultBaseURL returns the API endpoint used when LLM_BASE_URL is unset.
+func defaultBaseURL(provider string) string {
+	switch provider {
+	case "anthropic":
+		return "https://api.anthropic.com"
+	case "ollama":
+		return "http://localhost:11434"
+	case "llamacpp":
+		return "http://localhost:8080"
+	default:
+		return "https://generativelanguage.googleapis.com"
+	}
+}
+
+// defaultTranscribeBaseURL returns the endpoint used when
+// TRANSCRIBE_BASE_URL is unset. Gemini is reached through its SDK.
+func defaultTranscribeBaseURL(provider string) string {
+	switch provider {
+	case TranscribeOpenAI:
+		return "https://api.openai.com/v1"
+	case TranscribeWhisper:
+		return "http://localhost:8000/v1"
+	default:
+		return ""
+	}
+}
+
+// defaultTranscribeModel returns the model used when TRANSCRIBE_MODEL is
+// unset.
+func defaultTranscribeModel(provider string) string {
+	switch provider {
+	case TranscribeOpenAI:
+		return "gpt-4o-mini-transcribe"
+	case TranscribeWhisper:
+		return "whisper-1"
+	case TranscribeGemini:
+		return "gemini-2.5-flash"
+	default:
+		return ""
+	}
+}
+
+// parseProviders reads the LLM_<NAME>_* settings of each named provider,
+// e.g. LLM_FALLBACKS=openai with LLM_OPENAI_PROVIDER, LLM_OPENAI_MODEL,
+// LLM_OPENAI_API_KEY and LLM_OPENAI_BASE_URL.
+func parseProviders(names []string) []ProviderConfig {
+	var providers []ProviderConfig
+	for _, name := range names {
+		prefix := "LLM_" + envName(name) + "_"
+		provider := getEnv(prefix+"PROVIDER", "openai")
+		providers = append(providers, ProviderConfig{
+			Name:            name,
+			Provider:        provider,
+			APIKey:          getEnv(prefix+"API_KEY", ""),
+			BaseURL:         getEnv(prefix+"BASE_URL", defaultBaseURL(provider)),
+			Model:           getEnv(prefix+"MODEL", ""),
+			MaxOutputTokens: getEnvInt(prefix+"MAX_OUTPUT_TOKENS", getEnvInt("LLM_MAX_OUTPUT_TOKENS", 8192)),
+			ContextTokens:   getEnvInt(prefix+"CONTEXT_TOKENS", 0),
+		})
+	}
+	return providers
+}
+
+// envName upper-cases name and replaces characters not allowed in an
+// environment variable name with underscores.
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// PrimaryProvider returns the LLM_* provider settings.
+func (c *Config) PrimaryProvider() ProviderConfig {
+	return ProviderConfig{
+		Name:            c.LLMProvider,
+		Provider:        c.LLMProvider,
+		APIKey:          c.LLMAPIKey,
+		BaseURL:         c.LLMBaseURL,
+		Model:           c.LLMModel,
+		MaxOutputTokens: c.LLMMaxOutputTokens,
+		ContextTokens:   c.LLMContextTokens,
+	}
+}
+
+// CaptionProvider returns the provider settings of CAPTION_PROVIDER, with
+// CAPTION_MODEL applied. ok is false when captioning is off.
+func (c *Config) CaptionProvider() (pc ProviderConfig, ok bool) {
+	if c.Caption.Provider == "" {
+		return ProviderConfig{}, false
+	}
+	pc, ok = c.NamedProvider(c.Caption.Provider)
+	if ok && c.Caption.Model != "" {
+		pc.Model = c.Caption.Model
+	}
+	return pc, ok
+}
+
+// IsLocal reports whether pc is a local model server, so content sent to it
+// stays on premises.
+func (pc ProviderConfig) IsLocal() bool {
+	return isLocalProvider(pc.Provider)
+}
+
+// OnPremOnly reports whether the group is bound to a local provider, so its
+// content must not be sent to cloud services. A group bound to a provider
+// that is not configured counts as on-prem, as its summaries fail rather
+// than leave the building.
+func (c *Config) OnPremOnly() bool {
+	if c.LLMGroupProvider == "" {
+		return false
+	}
+	pc, ok := c.NamedProvider(c.LLMGroupProvider)
+	return !ok || pc.IsLocal()
+}
+
+// NamedProvider returns the LLM_PROVIDERS or LLM_FALLBACKS entry called
+// name.
+func (c *Config) NamedProvider(name string) (ProviderConfig, bool) {
+	for _, list := range [][]ProviderConfig{c.LLMProviders, c.LLMFallbacks} {
+		for _, pc := range list {
+			if strings.EqualFold(pc.Name, name) {
+				return pc, true
+			}
+		}
+	}
+	return ProviderConfig{}, false
+}
+
 // Parse reads .env and updates config atomically
 func Parse() error {
 	if err := godotenv.Load(); err != nil {
 		logging.Info("No .env file found, using environment variables")
 	}
 
+	provider := getEnv("LLM_PROVIDER", "gemini")
+	transcriber := getEnv("TRANSCRIBE_PROVIDER", "")
+	transcribeURL := getEnv("TRANSCRIBE_BASE_URL", defaultTranscribeBaseURL(transcriber))
 	cfg := &Config{
-		LLMAPIKey:        getEnv("LLM_API_KEY", ""),
-		LLMBaseURL:       getEnv("LLM_BASE_URL", "https://generativelanguage.googleapis.com"),
-		LLMModel:         getEnv("LLM_MODEL", "gemini-2.5-flash"),
-		LLMProvider:      getEnv("LLM_PROVIDER", "gemini"),
-		SystemPromptFile: getEnv("SYSTEM_PROMPT_FILE", "system_prompt.txt"),
-		BotName:          getEnv("BOT_NAME", "meeting-minutes-bot"),
+		LLMAPIKey:                 getEnv("LLM_API_KEY", ""),
+		LLMBaseURL:                getEnv("LLM_BASE_URL", defaultBaseURL(provider)),
+		LLMModel:                  getEnv("LLM_MODEL", "gemini-2.5-flash"),
+		LLMProvider:               provider,
+		LLMMaxOutputTokens:        getEnvInt("LLM_MAX_OUTPUT_TOKENS", 8192),
+		LLMContextTokens:          getEnvInt("LLM_CONTEXT_TOKENS", 0),
+		LLMFallbacks:              parseProviders(splitList(getEnv("LLM_FALLBACKS", ""))),
+		LLMProviders:              parseProviders(splitList(getEnv("LLM_PROVIDERS", ""))),
+		LLMBreakerFailures:        getEnvInt("LLM_BREAKER_FAILURES", 3),
+		LLMBreakerCooldownSeconds: getEnvInt("LLM_BREAKER_COOLDOWN_SECONDS", 120),
+		LLMMaxRetries:             getEnvInt("LLM_MAX_RETRIES", 3),
+		LLMRetryBaseSeconds:       getEnvInt("LLM_RETRY_BASE_SECONDS", 2),
+		LLMRetryMaxSeconds:        getEnvInt("LLM_RETRY_MAX_SECONDS", 60),
+		LLMTimeoutSeconds:         getEnvInt("LLM_TIMEOUT_SECONDS", 180),
+		LLMChunkMaxTokens:         getEnvInt("LLM_CHUNK_MAX_TOKENS", 0),
+		LLMChunkMaxBytes:          getEnvBytes("LLM_CHUNK_MAX_BYTES", 0),
+		LLMStructuredOutput:       getEnvBool("LLM_STRUCTURED_OUTPUT", true),
+		SystemPromptFile:          getEnv("SYSTEM_PROMPT_FILE", "system_prompt.txt"),
+		PromptDir:                 getEnv("PROMPT_DIR", "prompts"),
+		BotName:                   getEnv("BOT_NAME", "meeting-minutes-bot"),
 		SummaryTrigger: SummaryTriggerConfig{
 			IntervalMinutes:       getEnvInt("SUMMARY_INTERVAL_MINUTES", 30),
 			MessageCount:          getEnvInt("SUMMARY_MESSAGE_COUNT", 50),
+			IdleMinutes:           getEnvInt("SUMMARY_IDLE_MINUTES", 0),
 			Keyword:               getEnv("SUMMARY_KEYWORD", "@bot 总结"),
 			MinMessagesForSummary: getEnvInt("MIN_MESSAGES_FOR_SUMMARY", 5),
+			Schedules:             parseGroupRules(getEnv("SUMMARY_SCHEDULES", "")),
+			ScheduleStateFile:     getEnv("SCHEDULE_STATE_FILE", "schedule_state.json"),
+			RollingEnabled:        getEnvBool("SUMMARY_ROLLING_ENABLED", true),
+			RollingMaxAgeHours:    getEnvInt("SUMMARY_ROLLING_MAX_AGE_HOURS", 24),
 		},
 		MediaSupport: MediaSupportConfig{
 			ImageEnabled:  getEnvBool("MEDIA_IMAGE_ENABLED", true),
@@ -137,7 +492,57 @@ func Parse() error {
 			MaxAudioBytes: getEnvBytes("MEDIA_MAX_AUDIO_SIZE", 10*1024*1024),
 			MaxPDFBytes:   getEnvBytes("MEDIA_MAX_PDF_SIZE", 10*1024*1024),
 		},
-		MaxBufferSize: getEnvInt("MAX_BUFFER_SIZE", 200),
+		MaxBufferSize:             getEnvInt("MAX_BUFFER_SIZE", 200),
+		BufferWALEnabled:          getEnvBool("BUFFER_WAL_ENABLED", true),
+		BufferWALFile:             getEnv("BUFFER_WAL_FILE", "buffer.wal"),
+		BufferWALCompactThreshold: getEnvInt("BUFFER_WAL_COMPACT_THRESHOLD", 1000),
+		Archive: ArchiveConfig{
+			Enabled:  getEnvBool("ARCHIVE_ENABLED", true),
+			DBFile:   getEnv("ARCHIVE_DB_FILE", "archive.db"),
+			MediaDir: getEnv("ARCHIVE_MEDIA_DIR", ""),
+		},
+		Delivery: DeliveryConfig{
+			Targets: splitList(getEnv("DELIVERY_TARGETS", "filehelper")),
+			Routes:  parseGroupRules(getEnv("DELIVERY_ROUTES", "")),
+		},
+		Webhook: WebhookConfig{
+			URL:              getEnv("WEBHOOK_URL", ""),
+			Secret:           getEnv("WEBHOOK_SECRET", ""),
+			MaxRetries:       getEnvInt("WEBHOOK_MAX_RETRIES", 5),
+			RetryBaseSeconds: getEnvInt("WEBHOOK_RETRY_BASE_SECONDS", 2),
+			TimeoutSeconds:   getEnvInt("WEBHOOK_TIMEOUT_SECONDS", 10),
+			DeadLetterDir:    getEnv("WEBHOOK_DEAD_LETTER_DIR", "webhook_dead_letters"),
+		},
+		Digest: DigestConfig{
+			Schedule: getEnv("DIGEST_SCHEDULE", ""),
+			Targets:  splitList(getEnv("DIGEST_TARGETS", "")),
+		},
+		Todo: TodoConfig{
+			Enabled:           getEnvBool("TODO_ENABLED", true),
+			File:              getEnv("TODO_FILE", "todos.json"),
+			RemindBeforeHours: getEnvInt("TODO_REMIND_BEFORE_HOURS", 24),
+			RemindTargets:     splitList(getEnv("TODO_REMIND_TARGETS", "filehelper")),
+		},
+		Usage: UsageConfig{
+			File:         getEnv("USAGE_FILE", "usage.json"),
+			Prices:       parsePrices(getEnv("LLM_PRICES", "")),
+			DailyBudgets: parseGroupRules(getEnv("USAGE_DAILY_BUDGETS", "")),
+			BudgetAction: getEnv("USAGE_BUDGET_ACTION", BudgetTextOnly),
+		},
+		Transcribe: TranscribeConfig{
+			Provider:       transcriber,
+			APIKey:         getEnv("TRANSCRIBE_API_KEY", getEnv("LLM_API_KEY", "")),
+			BaseURL:        transcribeURL,
+			Model:          getEnv("TRANSCRIBE_MODEL", defaultTranscribeModel(transcriber)),
+			Language:       getEnv("TRANSCRIBE_LANGUAGE", "zh"),
+			TimeoutSeconds: getEnvInt("TRANSCRIBE_TIMEOUT_SECONDS", 30),
+			Local:          getEnvBool("TRANSCRIBE_LOCAL", isLocalURL(transcribeURL)),
+		},
+		Caption: CaptionConfig{
+			Provider:       getEnv("CAPTION_PROVIDER", ""),
+			Model:          getEnv("CAPTION_MODEL", ""),
+			TimeoutSeconds: getEnvInt("CAPTION_TIMEOUT_SECONDS", 30),
+		},
 	}
 
 	if err := cfg.validate(); err != nil {
@@ -148,25 +553,43 @@ func Parse() error {
 	return nil
 }
 
-// LoadGroups loads target groups from groups.json
+// LoadGroups loads target groups and their overrides from groups.json
 func LoadGroups() error {
 	data, err := os.ReadFile(groupsFile)
 	if err != nil {
 		return err
 	}
 
-	var groups []string
+	var groups []GroupConfig
 	if err := json.Unmarshal(data, &groups); err != nil {
 		return fmt.Errorf("failed to parse groups.json: %w", err)
 	}
+	for _, g := range groups {
+		g.warnIgnored()
+	}
 
-	targetGroups.Store(&groups)
+	groupConfigs.Store(&groups)
 	logging.Info("Loaded target groups from groups.json", zap.Int("count", len(groups)))
 	return nil
 }
 
-// SaveGroups saves target groups to groups.json
-func SaveGroups(groups []string) error {
+// SaveGroups saves target groups to groups.json. Overrides of groups that
+// are kept are preserved.
+func SaveGroups(names []string) error {
+	existing := make(map[string]GroupConfig)
+	for _, group := range GetGroupConfigs() {
+		existing[group.Name] = group
+	}
+
+	groups := make([]GroupConfig, len(names))
+	for i, name := range names {
+		if group, ok := existing[name]; ok {
+			groups[i] = group
+		} else {
+			groups[i] = GroupConfig{Name: name}
+		}
+	}
+
 	data, err := json.MarshalIndent(groups, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal groups: %w", err)
@@ -176,7 +599,7 @@ func SaveGroups(groups []string) error {
 		return fmt.Errorf("failed to write groups.json: %w", err)
 	}
 
-	targetGroups.Store(&groups)
+	groupConfigs.Store(&groups)
 	logging.Info("Saved groups to groups.json", zap.Int("count", len(groups)))
 	return nil
 }
@@ -278,12 +701,38 @@ func StopWatchers() {
 }
 
 func (c *Config) validate() error {
-	if c.LLMAPIKey == "" {
+	if c.LLMAPIKey == "" && !isLocalProvider(c.LLMProvider) {
 		return fmt.Errorf("LLM_API_KEY is required")
 	}
 	if c.SystemPromptFile == "" {
 		return fmt.Errorf("SYSTEM_PROMPT_FILE is required")
 	}
+	if c.MaxBufferSize <= 0 {
+		return fmt.Errorf("MAX_BUFFER_SIZE must be positive")
+	}
+	if c.Usage.BudgetAction != BudgetTextOnly && c.Usage.BudgetAction != BudgetPause {
+		return fmt.Errorf("USAGE_BUDGET_ACTION must be %q or %q", BudgetTextOnly, BudgetPause)
+	}
+	for _, f := range c.LLMFallbacks {
+		if f.Model == "" {
+			return fmt.Errorf("LLM_%s_MODEL is required for fallback %s", envName(f.Name), f.Name)
+		}
+	}
+	switch c.Transcribe.Provider {
+	case "", TranscribeOpenAI, TranscribeWhisper, TranscribeGemini:
+	default:
+		return fmt.Errorf("TRANSCRIBE_PROVIDER must be %q, %q or %q", TranscribeOpenAI, TranscribeWhisper, TranscribeGemini)
+	}
+	for _, p := range c.LLMProviders {
+		if p.Model == "" {
+			return fmt.Errorf("LLM_%s_MODEL is required for provider %s", envName(p.Name), p.Name)
+		}
+	}
+	if c.Caption.Provider != "" {
+		if _, ok := c.NamedProvider(c.Caption.Provider); !ok {
+			return fmt.Errorf("CAPTION_PROVIDER %q must be listed in LLM_PROVIDERS or LLM_FALLBACKS", c.Caption.Provider)
+		}
+	}
 
 	logging.Info("Configuration loaded successfully")
 	logging.Info("Bot settings",
@@ -291,6 +740,18 @@ func (c *Config) validate() error {
 		zap.String("model", c.LLMModel),
 		zap.String("baseURL", c.LLMBaseURL),
 		zap.String("promptFile", c.SystemPromptFile))
+	for _, f := range c.LLMFallbacks {
+		logging.Info("Fallback provider",
+			zap.String("name", f.Name),
+			zap.String("provider", f.Provider),
+			zap.String("model", f.Model))
+	}
+	for _, p := range c.LLMProviders {
+		logging.Info("Named provider",
+			zap.String("name", p.Name),
+			zap.String("provider", p.Provider),
+			zap.String("model", p.Model))
+	}
 
 	groups := GetTargetGroups()
 	if len(groups) > 0 {
@@ -302,8 +763,10 @@ func (c *Config) validate() error {
 	logging.Info("Summary triggers",
 		zap.Int("interval", c.SummaryTrigger.IntervalMinutes),
 		zap.Int("messageCount", c.SummaryTrigger.MessageCount),
+		zap.Int("idle", c.SummaryTrigger.IdleMinutes),
 		zap.Int("minMessages", c.SummaryTrigger.MinMessagesForSummary),
-		zap.String("keyword", c.SummaryTrigger.Keyword))
+		zap.String("keyword", c.SummaryTrigger.Keyword),
+		zap.Int("schedules", len(c.SummaryTrigger.Schedules)))
 
 	return nil
 }
@@ -316,6 +779,77 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePrices parses "model=input/output[/cached];..." prices per million
+// tokens, e.g. "gemini-2.5-flash=0.30/2.50/0.075".
+func parsePrices(value string) []ModelPrice {
+	var prices []ModelPrice
+	for _, rule := range parseGroupRules(value) {
+		fields := strings.Split(rule.Value, "/")
+		if len(fields) < 2 || len(fields) > 3 {
+			logging.Warn("Invalid LLM price, want input/output[/cached]", zap.String("model", rule.Pattern), zap.String("price", rule.Value))
+			continue
+		}
+		var amounts [3]float64
+		valid := true
+		for i, field := range fields {
+			amount, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil || amount < 0 {
+				valid = false
+				break
+			}
+			amounts[i] = amount
+		}
+		if !valid {
+			logging.Warn("Invalid LLM price", zap.String("model", rule.Pattern), zap.String("price", rule.Value))
+			continue
+		}
+		prices = append(prices, ModelPrice{Model: rule.Pattern, Input: amounts[0], Output: amounts[1], Cached: amounts[2]})
+	}
+	return prices
+}
+
+// PriceFor returns the price of the longest model name prefix matching
+// model.
+func (c *UsageConfig) PriceFor(model string) (ModelPrice, bool) {
+	var best ModelPrice
+	found := false
+	for _, p := range c.Prices {
+		if strings.HasPrefix(model, p.Model) && (!found || len(p.Model) > len(best.Model)) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// parseGroupRules parses "pattern=value;pattern=value" into ordered rules.
+func parseGroupRules(value string) []GroupRule {
+	var rules []GroupRule
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, ruleValue, ok := strings.Cut(entry, "=")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			logging.Warn("Invalid group rule, ignoring", zap.String("rule", entry))
+			continue
+		}
+		rules = append(rules, GroupRule{Pattern: pattern, Value: strings.TrimSpace(ruleValue)})
+	}
+	return rules
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	value := os.Getenv(key)
 	if value == "" {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -36,6 +37,12 @@ func TestParse(t *testing.T) {
 	if cfg.SummaryTrigger.IntervalMinutes != 15 {
 		t.Errorf("IntervalMinutes = %d, want %d", cfg.SummaryTrigger.IntervalMinutes, 15)
 	}
+
+	os.Setenv("MAX_BUFFER_SIZE", "0")
+	defer os.Unsetenv("MAX_BUFFER_SIZE")
+	if err := Parse(); err == nil {
+		t.Error("Parse() should reject a non-positive MAX_BUFFER_SIZE")
+	}
 }
 
 func TestGetConfigConcurrent(t *testing.T) {
@@ -160,3 +167,220 @@ func TestParseBytes(t *testing.T) {
 		}
 	}
 }
+
+func TestDeliveryTargets(t *testing.T) {
+	os.Setenv("LLM_API_KEY", "test-key")
+	os.Setenv("DELIVERY_TARGETS", "filehelper, dir:minutes")
+	os.Setenv("DELIVERY_ROUTES", "研发=group,contact:张三; Management = contact:Boss ;broken")
+	defer func() {
+		os.Unsetenv("LLM_API_KEY")
+		os.Unsetenv("DELIVERY_TARGETS")
+		os.Unsetenv("DELIVERY_ROUTES")
+	}()
+
+	if err := Parse(); err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	cfg := GetConfig()
+
+	if len(cfg.Delivery.Routes) != 2 {
+		t.Fatalf("Expected 2 valid routes, got %d: %+v", len(cfg.Delivery.Routes), cfg.Delivery.Routes)
+	}
+
+	tests := []struct {
+		group    string
+		expected []string
+	}{
+		{"后端研发群", []string{"group", "contact:张三"}},
+		{"management weekly", []string{"contact:Boss"}},
+		{"闲聊群", []string{"filehelper", "dir:minutes"}},
+	}
+
+	for _, tt := range tests {
+		got := ForGroup(tt.group).Delivery.Targets
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("DeliveryTargets(%q) = %v, want %v", tt.group, got, tt.expected)
+		}
+	}
+}
+
+func TestGroupOverrides(t *testing.T) {
+	os.Setenv("LLM_API_KEY", "test-key")
+	os.Setenv("LLM_MODEL", "base-model")
+	os.Setenv("DELIVERY_ROUTES", "研发=group")
+	os.Setenv("LLM_PROVIDERS", "onprem")
+	os.Setenv("LLM_ONPREM_PROVIDER", "ollama")
+	os.Setenv("LLM_ONPREM_MODEL", "qwen3")
+	defer func() {
+		os.Unsetenv("LLM_API_KEY")
+		os.Unsetenv("LLM_MODEL")
+		os.Unsetenv("DELIVERY_ROUTES")
+		os.Unsetenv("LLM_PROVIDERS")
+		os.Unsetenv("LLM_ONPREM_PROVIDER")
+		os.Unsetenv("LLM_ONPREM_MODEL")
+		os.Remove(groupsFile)
+	}()
+	if err := Parse(); err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	data := `[
+  "闲聊",
+  {"name": "研发", "min_messages": 20, "model": "big-model", "delivery": ["dir:minutes"], "media": {"video": false}},
+  {"name": "产品", "schedule": "0 18 * * *", "keyword": "#纪要", "provider": "onprem", "max_buffer_size": 0}
+]`
+	if err := os.WriteFile(groupsFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadGroups(); err != nil {
+		t.Fatalf("LoadGroups() failed: %v", err)
+	}
+
+	if got := strings.Join(GetTargetGroups(), "|"); got != "闲聊|研发|产品" {
+		t.Errorf("GetTargetGroups() = %q", got)
+	}
+
+	dev := ForGroup("后端研发群")
+	if dev.SummaryTrigger.MinMessagesForSummary != 20 || dev.LLMModel != "big-model" {
+		t.Errorf("Overrides not applied: min=%d model=%q", dev.SummaryTrigger.MinMessagesForSummary, dev.LLMModel)
+	}
+	if strings.Join(dev.Delivery.Targets, "|") != "dir:minutes" {
+		t.Errorf("groups.json delivery should win over DELIVERY_ROUTES, got %v", dev.Delivery.Targets)
+	}
+	if dev.MediaSupport.VideoEnabled || !dev.MediaSupport.ImageEnabled {
+		t.Errorf("Media override wrong: %+v", dev.MediaSupport)
+	}
+
+	product := ForGroup("产品周会")
+	if product.SummaryTrigger.Schedule != "0 18 * * *" || product.SummaryTrigger.Keyword != "#纪要" {
+		t.Errorf("Overrides not applied: %+v", product.SummaryTrigger)
+	}
+	if product.MaxBufferSize != GetConfig().MaxBufferSize {
+		t.Errorf("Non-positive max_buffer_size should be ignored, got %d", product.MaxBufferSize)
+	}
+	pc, ok := product.NamedProvider(product.LLMGroupProvider)
+	if !ok || pc.Provider != "ollama" || pc.Model != "qwen3" || pc.BaseURL != "http://localhost:11434" {
+		t.Errorf("NamedProvider(%q) = %+v, %v", product.LLMGroupProvider, pc, ok)
+	}
+
+	chat := ForGroup("闲聊群")
+	if chat.LLMModel != "base-model" || chat.LLMGroupProvider != "" || chat.SummaryTrigger.MinMessagesForSummary != GetConfig().SummaryTrigger.MinMessagesForSummary {
+		t.Errorf("Plain entry should use globals, got model=%q", chat.LLMModel)
+	}
+	if GetConfig().LLMModel != "base-model" {
+		t.Error("ForGroup must not modify the global config")
+	}
+
+	// Saving a new selection keeps the overrides of groups that remain
+	if err := SaveGroups([]string{"研发", "新群"}); err != nil {
+		t.Fatalf("SaveGroups() failed: %v", err)
+	}
+	if err := LoadGroups(); err != nil {
+		t.Fatalf("LoadGroups() failed: %v", err)
+	}
+	if ForGroup("研发").LLMModel != "big-model" {
+		t.Error("SaveGroups dropped the overrides of a kept group")
+	}
+	saved, _ := os.ReadFile(groupsFile)
+	if !strings.Contains(string(saved), `"新群"`) || strings.Contains(string(saved), `"name": "新群"`) {
+		t.Errorf("Entries without overrides should be saved as plain strings:\n%s", saved)
+	}
+}
+
+func TestParsePrices(t *testing.T) {
+	cfg := UsageConfig{Prices: parsePrices("gpt-4o=2.50/10/1.25;gpt-4o-mini=0.15/0.60;bad=1;gemini=x/1")}
+	if len(cfg.Prices) != 2 {
+		t.Fatalf("parsePrices() = %+v, want 2 valid prices", cfg.Prices)
+	}
+
+	tests := []struct {
+		model string
+		input float64
+		ok    bool
+	}{
+		{"gpt-4o-2024-08-06", 2.50, true},
+		{"gpt-4o-mini", 0.15, true},
+		{"gemini-2.5-flash", 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := cfg.PriceFor(tt.model)
+		if ok != tt.ok || price.Input != tt.input {
+			t.Errorf("PriceFor(%q) = %+v, %v; want input %v, %v", tt.model, price, ok, tt.input, tt.ok)
+		}
+	}
+}
+
+func TestCaptionProvider(t *testing.T) {
+	os.Setenv("LLM_API_KEY", "test-key")
+	os.Setenv("LLM_PROVIDERS", "vision")
+	os.Setenv("LLM_VISION_PROVIDER", "ollama")
+	os.Setenv("LLM_VISION_MODEL", "qwen2.5vl:7b")
+	os.Setenv("CAPTION_PROVIDER", "vision")
+	os.Setenv("CAPTION_MODEL", "qwen2.5vl:3b")
+	defer func() {
+		for _, k := range []string{"LLM_API_KEY", "LLM_PROVIDERS", "LLM_VISION_PROVIDER", "LLM_VISION_MODEL", "CAPTION_PROVIDER", "CAPTION_MODEL"} {
+			os.Unsetenv(k)
+		}
+	}()
+	if err := Parse(); err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	pc, ok := GetConfig().CaptionProvider()
+	if !ok || pc.Model != "qwen2.5vl:3b" || !pc.IsLocal() {
+		t.Errorf("CaptionProvider() = %+v, %v", pc, ok)
+	}
+
+	os.Setenv("CAPTION_PROVIDER", "missing")
+	if err := Parse(); err == nil {
+		t.Error("Parse() should reject a caption provider missing from LLM_PROVIDERS and LLM_FALLBACKS")
+	}
+}
+
+func TestOnPremOnly(t *testing.T) {
+	cfg := &Config{LLMProviders: []ProviderConfig{
+		{Name: "onprem", Provider: "ollama"},
+		{Name: "cloud", Provider: "openai"},
+	}}
+	for provider, want := range map[string]bool{"": false, "onprem": true, "cloud": false, "missing": true} {
+		cfg.LLMGroupProvider = provider
+		if got := cfg.OnPremOnly(); got != want {
+			t.Errorf("OnPremOnly() with provider %q = %v, want %v", provider, got, want)
+		}
+	}
+}
+
+func TestTranscribeLocal(t *testing.T) {
+	os.Setenv("LLM_API_KEY", "test-key")
+	defer func() {
+		os.Unsetenv("LLM_API_KEY")
+		os.Unsetenv("TRANSCRIBE_PROVIDER")
+		os.Unsetenv("TRANSCRIBE_BASE_URL")
+		os.Unsetenv("TRANSCRIBE_LOCAL")
+	}()
+
+	tests := []struct {
+		provider, baseURL, local string
+		want                     bool
+	}{
+		{provider: "whisper", want: true},
+		{provider: "whisper", baseURL: "https://whisper.example.com/v1"},
+		{provider: "openai"},
+		{provider: "openai", baseURL: "http://10.0.0.5:8000/v1", want: true},
+		{provider: "openai", baseURL: "http://127.0.0.1:8000/v1", want: true},
+		{provider: "openai", baseURL: "https://asr.corp.example.com/v1", local: "true", want: true},
+		{provider: "whisper", local: "false"},
+		{provider: "gemini"},
+	}
+	for _, tt := range tests {
+		os.Setenv("TRANSCRIBE_PROVIDER", tt.provider)
+		os.Setenv("TRANSCRIBE_BASE_URL", tt.baseURL)
+		os.Setenv("TRANSCRIBE_LOCAL", tt.local)
+		if err := Parse(); err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+		if got := GetConfig().Transcribe.Local; got != tt.want {
+			t.Errorf("%s at %q with TRANSCRIBE_LOCAL=%q: Local = %v, want %v", tt.provider, tt.baseURL, tt.local, got, tt.want)
+		}
+	}
+}
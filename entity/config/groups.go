@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// GroupConfig is one entry of groups.json. Name selects groups by
+// case-insensitive substring; every other field overrides the matching
+// global setting when present. A plain string entry is shorthand for
+// {"name": "..."}, so the original flat array format still loads.
+type GroupConfig struct {
+	Name             string         `json:"name"`
+	IntervalMinutes  *int           `json:"interval_minutes,omitempty"`
+	MessageCount     *int           `json:"message_count,omitempty"`
+	IdleMinutes      *int           `json:"idle_minutes,omitempty"`
+	MinMessages      *int           `json:"min_messages,omitempty"`
+	Keyword          *string        `json:"keyword,omitempty"`
+	Schedule         *string        `json:"schedule,omitempty"`
+	Rolling          *bool          `json:"rolling,omitempty"`
+	Media            *MediaOverride `json:"media,omitempty"`
+	SystemPromptFile *string        `json:"system_prompt_file,omitempty"`
+	Model            *string        `json:"model,omitempty"`
+	Provider         *string        `json:"provider,omitempty"`
+	Delivery         []string       `json:"delivery,omitempty"`
+	MaxBufferSize    *int           `json:"max_buffer_size,omitempty"`
+	DailyBudget      *float64       `json:"daily_budget,omitempty"`
+	BudgetAction     *string        `json:"budget_action,omitempty"`
+}
+
+type MediaOverride struct {
+	Image *bool `json:"image,omitempty"`
+	Video *bool `json:"video,omitempty"`
+	Audio *bool `json:"audio,omitempty"`
+	PDF   *bool `json:"pdf,omitempty"`
+}
+
+func (g *GroupConfig) UnmarshalJSON(data []byte) error {
+	if len(bytes.TrimSpace(data)) > 0 && bytes.TrimSpace(data)[0] == '"' {
+		*g = GroupConfig{}
+		return json.Unmarshal(data, &g.Name)
+	}
+
+	type plain GroupConfig
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*g = GroupConfig(p)
+	return nil
+}
+
+// MarshalJSON writes entries without overrides as plain strings, so files
+// that never used overrides keep their original shape.
+func (g GroupConfig) MarshalJSON() ([]byte, error) {
+	if !g.hasOverrides() {
+		return json.Marshal(g.Name)
+	}
+	type plain GroupConfig
+	return json.Marshal(plain(g))
+}
+
+func (g GroupConfig) hasOverrides() bool {
+	return g.IntervalMinutes != nil || g.MessageCount != nil || g.IdleMinutes != nil || g.MinMessages != nil ||
+		g.Keyword != nil || g.Schedule != nil || g.Rolling != nil || g.Media != nil || g.SystemPromptFile != nil ||
+		g.Model != nil || g.Provider != nil || g.Delivery != nil || g.MaxBufferSize != nil || g.DailyBudget != nil || g.BudgetAction != nil
+}
+
+// warnIgnored logs the overrides that apply skips because they are invalid.
+func (g GroupConfig) warnIgnored() {
+	if g.MaxBufferSize != nil && *g.MaxBufferSize <= 0 {
+		logging.Warn("Ignoring non-positive max_buffer_size in groups.json",
+			zap.String("group", g.Name),
+			zap.Int("maxBufferSize", *g.MaxBufferSize))
+	}
+}
+
+func (g GroupConfig) apply(cfg *Config) {
+	if g.IntervalMinutes != nil {
+		cfg.SummaryTrigger.IntervalMinutes = *g.IntervalMinutes
+	}
+	if g.MessageCount != nil {
+		cfg.SummaryTrigger.MessageCount = *g.MessageCount
+	}
+	if g.IdleMinutes != nil {
+		cfg.SummaryTrigger.IdleMinutes = *g.IdleMinutes
+	}
+	if g.MinMessages != nil {
+		cfg.SummaryTrigger.MinMessagesForSummary = *g.MinMessages
+	}
+	if g.Keyword != nil {
+		cfg.SummaryTrigger.Keyword = *g.Keyword
+	}
+	if g.Schedule != nil {
+		cfg.SummaryTrigger.Schedule = *g.Schedule
+	}
+	if g.Rolling != nil {
+		cfg.SummaryTrigger.RollingEnabled = *g.Rolling
+	}
+	if m := g.Media; m != nil {
+		if m.Image != nil {
+			cfg.MediaSupport.ImageEnabled = *m.Image
+		}
+		if m.Video != nil {
+			cfg.MediaSupport.VideoEnabled = *m.Video
+		}
+		if m.Audio != nil {
+			cfg.MediaSupport.AudioEnabled = *m.Audio
+		}
+		if m.PDF != nil {
+			cfg.MediaSupport.PDFEnabled = *m.PDF
+		}
+	}
+	if g.SystemPromptFile != nil {
+		cfg.SystemPromptFile = *g.SystemPromptFile
+	}
+	if g.Model != nil {
+		cfg.LLMModel = *g.Model
+	}
+	if g.Provider != nil {
+		cfg.LLMGroupProvider = *g.Provider
+	}
+	if g.Delivery != nil {
+		cfg.Delivery.Targets = g.Delivery
+	}
+	if g.MaxBufferSize != nil && *g.MaxBufferSize > 0 {
+		cfg.MaxBufferSize = *g.MaxBufferSize
+	}
+	if g.DailyBudget != nil {
+		cfg.Usage.DailyBudget = *g.DailyBudget
+	}
+	if g.BudgetAction != nil {
+		cfg.Usage.BudgetAction = *g.BudgetAction
+	}
+}
+
+// GetGroupConfigs returns the entries of groups.json.
+func GetGroupConfigs() []GroupConfig {
+	groups := groupConfigs.Load()
+	if groups == nil {
+		return nil
+	}
+	return *groups
+}
+
+// ForGroup returns the effective config of a group: the global config with
+// DELIVERY_ROUTES, SUMMARY_SCHEDULES and USAGE_DAILY_BUDGETS resolved for it, then overridden by
+// the first matching groups.json entry. The result is a copy and must not be
+// stored, since it does not follow hot reloads.
+func ForGroup(groupTopic string) *Config {
+	base := GetConfig()
+	cfg := *base
+
+	if rule, ok := matchRule(base.Delivery.Routes, groupTopic); ok {
+		cfg.Delivery.Targets = splitList(rule.Value)
+	}
+	if rule, ok := matchRule(base.SummaryTrigger.Schedules, groupTopic); ok {
+		cfg.SummaryTrigger.Schedule = rule.Value
+	}
+	if rule, ok := matchRule(base.Usage.DailyBudgets, groupTopic); ok {
+		if budget, err := strconv.ParseFloat(rule.Value, 64); err == nil {
+			cfg.Usage.DailyBudget = budget
+		}
+	}
+
+	for _, group := range GetGroupConfigs() {
+		if MatchGroup(group.Name, groupTopic) {
+			group.apply(&cfg)
+			break
+		}
+	}
+	return &cfg
+}
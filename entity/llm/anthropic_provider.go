@@ -0,0 +1,296 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	anthropicVersion        = "2023-06-01"
+	anthropicDefaultBaseURL = "https://api.anthropic.com"
+	anthropicTimeout        = 5 * time.Minute
+)
+
+// AnthropicProvider talks to the Anthropic Messages API over plain HTTP.
+type AnthropicProvider struct {
+	apiKey    string
+	baseURL   string
+	model     string
+	maxTokens int
+	client    *http.Client
+	log       *zap.Logger
+}
+
+type AnthropicConfig struct {
+	APIKey    string
+	BaseURL   string
+	Model     string
+	MaxTokens int
+}
+
+type anthropicRequest struct {
+	Model      string               `json:"model"`
+	MaxTokens  int                  `json:"max_tokens"`
+	System     string               `json:"system,omitempty"`
+	Messages   []anthropicMessage   `json:"messages"`
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type   string           `json:"type"`
+	Text   string           `json:"text,omitempty"`
+	Source *anthropicSource `json:"source,omitempty"`
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens              int `json:"input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+}
+
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func NewAnthropicProvider(cfg AnthropicConfig) *AnthropicProvider {
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = anthropicDefaultBaseURL
+	}
+
+	p := &AnthropicProvider{
+		apiKey:    cfg.APIKey,
+		baseURL:   baseURL,
+		model:     cfg.Model,
+		maxTokens: cfg.MaxTokens,
+		client:    &http.Client{Timeout: anthropicTimeout},
+		log:       logging.Named("anthropic"),
+	}
+
+	p.log.Info("Anthropic provider initialized",
+		zap.String("model", cfg.Model),
+		zap.String("baseURL", baseURL))
+
+	return p
+}
+
+// Capabilities assumes a 200K context. Media is sent base64-encoded within
+// the API's 32MB request limit, and each image within its 5MB limit, which
+// PDFs are held to as well.
+func (p *AnthropicProvider) Capabilities() Capabilities {
+	return Capabilities{
+		MimeTypes:       []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf"},
+		MaxInlineBytes:  5 << 20,
+		MaxRequestBytes: 20 << 20,
+		ContextTokens:   150_000,
+	}
+}
+
+func (p *AnthropicProvider) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	resp, err := p.send(ctx, p.newRequest(systemPrompt, contents))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	text := sb.String()
+	p.log.Debug("Response received", zap.Int("length", len(text)), zap.String("stopReason", resp.StopReason))
+
+	return text, nil
+}
+
+// GenerateStructured forces a call to a tool whose input schema is schema;
+// the tool input is the JSON document.
+func (p *AnthropicProvider) GenerateStructured(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
+	req := p.newRequest(systemPrompt, contents)
+	req.Tools = []anthropicTool{{
+		Name:        schema.Name,
+		Description: schema.Description,
+		InputSchema: schema.JSONSchema(),
+	}}
+	req.ToolChoice = &anthropicToolChoice{Type: "tool", Name: schema.Name}
+
+	resp, err := p.send(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	for _, block := range resp.Content {
+		if block.Type == "tool_use" && block.Name == schema.Name {
+			p.log.Debug("Response received", zap.Int("length", len(block.Input)), zap.String("stopReason", resp.StopReason))
+			return string(block.Input), nil
+		}
+	}
+	return "", fmt.Errorf("no %s tool call in Anthropic response", schema.Name)
+}
+
+func (p *AnthropicProvider) newRequest(systemPrompt string, contents []*chat.Content) *anthropicRequest {
+	return &anthropicRequest{
+		Model:     p.model,
+		MaxTokens: p.maxTokens,
+		System:    systemPrompt,
+		Messages: []anthropicMessage{{
+			Role:    "user",
+			Content: p.buildBlocks(contents),
+		}},
+	}
+}
+
+func (p *AnthropicProvider) send(ctx context.Context, req *anthropicRequest) (*anthropicResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Anthropic request: %w", err)
+	}
+
+	p.log.Debug("Sending request to Anthropic",
+		zap.String("model", p.model),
+		zap.Int("blocks", len(req.Messages[0].Content)),
+		zap.Bool("structured", req.ToolChoice != nil))
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Anthropic request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		p.log.Error("Anthropic API error", zap.Error(err))
+		return nil, newAPIError("Anthropic", 0, nil, err)
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Anthropic response: %w", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		var body anthropicError
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+			message = fmt.Sprintf("%s: %s", body.Error.Type, body.Error.Message)
+		}
+		p.log.Error("Anthropic API error", zap.Int("status", httpResp.StatusCode), zap.String("error", message))
+
+		apiErr := newAPIError("Anthropic", httpResp.StatusCode, httpResp.Header, errors.New(message))
+		// An exhausted credit balance is reported as an invalid request
+		if body.Error.Type == "billing_error" || strings.Contains(body.Error.Message, "credit balance") {
+			apiErr.Kind = ErrQuotaExhausted
+		}
+		return nil, apiErr
+	}
+
+	var resp anthropicResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse Anthropic response: %w", err)
+	}
+	// input_tokens excludes the tokens read from or written to the cache
+	u := resp.Usage
+	recordUsage(ctx, Usage{
+		Model:            p.model,
+		PromptTokens:     u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens,
+		CompletionTokens: u.OutputTokens,
+		CachedTokens:     u.CacheReadInputTokens,
+	})
+	if resp.StopReason == "max_tokens" {
+		p.log.Warn("Anthropic response truncated at max_tokens", zap.Int("maxTokens", p.maxTokens))
+	}
+	return &resp, nil
+}
+
+func (p *AnthropicProvider) buildBlocks(contents []*chat.Content) []anthropicBlock {
+	var blocks []anthropicBlock
+
+	for _, c := range contents {
+		switch c.Type {
+		case chat.ContentTypeText:
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: c.Text})
+
+		case chat.ContentTypeImage:
+			if len(c.Data) > 0 {
+				blocks = append(blocks, anthropicBlock{Type: "image", Source: base64Source(c.MimeType, c.Data)})
+				p.log.Debug("Added image block", zap.Int("size", len(c.Data)))
+			} else {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: c.Description()})
+			}
+
+		case chat.ContentTypePDF:
+			if len(c.Data) > 0 {
+				blocks = append(blocks, anthropicBlock{Type: "document", Source: base64Source("application/pdf", c.Data)})
+				p.log.Debug("Added PDF block", zap.Int("size", len(c.Data)))
+			} else {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: c.Description()})
+			}
+
+		case chat.ContentTypeVideo, chat.ContentTypeAudio, chat.ContentTypeFile:
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: c.Description()})
+			p.log.Debug("Media not supported by Anthropic, using placeholder", zap.String("type", string(c.Type)))
+		}
+	}
+
+	return blocks
+}
+
+func base64Source(mediaType string, data []byte) *anthropicSource {
+	return &anthropicSource{
+		Type:      "base64",
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}
+}
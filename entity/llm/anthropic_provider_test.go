@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+)
+
+func newAnthropicServer(t *testing.T, handler func(req anthropicRequest) (int, string)) (*AnthropicProvider, *[]anthropicRequest) {
+	t.Helper()
+	var received []anthropicRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("path = %s, want /v1/messages", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != anthropicVersion {
+			t.Errorf("missing auth headers: %v", r.Header)
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		received = append(received, req)
+		status, body := handler(req)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	p := NewAnthropicProvider(AnthropicConfig{
+		APIKey:    "test-key",
+		BaseURL:   srv.URL + "/",
+		Model:     "claude-test",
+		MaxTokens: 1024,
+	})
+	return p, &received
+}
+
+func TestAnthropicGenerateContent(t *testing.T) {
+	p, received := newAnthropicServer(t, func(anthropicRequest) (int, string) {
+		return http.StatusOK, `{"content":[{"type":"text","text":"纪要"},{"type":"text","text":"完成"}],"stop_reason":"end_turn",
+			"usage":{"input_tokens":100,"output_tokens":20,"cache_read_input_tokens":50}}`
+	})
+
+	contents := []*chat.Content{
+		{Type: chat.ContentTypeText, Text: "[10:00] 张三: 看图"},
+		{Type: chat.ContentTypeImage, Data: []byte("png"), MimeType: "image/png"},
+		{Type: chat.ContentTypePDF, Data: []byte("%PDF"), MimeType: "application/pdf"},
+		{Type: chat.ContentTypeVideo, Data: []byte("mp4"), MimeType: "video/mp4"},
+	}
+	ctx, collector := withUsage(context.Background())
+	text, err := p.GenerateContent(ctx, "system", contents)
+	if err != nil {
+		t.Fatalf("GenerateContent() error = %v", err)
+	}
+	if text != "纪要完成" {
+		t.Errorf("GenerateContent() = %q, want %q", text, "纪要完成")
+	}
+	want := Usage{Model: "claude-test", Requests: 1, PromptTokens: 150, CompletionTokens: 20, CachedTokens: 50}
+	if used := collector.usage(); len(used) != 1 || used[0] != want {
+		t.Errorf("usage = %+v, want %+v", used, want)
+	}
+
+	req := (*received)[0]
+	if req.Model != "claude-test" || req.MaxTokens != 1024 || req.System != "system" {
+		t.Errorf("request = %+v", req)
+	}
+	blocks := req.Messages[0].Content
+	var types []string
+	for _, b := range blocks {
+		types = append(types, b.Type)
+	}
+	if got := strings.Join(types, ","); got != "text,image,document,text" {
+		t.Fatalf("block types = %s", got)
+	}
+	if src := blocks[1].Source; src.Type != "base64" || src.MediaType != "image/png" || src.Data != "cG5n" {
+		t.Errorf("image source = %+v", src)
+	}
+	if src := blocks[2].Source; src.MediaType != "application/pdf" {
+		t.Errorf("document source = %+v", src)
+	}
+	if blocks[3].Text != contents[3].Description() {
+		t.Errorf("video placeholder = %q", blocks[3].Text)
+	}
+}
+
+func TestAnthropicGenerateStructured(t *testing.T) {
+	p, received := newAnthropicServer(t, func(anthropicRequest) (int, string) {
+		return http.StatusOK, `{"content":[{"type":"tool_use","id":"t1","name":"minutes","input":{"has_update":true,"key_points":["发布"]}}],"stop_reason":"tool_use"}`
+	})
+
+	text, err := p.GenerateStructured(context.Background(), "system", nil, MinutesSchema)
+	if err != nil {
+		t.Fatalf("GenerateStructured() error = %v", err)
+	}
+	m, err := ParseMinutes(text)
+	if err != nil || !m.HasUpdate || len(m.KeyPoints) != 1 {
+		t.Errorf("ParseMinutes(%q) = %+v, %v", text, m, err)
+	}
+
+	req := (*received)[0]
+	if len(req.Tools) != 1 || req.Tools[0].Name != MinutesSchema.Name || req.ToolChoice.Name != MinutesSchema.Name {
+		t.Errorf("tools = %+v, choice = %+v", req.Tools, req.ToolChoice)
+	}
+}
+
+func TestAnthropicError(t *testing.T) {
+	p, _ := newAnthropicServer(t, func(anthropicRequest) (int, string) {
+		return http.StatusTooManyRequests, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`
+	})
+
+	_, err := p.GenerateContent(context.Background(), "system", nil)
+	if err == nil || !strings.Contains(err.Error(), "429") || !strings.Contains(err.Error(), "rate_limit_error: slow down") {
+		t.Errorf("GenerateContent() error = %v", err)
+	}
+	if kind := ErrorKindOf(err); kind != ErrRateLimited {
+		t.Errorf("ErrorKindOf() = %v, want %v", kind, ErrRateLimited)
+	}
+}
@@ -0,0 +1,188 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"slices"
+	"strings"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Capabilities describes what a provider accepts in one request. Content
+// outside them is converted or replaced by a placeholder before it reaches
+// the provider.
+type Capabilities struct {
+	MimeTypes       []string // accepted media types; "video/*" accepts any video
+	MaxInlineBytes  int64    // largest single media part
+	MaxRequestBytes int64    // all media parts of one request
+	ContextTokens   int      // estimated input tokens of one request, leaving room for the response
+}
+
+// Accepts reports whether media of mimeType can be sent inline.
+func (c Capabilities) Accepts(mimeType string) bool {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	for _, t := range c.MimeTypes {
+		if t == mimeType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "*"); ok && strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// degradeReport counts the media parts degraded for one request.
+type degradeReport struct {
+	converted   []string // MIME types converted to PNG
+	unsupported []string // MIME types replaced by placeholders
+	oversized   int
+}
+
+func (r *degradeReport) empty() bool {
+	return len(r.converted) == 0 && len(r.unsupported) == 0 && r.oversized == 0
+}
+
+// degrade returns contents with every media part fitted to caps: images in
+// a format the provider cannot read are converted to PNG where possible, and
+// other unsupported or oversized media is replaced by its placeholder.
+// contents itself is not modified.
+func degrade(contents []*chat.Content, caps Capabilities) ([]*chat.Content, degradeReport) {
+	var report degradeReport
+	out := make([]*chat.Content, len(contents))
+	var total int64
+	for i, c := range contents {
+		out[i] = c
+		if !c.IsMedia() || len(c.Data) == 0 {
+			continue
+		}
+
+		part := c
+		if !caps.Accepts(c.MimeType) {
+			if converted, ok := toPNG(c, caps); ok {
+				part = converted
+				report.converted = append(report.converted, c.MimeType)
+			} else {
+				out[i] = placeholder(c)
+				report.unsupported = append(report.unsupported, c.MimeType)
+				continue
+			}
+		}
+
+		size := int64(len(part.Data))
+		if (caps.MaxInlineBytes > 0 && size > caps.MaxInlineBytes) ||
+			(caps.MaxRequestBytes > 0 && total+size > caps.MaxRequestBytes) {
+			out[i] = placeholder(c)
+			report.oversized++
+			continue
+		}
+		total += size
+		out[i] = part
+	}
+	return out, report
+}
+
+// TextOnly returns contents with every media part replaced by its
+// placeholder.
+func TextOnly(contents []*chat.Content) []*chat.Content {
+	out := make([]*chat.Content, len(contents))
+	for i, c := range contents {
+		out[i] = c
+		if c.IsMedia() {
+			out[i] = placeholder(c)
+		}
+	}
+	return out
+}
+
+func hasMedia(contents []*chat.Content) bool {
+	return slices.ContainsFunc(contents, (*chat.Content).IsMedia)
+}
+
+func placeholder(c *chat.Content) *chat.Content {
+	return &chat.Content{Type: chat.ContentTypeText, Text: c.Description()}
+}
+
+// toPNG re-encodes an image the standard library can decode, such as a GIF
+// for a provider that only reads PNG and JPEG. Animated images keep their
+// first frame.
+func toPNG(c *chat.Content, caps Capabilities) (*chat.Content, bool) {
+	if c.Type != chat.ContentTypeImage || !caps.Accepts("image/png") {
+		return nil, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(c.Data))
+	if err != nil {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, false
+	}
+	return &chat.Content{Type: chat.ContentTypeImage, Data: buf.Bytes(), MimeType: "image/png", FileName: c.FileName}, true
+}
+
+// capableProvider fits requests to the capabilities of the provider it
+// wraps, and retries a request the provider rejected without its media.
+type capableProvider struct {
+	Provider
+	name string
+}
+
+// capableStructuredProvider is a capableProvider for a StructuredProvider.
+type capableStructuredProvider struct {
+	*capableProvider
+	structured StructuredProvider
+}
+
+// withCapabilities wraps p so that content is degraded to what p accepts,
+// keeping p's optional interfaces.
+func withCapabilities(p Provider, name string) Provider {
+	c := &capableProvider{Provider: p, name: name}
+	if sp, ok := p.(StructuredProvider); ok {
+		return &capableStructuredProvider{capableProvider: c, structured: sp}
+	}
+	return c
+}
+
+func (c *capableProvider) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	return c.generate(ctx, contents, func(contents []*chat.Content) (string, error) {
+		return c.Provider.GenerateContent(ctx, systemPrompt, contents)
+	})
+}
+
+func (c *capableStructuredProvider) GenerateStructured(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
+	return c.generate(ctx, contents, func(contents []*chat.Content) (string, error) {
+		return c.structured.GenerateStructured(ctx, systemPrompt, contents, schema)
+	})
+}
+
+// generate sends the degraded contents, and once more as text alone if the
+// provider rejected a request that carried media.
+func (c *capableProvider) generate(ctx context.Context, contents []*chat.Content, send func([]*chat.Content) (string, error)) (string, error) {
+	degraded, report := degrade(contents, c.Capabilities())
+	if !report.empty() {
+		logging.Info("Media degraded for provider",
+			zap.String("provider", c.name),
+			zap.Strings("converted", report.converted),
+			zap.Strings("unsupported", report.unsupported),
+			zap.Int("oversized", report.oversized))
+	}
+
+	text, err := send(degraded)
+	if err == nil || ctx.Err() != nil || ErrorKindOf(err) != ErrBadRequest || !hasMedia(degraded) {
+		return text, err
+	}
+
+	logging.Warn("Provider rejected media, retrying as text only",
+		zap.String("provider", c.name),
+		zap.Error(err))
+	return send(TextOnly(degraded))
+}
@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+)
+
+func TestCapabilitiesAccepts(t *testing.T) {
+	caps := Capabilities{MimeTypes: []string{"image/png", "video/*"}}
+	tests := []struct {
+		mimeType string
+		want     bool
+	}{
+		{"image/png", true},
+		{"IMAGE/PNG", true},
+		{"video/mp4", true},
+		{"video/quicktime; codecs=avc1", true},
+		{"image/gif", false},
+		{"audio/amr", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := caps.Accepts(tt.mimeType); got != tt.want {
+			t.Errorf("Accepts(%q) = %v, want %v", tt.mimeType, got, tt.want)
+		}
+	}
+}
+
+func TestDegrade(t *testing.T) {
+	var gifData bytes.Buffer
+	if err := gif.Encode(&gifData, image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Black}), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	voice := &chat.Content{Type: chat.ContentTypeAudio, Data: []byte("#!AMR"), MimeType: "audio/amr"}
+	contents := []*chat.Content{
+		{Type: chat.ContentTypeText, Text: "[10:00] 张三: 看看"},
+		{Type: chat.ContentTypeImage, Data: gifData.Bytes(), MimeType: "image/gif"},
+		voice,
+		{Type: chat.ContentTypeImage, Data: bytes.Repeat([]byte{1}, 200), MimeType: "image/jpeg"},
+		{Type: chat.ContentTypeImage, Data: []byte{1}, MimeType: "image/jpeg"},
+	}
+	caps := Capabilities{MimeTypes: []string{"image/png", "image/jpeg"}, MaxInlineBytes: 150, MaxRequestBytes: 1 << 20}
+
+	out, report := degrade(contents, caps)
+	if out[0] != contents[0] || out[4] != contents[4] {
+		t.Error("supported parts should be passed through")
+	}
+	if out[1].MimeType != "image/png" || !bytes.HasPrefix(out[1].Data, []byte("\x89PNG")) {
+		t.Errorf("GIF not converted: %q", out[1].MimeType)
+	}
+	if out[2].Type != chat.ContentTypeText || out[2].Text != voice.Description() {
+		t.Errorf("unsupported audio = %+v", out[2])
+	}
+	if out[3].Type != chat.ContentTypeText {
+		t.Errorf("oversized image = %+v", out[3])
+	}
+	if len(report.converted) != 1 || len(report.unsupported) != 1 || report.oversized != 1 {
+		t.Errorf("report = %+v", report)
+	}
+	if contents[2] != voice || voice.Type != chat.ContentTypeAudio {
+		t.Error("degrade must not modify its input")
+	}
+
+	// The request total is enforced in order
+	_, report = degrade(contents[3:], Capabilities{MimeTypes: []string{"image/*"}, MaxRequestBytes: 100})
+	if report.oversized != 1 {
+		t.Errorf("request limit: report = %+v", report)
+	}
+}
+
+// rejectingProvider rejects requests that carry media.
+type rejectingProvider struct {
+	requests [][]*chat.Content
+}
+
+func (r *rejectingProvider) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	r.requests = append(r.requests, contents)
+	if hasMedia(contents) {
+		return "", &APIError{Provider: "test", Kind: ErrBadRequest, StatusCode: 400, Err: errors.New("invalid image")}
+	}
+	return "text minutes", nil
+}
+
+func (r *rejectingProvider) Capabilities() Capabilities {
+	return Capabilities{MimeTypes: []string{"image/*"}}
+}
+
+func TestCapableProviderTextOnlyRetry(t *testing.T) {
+	inner := &rejectingProvider{}
+	p := withCapabilities(inner, "test")
+
+	contents := []*chat.Content{
+		{Type: chat.ContentTypeText, Text: "[10:00] 张三: 截图"},
+		{Type: chat.ContentTypeImage, Data: []byte("png"), MimeType: "image/png"},
+	}
+	text, err := p.GenerateContent(context.Background(), "system", contents)
+	if err != nil || text != "text minutes" {
+		t.Fatalf("GenerateContent() = %q, %v", text, err)
+	}
+	if len(inner.requests) != 2 || !hasMedia(inner.requests[0]) || hasMedia(inner.requests[1]) {
+		t.Errorf("requests = %d, want one with media and one without", len(inner.requests))
+	}
+
+	// Errors other than a rejected request are returned as they are
+	inner.requests = nil
+	down := withCapabilities(&MockProvider{MockError: &APIError{Kind: ErrUnavailable, Err: errors.New("down")}, Caps: inner.Capabilities()}, "down")
+	if _, err := down.GenerateContent(context.Background(), "system", contents); ErrorKindOf(err) != ErrUnavailable {
+		t.Errorf("GenerateContent() error = %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// ErrUnsupportedImage is returned for images in a format the caption model
+// cannot read.
+var ErrUnsupportedImage = errors.New("image format not supported")
+
+// Caption is what a vision model saw in an image.
+type Caption struct {
+	Description string `json:"caption"`
+	Text        string `json:"text"` // text read from the image, empty if none
+}
+
+// Captioner describes images for models that cannot see them.
+type Captioner interface {
+	Caption(ctx context.Context, image *chat.Content) (Caption, error)
+}
+
+// CaptionSchema constrains caption responses to Caption.
+var CaptionSchema = &Schema{
+	Name:        "caption",
+	Type:        SchemaObject,
+	Description: "图片描述",
+	Properties: []Property{
+		{"caption", stringSchema("图片内容的简要描述")},
+		{"text", stringSchema("图片中的全部文字，没有则为空")},
+	},
+}
+
+const captionSystemPrompt = `你在为群聊记录描述图片，描述会代替图片交给看不到图片的模型做会议纪要。
+用一两句话说明图片内容及其在讨论中可能的用途，例如"某页面的报错截图"、"排期表"；截图和文档要写明是什么界面或文件。
+逐字摘录图片中的文字，保留换行，不要翻译或总结；没有文字时留空。
+以 JSON 输出：{"caption": "描述", "text": "图中文字"}。`
+
+// NewCaptioner creates the captioner selected by cfg.Caption, retrying
+// transient errors like summary requests do. It returns nil when captioning
+// is off.
+func NewCaptioner(cfg *config.Config) (Captioner, error) {
+	pc, ok := cfg.CaptionProvider()
+	if !ok {
+		return nil, nil
+	}
+	policy := retryPolicyFor(cfg)
+	policy.timeout = time.Duration(cfg.Caption.TimeoutSeconds) * time.Second
+
+	p, err := newProvider(pc, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create caption provider %s: %w", pc.Name, err)
+	}
+
+	logging.Info("Captioner initialized",
+		zap.String("provider", pc.Name),
+		zap.String("model", pc.Model))
+	return &providerCaptioner{provider: p}, nil
+}
+
+// providerCaptioner asks a vision model to describe the image.
+type providerCaptioner struct {
+	provider Provider
+}
+
+func (c *providerCaptioner) Caption(ctx context.Context, image *chat.Content) (Caption, error) {
+	// The provider would swap an image it cannot read for a placeholder
+	if fitted, _ := degrade([]*chat.Content{image}, c.provider.Capabilities()); !fitted[0].IsMedia() {
+		return Caption{}, fmt.Errorf("%w: %s", ErrUnsupportedImage, image.MimeType)
+	}
+
+	var text string
+	var err error
+	if sp, ok := c.provider.(StructuredProvider); ok {
+		text, err = sp.GenerateStructured(ctx, captionSystemPrompt, []*chat.Content{image}, CaptionSchema)
+	} else {
+		text, err = c.provider.GenerateContent(ctx, captionSystemPrompt, []*chat.Content{image})
+	}
+	if err != nil {
+		return Caption{}, err
+	}
+	return parseCaption(text), nil
+}
+
+// parseCaption reads a Caption from the model's JSON, tolerating code
+// fences. A reply that is not JSON is taken as the description.
+func parseCaption(text string) Caption {
+	text = strings.TrimSpace(text)
+	body := text
+	if strings.HasPrefix(body, "```") {
+		body = strings.TrimPrefix(body, "```json")
+		body = strings.TrimPrefix(body, "```")
+		body = strings.TrimSuffix(strings.TrimSpace(body), "```")
+	}
+
+	var caption Caption
+	if err := json.Unmarshal([]byte(body), &caption); err != nil {
+		return Caption{Description: text}
+	}
+	caption.Description = strings.TrimSpace(caption.Description)
+	caption.Text = strings.TrimSpace(caption.Text)
+	if caption.Description == "" && caption.Text != "" {
+		caption.Description = "含文字的图片"
+	}
+	return caption
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+)
+
+func TestParseCaption(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Caption
+	}{
+		{"json", `{"caption": "登录页报错截图", "text": "用户名或密码错误"}`, Caption{"登录页报错截图", "用户名或密码错误"}},
+		{"code fence", "```json\n{\"caption\": \"排期表\", \"text\": \"\"}\n```", Caption{Description: "排期表"}},
+		{"plain text", "一只猫", Caption{Description: "一只猫"}},
+		{"text only", `{"caption": "", "text": "v2 发布"}`, Caption{"含文字的图片", "v2 发布"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCaption(tt.text); got != tt.want {
+				t.Errorf("parseCaption() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderCaptioner(t *testing.T) {
+	mock := &MockProvider{
+		MockResponse: `{"caption": "登录页报错截图", "text": "用户名或密码错误"}`,
+		Caps:         Capabilities{MimeTypes: []string{"image/jpeg"}},
+	}
+	c := &providerCaptioner{provider: mock}
+
+	image := &chat.Content{Type: chat.ContentTypeImage, Data: []byte("jpeg"), MimeType: "image/jpeg"}
+	caption, err := c.Caption(context.Background(), image)
+	if err != nil || caption.Description != "登录页报错截图" || caption.Text != "用户名或密码错误" {
+		t.Errorf("Caption() = %+v, %v", caption, err)
+	}
+	if len(mock.LastContents) != 1 || mock.LastContents[0] != image {
+		t.Errorf("contents = %v", mock.LastContents)
+	}
+
+	webp := &chat.Content{Type: chat.ContentTypeImage, Data: []byte("RIFF"), MimeType: "image/webp"}
+	if _, err := c.Caption(context.Background(), webp); !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("Caption(webp) error = %v, want ErrUnsupportedImage", err)
+	}
+}
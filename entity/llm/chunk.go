@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"unicode"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+)
+
+// ChunkLimits bounds the messages sent in one request. Buffers that exceed
+// either limit are summarized in chunks and merged.
+type ChunkLimits struct {
+	MaxTokens int   // estimated input tokens
+	MaxBytes  int64 // inline media payload
+}
+
+// defaultChunkLimits apply to providers whose Capabilities leave a limit at
+// zero.
+var defaultChunkLimits = ChunkLimits{MaxTokens: 60_000, MaxBytes: 15 << 20}
+
+// Rough token costs of inline media; byte limits do most of the work there.
+var mediaTokens = map[chat.ContentType]int{
+	chat.ContentTypeImage: 1_000,
+	chat.ContentTypeAudio: 2_000,
+	chat.ContentTypeVideo: 10_000,
+	chat.ContentTypePDF:   5_000,
+}
+
+// limitsFor returns the chunk limits of a provider, with non-zero config
+// values taking precedence.
+func limitsFor(p Provider, maxTokens int, maxBytes int64) ChunkLimits {
+	limits := defaultChunkLimits
+	caps := p.Capabilities()
+	if caps.ContextTokens > 0 {
+		limits.MaxTokens = caps.ContextTokens
+	}
+	if caps.MaxRequestBytes > 0 {
+		limits.MaxBytes = caps.MaxRequestBytes
+	}
+	if maxTokens > 0 {
+		limits.MaxTokens = maxTokens
+	}
+	if maxBytes > 0 {
+		limits.MaxBytes = maxBytes
+	}
+	return limits
+}
+
+// estimateTokens approximates the token count of a content part: one token
+// per CJK character and one per four other characters.
+func estimateTokens(c *chat.Content) int {
+	if c.Type != chat.ContentTypeText {
+		if len(c.Data) > 0 {
+			return mediaTokens[c.Type]
+		}
+		return estimateTextTokens(c.Description())
+	}
+	return estimateTextTokens(c.Text)
+}
+
+func estimateTextTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// splitChunks packs content parts into chunks within limits. A chunk only
+// starts at a text part, so media always stays with the sender header that
+// precedes it. A single message too large for any chunk has its media
+// replaced by a text placeholder.
+func splitChunks(parts []*chat.Content, limits ChunkLimits) [][]*chat.Content {
+	var chunks [][]*chat.Content
+	var current []*chat.Content
+	var tokens int
+	var size int64
+
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, current)
+		}
+		current, tokens, size = nil, 0, 0
+	}
+
+	for _, unit := range messageUnits(parts) {
+		unitTokens, unitSize := measure(unit)
+		if unitTokens > limits.MaxTokens || unitSize > limits.MaxBytes {
+			unit = TextOnly(unit)
+			unitTokens, unitSize = measure(unit)
+		}
+		if len(current) > 0 && (tokens+unitTokens > limits.MaxTokens || size+unitSize > limits.MaxBytes) {
+			flush()
+		}
+		current = append(current, unit...)
+		tokens += unitTokens
+		size += unitSize
+	}
+	flush()
+	return chunks
+}
+
+// messageUnits groups parts into units that must not be split: a text part
+// and the media parts that follow it.
+func messageUnits(parts []*chat.Content) [][]*chat.Content {
+	var units [][]*chat.Content
+	for _, part := range parts {
+		if part.Type == chat.ContentTypeText || len(units) == 0 {
+			units = append(units, []*chat.Content{part})
+			continue
+		}
+		units[len(units)-1] = append(units[len(units)-1], part)
+	}
+	return units
+}
+
+func measure(parts []*chat.Content) (int, int64) {
+	var tokens int
+	var size int64
+	for _, part := range parts {
+		tokens += estimateTokens(part)
+		size += int64(len(part.Data))
+	}
+	return tokens, size
+}
@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"hello world", 3},
+		{"今天开会", 4},
+		{"[10:00] 张三: ok", 2 + 3},
+	}
+	for _, tt := range tests {
+		if got := estimateTextTokens(tt.text); got != tt.expected {
+			t.Errorf("estimateTextTokens(%q) = %d, want %d", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestSplitChunks(t *testing.T) {
+	text := func(s string) *chat.Content { return &chat.Content{Type: chat.ContentTypeText, Text: s} }
+	image := func(size int) *chat.Content {
+		return &chat.Content{Type: chat.ContentTypeImage, MimeType: "image/png", Data: make([]byte, size)}
+	}
+
+	parts := []*chat.Content{
+		text(strings.Repeat("字", 40)),
+		text("张三:"), image(600),
+		text(strings.Repeat("字", 40)),
+		text("李四:"), image(5000),
+		text("王五:"), image(600),
+		text(strings.Repeat("字", 40)),
+	}
+	limits := ChunkLimits{MaxTokens: 5000, MaxBytes: 1000}
+
+	chunks := splitChunks(parts, limits)
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d", len(chunks))
+	}
+
+	var placeholder bool
+	for i, chunk := range chunks {
+		if chunk[0].Type != chat.ContentTypeText {
+			t.Errorf("Chunk %d starts with %s, want a text part", i, chunk[0].Type)
+		}
+		_, size := measure(chunk)
+		if size > limits.MaxBytes {
+			t.Errorf("Chunk %d carries %d bytes of media, limit %d", i, size, limits.MaxBytes)
+		}
+		for _, part := range chunk {
+			if len(part.Data) > int(limits.MaxBytes) {
+				t.Error("Oversized image should have been replaced")
+			}
+			placeholder = placeholder || part.Text == "[图片]"
+		}
+	}
+	if !placeholder {
+		t.Error("Oversized image placeholder missing")
+	}
+
+	// Media stays with its sender header
+	if chunks[1][0].Text != "王五:" || chunks[1][1].Type != chat.ContentTypeImage {
+		t.Error("Media was separated from its sender header")
+	}
+
+	if got := splitChunks(parts, ChunkLimits{MaxTokens: 60, MaxBytes: 1 << 20}); len(got) != 3 {
+		t.Errorf("Expected 3 token-bounded chunks, got %d", len(got))
+	}
+}
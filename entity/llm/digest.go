@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/entity/config"
+)
+
+const digestSystemPrompt = `你是团队的每日简报编辑。你会收到当天多个群聊的会议纪要，请合并成一份每日汇总。
+- 按重要性从高到低排列：先写需要决策或有截止时间的事项，再写已做出的决定，最后写一般进展
+- 多个群讨论的同一话题只写一次，注明涉及的群
+- 待办事项列出负责人和截止时间
+- 不要编造纪要中没有的内容，只输出汇总本身`
+
+// DigestItem is one group summary fed into the daily digest.
+type DigestItem struct {
+	GroupTopic string
+	TimeRange  string
+	Text       string
+}
+
+// DigestRequest describes a cross-group digest of the summaries of one day.
+type DigestRequest struct {
+	Date  string
+	Items []DigestItem
+}
+
+// GenerateDigest merges the summaries of several groups into one digest. It
+// reads summaries rather than messages, so one request is enough. Like
+// GenerateSummary, it returns the usage even when it fails.
+func (s *Service) GenerateDigest(ctx context.Context, req DigestRequest) (Summary, error) {
+	ctx, usage := withUsage(ctx)
+	p, model, err := s.providerFor(config.GetConfig())
+	if err != nil {
+		return Summary{}, err
+	}
+
+	groups := make(map[string]struct{})
+	for _, item := range req.Items {
+		groups[item.GroupTopic] = struct{}{}
+	}
+	preamble := fmt.Sprintf("日期：%s\n群聊数量：%d\n纪要数量：%d\n\n请基于以下纪要生成每日汇总：\n<summaries>\n",
+		req.Date, len(groups), len(req.Items))
+
+	contents := make([]*chat.Content, len(req.Items))
+	for i, item := range req.Items {
+		contents[i] = &chat.Content{
+			Type: chat.ContentTypeText,
+			Text: fmt.Sprintf("<summary group=%q time=%q>\n%s\n</summary>\n", item.GroupTopic, item.TimeRange, strings.TrimSpace(item.Text)),
+		}
+	}
+
+	summary := Summary{PromptHash: promptHash(digestSystemPrompt)}
+	err = tryProviders(ctx, p, model, func(p Provider, model string) error {
+		text, err := p.GenerateContent(ctx, digestSystemPrompt, wrap(preamble, contents, "</summaries>"))
+		summary.Model, summary.Text = model, text
+		return err
+	})
+	if err != nil {
+		return Summary{Usage: usage.usage()}, err
+	}
+	summary.Usage = usage.usage()
+	return summary, nil
+}
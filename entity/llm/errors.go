@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies provider errors by what the caller should do about
+// them.
+type ErrorKind int
+
+const (
+	ErrUnknown        ErrorKind = iota
+	ErrRateLimited              // too many requests, retry later
+	ErrUnavailable              // server error, overload, timeout or network failure
+	ErrQuotaExhausted           // billing or daily quota used up, retrying will not help
+	ErrAuth                     // invalid API key or missing permission
+	ErrBadRequest               // the provider rejected the request itself
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrRateLimited:
+		return "rate_limited"
+	case ErrUnavailable:
+		return "unavailable"
+	case ErrQuotaExhausted:
+		return "quota_exhausted"
+	case ErrAuth:
+		return "auth"
+	case ErrBadRequest:
+		return "bad_request"
+	default:
+		return "unknown"
+	}
+}
+
+// Retryable reports whether a request failing this way may succeed later.
+func (k ErrorKind) Retryable() bool {
+	return k == ErrRateLimited || k == ErrUnavailable
+}
+
+// APIError is a classified provider error.
+type APIError struct {
+	Provider   string
+	Kind       ErrorKind
+	StatusCode int           // 0 when no response was received
+	RetryAfter time.Duration // server-requested delay, 0 if none
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s API error (%s): %v", e.Provider, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s API error (%s, status %d): %v", e.Provider, e.Kind, e.StatusCode, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKindOf returns the kind of the first APIError in err's chain. A
+// timed-out request counts as ErrUnavailable.
+func ErrorKindOf(err error) ErrorKind {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Kind
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrUnavailable
+	default:
+		return ErrUnknown
+	}
+}
+
+// classifyStatus maps an HTTP status code to an error kind. Providers refine
+// 429s that mean an exhausted quota rather than a rate limit.
+func classifyStatus(status int) ErrorKind {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusPaymentRequired:
+		return ErrQuotaExhausted
+	case status == http.StatusRequestTimeout, status >= 500:
+		return ErrUnavailable
+	case status >= 400:
+		return ErrBadRequest
+	default:
+		return ErrUnknown
+	}
+}
+
+// newAPIError classifies err from provider. Errors without a response, such
+// as connection failures and timeouts, count as ErrUnavailable.
+func newAPIError(provider string, status int, header http.Header, err error) *APIError {
+	kind := ErrUnavailable
+	if status != 0 {
+		kind = classifyStatus(status)
+	}
+	return &APIError{
+		Provider:   provider,
+		Kind:       kind,
+		StatusCode: status,
+		RetryAfter: parseRetryAfter(header, time.Now()),
+		Err:        err,
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
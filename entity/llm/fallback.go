@@ -0,0 +1,190 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// breaker is a circuit breaker for one provider. It opens after threshold
+// consecutive failures and, once cooldown has passed, lets a single request
+// through (half-open) to decide whether to close again.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: max(threshold, 1), cooldown: cooldown, now: time.Now}
+}
+
+// allow reports whether a request may be sent, and whether it is the
+// half-open probe.
+func (b *breaker) allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true, false
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// record updates the breaker with the outcome of a request and reports
+// whether this failure opened the circuit. Only the probe ends the half-open
+// state, so a request let through earlier cannot free a second probe.
+func (b *breaker) record(err error, probe bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	if err == nil {
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+		return true
+	}
+	return false
+}
+
+// release ends the probe without an outcome, e.g. when it was cancelled.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+type chainMember struct {
+	name     string
+	model    string
+	provider Provider
+	breaker  *breaker
+}
+
+// fallbackChain tries providers in order, skipping those whose circuit is
+// open, so minutes still arrive while a provider is down.
+type fallbackChain struct {
+	members []*chainMember
+}
+
+// Capabilities returns the smallest limits of the members so that any of
+// them can take a chunk, and every media type one of them accepts. Each
+// member degrades content to its own capabilities.
+func (c *fallbackChain) Capabilities() Capabilities {
+	caps := c.members[0].provider.Capabilities()
+	caps.MimeTypes = slices.Clone(caps.MimeTypes)
+	for _, m := range c.members[1:] {
+		mc := m.provider.Capabilities()
+		caps.MaxInlineBytes = min(caps.MaxInlineBytes, mc.MaxInlineBytes)
+		caps.MaxRequestBytes = min(caps.MaxRequestBytes, mc.MaxRequestBytes)
+		caps.ContextTokens = min(caps.ContextTokens, mc.ContextTokens)
+		for _, t := range mc.MimeTypes {
+			if !slices.Contains(caps.MimeTypes, t) {
+				caps.MimeTypes = append(caps.MimeTypes, t)
+			}
+		}
+	}
+	return caps
+}
+
+func (c *fallbackChain) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	var text string
+	err := c.run(ctx, func(p Provider, _ string) error {
+		var err error
+		text, err = p.GenerateContent(ctx, systemPrompt, contents)
+		return err
+	})
+	return text, err
+}
+
+// errAllOpen is returned when every circuit of a chain is open.
+var errAllOpen = errors.New("all providers unavailable, circuits open")
+
+// run calls fn with each member whose circuit allows it until one succeeds.
+// When every circuit is open nothing is sent and errAllOpen is returned, so
+// a provider that is down gets its cooldown.
+func (c *fallbackChain) run(ctx context.Context, fn func(p Provider, model string) error) error {
+	var errs []error
+	// try calls fn with m and reports whether the chain is done. probe is
+	// whether the request is m's half-open probe.
+	try := func(m *chainMember, probe bool) (bool, error) {
+		err := fn(m.provider, m.model)
+		if ctx.Err() != nil {
+			// Cancellation says nothing about the provider's health
+			if probe {
+				m.breaker.release()
+			}
+			return true, ctx.Err()
+		}
+		outcome := err
+		if ErrorKindOf(err) == ErrBadRequest {
+			// A rejected request shows the provider is up
+			outcome = nil
+		}
+		if m.breaker.record(outcome, probe) {
+			logging.Warn("Provider circuit opened",
+				zap.String("provider", m.name),
+				zap.Duration("cooldown", m.breaker.cooldown))
+		}
+		if err == nil {
+			if m != c.members[0] {
+				logging.Info("Summary generated by fallback provider", zap.String("provider", m.name), zap.String("model", m.model))
+			}
+			return true, nil
+		}
+		logging.Warn("Provider failed, trying next",
+			zap.String("provider", m.name),
+			zap.String("model", m.model),
+			zap.Error(err))
+		errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		return false, nil
+	}
+
+	tried := false
+	for _, m := range c.members {
+		// Ask each breaker only when its member is next, so a half-open
+		// probe is not taken for a member the chain never reaches
+		ok, probe := m.breaker.allow()
+		if !ok {
+			continue
+		}
+		tried = true
+		if done, err := try(m, probe); done {
+			return err
+		}
+	}
+	if !tried {
+		logging.Warn("All provider circuits open, skipping the request")
+		return errAllOpen
+	}
+	return fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
+
+// tryProviders calls fn with p, or with each member of a fallback chain in
+// turn until one succeeds. model is the model fn is called with for a
+// single provider.
+func tryProviders(ctx context.Context, p Provider, model string, fn func(p Provider, model string) error) error {
+	if c, ok := p.(*fallbackChain); ok {
+		return c.run(ctx, fn)
+	}
+	return fn(p, model)
+}
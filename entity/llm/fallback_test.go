@@ -0,0 +1,180 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/entity/config"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2025, 3, 5, 10, 0, 0, 0, time.Local)
+	b := newBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	fail := errors.New("unavailable")
+	allowed := func() bool {
+		ok, _ := b.allow()
+		return ok
+	}
+
+	b.record(fail, false)
+	if ok, probe := b.allow(); !ok || probe {
+		t.Fatal("breaker opened before reaching the threshold")
+	}
+	if !b.record(fail, false) {
+		t.Fatal("breaker did not open at the threshold")
+	}
+	if allowed() {
+		t.Fatal("open breaker allowed a request")
+	}
+
+	now = now.Add(time.Minute)
+	if ok, probe := b.allow(); !ok || !probe {
+		t.Fatal("breaker did not half-open after the cooldown")
+	}
+	if allowed() {
+		t.Fatal("half-open breaker allowed a second request")
+	}
+	// A request let through before the circuit opened does not end the probe
+	b.record(fail, false)
+	if allowed() {
+		t.Fatal("non-probe outcome freed a second probe")
+	}
+	if !b.record(fail, true) || allowed() {
+		t.Fatal("failed probe did not reopen the breaker")
+	}
+
+	now = now.Add(time.Minute)
+	_, probe := b.allow()
+	b.record(nil, probe)
+	if !allowed() || !allowed() {
+		t.Fatal("successful probe did not close the breaker")
+	}
+}
+
+func TestFallbackChain(t *testing.T) {
+	os.Setenv("LLM_API_KEY", "test-key")
+	os.Setenv("SYSTEM_PROMPT_FILE", "test_prompt.txt")
+	defer func() {
+		os.Unsetenv("LLM_API_KEY")
+		os.Unsetenv("SYSTEM_PROMPT_FILE")
+		os.Remove("test_prompt.txt")
+	}()
+
+	if err := os.WriteFile("test_prompt.txt", []byte("You are a bot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := New()
+	defer svc.Close()
+
+	primary := &MockProvider{MockError: errors.New("503 unavailable")}
+	backup := &structuredMock{
+		MockProvider:       MockProvider{MockResponse: "plain minutes"},
+		StructuredResponse: `{"has_update": true, "key_points": ["周五发布"]}`,
+	}
+	chain := &fallbackChain{members: []*chainMember{
+		{name: "gemini", model: "gemini-2.5-flash", provider: primary, breaker: newBreaker(2, time.Hour)},
+		{name: "backup", model: "gpt-4o-mini", provider: backup, breaker: newBreaker(2, time.Hour)},
+	}}
+	var p Provider = chain
+	svc.provider.Store(&p)
+
+	req := SummaryRequest{
+		GroupTopic: "Test Group",
+		Messages:   []*chat.Content{{Type: chat.ContentTypeText, Text: "Hello"}},
+	}
+	for i := 0; i < 3; i++ {
+		primary.LastContents = nil
+		summary, err := svc.GenerateSummary(context.Background(), req)
+		if err != nil {
+			t.Fatalf("GenerateSummary failed: %v", err)
+		}
+		if summary.Model != "gpt-4o-mini" || summary.Minutes == nil {
+			t.Fatalf("Expected structured minutes from the fallback, got %+v", summary)
+		}
+		// The open circuit keeps the third summary off the primary
+		if called := primary.LastContents != nil; called != (i < 2) {
+			t.Errorf("summary %d: primary called = %v", i, called)
+		}
+	}
+
+	backup.MockError = errors.New("quota exceeded")
+	if _, err := svc.GenerateSummary(context.Background(), req); err == nil {
+		t.Error("Expected an error when every provider fails")
+	}
+}
+
+func TestFallbackChainHalfOpenBackup(t *testing.T) {
+	now := time.Date(2025, 3, 5, 10, 0, 0, 0, time.Local)
+	backupBreaker := newBreaker(1, time.Minute)
+	backupBreaker.now = func() time.Time { return now }
+	backupBreaker.record(errors.New("unavailable"), false)
+	now = now.Add(time.Minute)
+
+	backup := &MockProvider{MockResponse: "backup minutes"}
+	chain := &fallbackChain{members: []*chainMember{
+		{name: "primary", provider: &MockProvider{MockResponse: "minutes"}, breaker: newBreaker(1, time.Minute)},
+		{name: "backup", provider: backup, breaker: backupBreaker},
+	}}
+
+	for i := 0; i < 2; i++ {
+		text, err := chain.GenerateContent(context.Background(), "prompt", nil)
+		if err != nil || text != "minutes" {
+			t.Fatalf("GenerateContent() = %q, %v", text, err)
+		}
+	}
+	if backup.LastSystemPrompt != "" {
+		t.Error("backup called while the primary succeeds")
+	}
+	// The primary's successes must not have left the backup's probe taken
+	if ok, _ := backupBreaker.allow(); !ok {
+		t.Error("half-open backup no longer allows a probe")
+	}
+}
+
+func TestFallbackChainCancelReleasesProbe(t *testing.T) {
+	now := time.Date(2025, 3, 5, 10, 0, 0, 0, time.Local)
+	b := newBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+	b.record(errors.New("unavailable"), false)
+	now = now.Add(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chain := &fallbackChain{members: []*chainMember{
+		{name: "primary", provider: &MockProvider{}, breaker: b},
+	}}
+	err := chain.run(ctx, func(Provider, string) error {
+		cancel()
+		return context.Canceled
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("run() error = %v", err)
+	}
+	if ok, _ := b.allow(); !ok {
+		t.Error("cancelled probe was not released")
+	}
+}
+
+func TestFallbackChainAllOpen(t *testing.T) {
+	primary := &MockProvider{MockResponse: "minutes"}
+	b := newBreaker(1, time.Hour)
+	b.record(errors.New("unavailable"), false)
+	chain := &fallbackChain{members: []*chainMember{
+		{name: "primary", provider: primary, breaker: b},
+	}}
+
+	if _, err := chain.GenerateContent(context.Background(), "prompt", nil); !errors.Is(err, errAllOpen) {
+		t.Fatalf("GenerateContent() error = %v, want errAllOpen", err)
+	}
+	if primary.LastSystemPrompt != "" {
+		t.Error("provider called while its circuit is open")
+	}
+}
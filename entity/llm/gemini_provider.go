@@ -2,7 +2,10 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/soaringk/msg-asst/entity/chat"
 	"github.com/soaringk/msg-asst/pkg/logging"
@@ -41,7 +44,32 @@ func NewGeminiProvider(ctx context.Context, cfg GeminiConfig) (*GeminiProvider,
 	}, nil
 }
 
+// Capabilities keeps requests well inside Gemini's long context and its 20MB
+// inline request limit. AMR voice is not among the audio formats it reads.
+func (p *GeminiProvider) Capabilities() Capabilities {
+	return Capabilities{
+		MimeTypes: []string{
+			"image/png", "image/jpeg", "image/webp", "image/heic", "image/heif",
+			"video/*",
+			"audio/wav", "audio/mp3", "audio/mpeg", "audio/aiff", "audio/aac", "audio/ogg", "audio/flac",
+			"application/pdf",
+		},
+		MaxInlineBytes:  14 << 20,
+		MaxRequestBytes: 14 << 20,
+		ContextTokens:   500_000,
+	}
+}
+
 func (p *GeminiProvider) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	return p.generate(ctx, systemPrompt, contents, nil)
+}
+
+// GenerateStructured asks Gemini for JSON matching schema.
+func (p *GeminiProvider) GenerateStructured(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
+	return p.generate(ctx, systemPrompt, contents, schema)
+}
+
+func (p *GeminiProvider) generate(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
 	parts := p.buildParts(contents)
 
 	userContent := &genai.Content{
@@ -51,23 +79,45 @@ func (p *GeminiProvider) GenerateContent(ctx context.Context, systemPrompt strin
 
 	p.log.Debug("Sending request to Gemini",
 		zap.String("model", p.model),
-		zap.Int("parts", len(parts)))
+		zap.Int("parts", len(parts)),
+		zap.Bool("structured", schema != nil))
+
+	genConfig := &genai.GenerateContentConfig{
+		SystemInstruction: &genai.Content{
+			Role:  genai.RoleUser,
+			Parts: []*genai.Part{{Text: systemPrompt}},
+		},
+	}
+	if schema != nil {
+		genConfig.ResponseMIMEType = "application/json"
+		genConfig.ResponseSchema = toGenaiSchema(schema)
+	}
 
 	result, err := p.client.Models.GenerateContent(
 		ctx,
 		p.model,
 		[]*genai.Content{userContent},
-		&genai.GenerateContentConfig{
-			SystemInstruction: &genai.Content{
-				Role:  genai.RoleUser,
-				Parts: []*genai.Part{{Text: systemPrompt}},
-			},
-		},
+		genConfig,
 	)
 
 	if err != nil {
 		p.log.Error("Gemini API error", zap.Error(err))
-		return "", fmt.Errorf("Gemini API error: %w", err)
+		return "", geminiError(err)
+	}
+
+	if u := result.UsageMetadata; u != nil {
+		usage := Usage{
+			Model:            p.model,
+			PromptTokens:     int(u.PromptTokenCount),
+			CompletionTokens: int(u.CandidatesTokenCount + u.ThoughtsTokenCount),
+			CachedTokens:     int(u.CachedContentTokenCount),
+		}
+		for _, d := range u.PromptTokensDetails {
+			if d.Modality != genai.MediaModalityText {
+				usage.MediaTokens += int(d.TokenCount)
+			}
+		}
+		recordUsage(ctx, usage)
 	}
 
 	text := result.Text()
@@ -76,6 +126,61 @@ func (p *GeminiProvider) GenerateContent(ctx context.Context, systemPrompt strin
 	return text, nil
 }
 
+// geminiError classifies a Gemini error. Gemini answers both rate limits and
+// exhausted daily quotas with 429 RESOURCE_EXHAUSTED; the quota violation in
+// the details tells them apart.
+func geminiError(err error) *APIError {
+	var gErr genai.APIError
+	if !errors.As(err, &gErr) {
+		return newAPIError("Gemini", 0, nil, err)
+	}
+
+	apiErr := newAPIError("Gemini", gErr.Code, nil, err)
+	for _, detail := range gErr.Details {
+		switch detail["@type"] {
+		case "type.googleapis.com/google.rpc.RetryInfo":
+			if delay, ok := detail["retryDelay"].(string); ok {
+				apiErr.RetryAfter, _ = time.ParseDuration(delay)
+			}
+		case "type.googleapis.com/google.rpc.QuotaFailure":
+			violations, _ := detail["violations"].([]any)
+			for _, v := range violations {
+				violation, _ := v.(map[string]any)
+				if id, _ := violation["quotaId"].(string); strings.Contains(id, "PerDay") {
+					apiErr.Kind = ErrQuotaExhausted
+				}
+			}
+		}
+	}
+	return apiErr
+}
+
+// toGenaiSchema converts schema to Gemini's OpenAPI subset, keeping the
+// property order so the model fills has_update first.
+func toGenaiSchema(schema *Schema) *genai.Schema {
+	out := &genai.Schema{Description: schema.Description}
+	switch schema.Type {
+	case SchemaObject:
+		out.Type = genai.TypeObject
+		out.Properties = make(map[string]*genai.Schema, len(schema.Properties))
+		for _, prop := range schema.Properties {
+			out.Properties[prop.Name] = toGenaiSchema(prop.Schema)
+			out.Required = append(out.Required, prop.Name)
+			out.PropertyOrdering = append(out.PropertyOrdering, prop.Name)
+		}
+	case SchemaArray:
+		out.Type = genai.TypeArray
+		if schema.Items != nil {
+			out.Items = toGenaiSchema(schema.Items)
+		}
+	case SchemaBoolean:
+		out.Type = genai.TypeBoolean
+	default:
+		out.Type = genai.TypeString
+	}
+	return out
+}
+
 func (p *GeminiProvider) buildParts(contents []*chat.Content) []*genai.Part {
 	var parts []*genai.Part
 
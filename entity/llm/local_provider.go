@@ -0,0 +1,357 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// APIs of local model servers.
+const (
+	LocalAPIOllama   = "ollama"   // Ollama's native /api/chat
+	LocalAPILlamaCpp = "llamacpp" // llama.cpp server's OpenAI-compatible /v1/chat/completions
+)
+
+// defaultLocalContextTokens is the context window requested from Ollama
+// when none is configured; its own default is too small for a summary.
+const defaultLocalContextTokens = 32_768
+
+// LocalProvider talks to a model server on the local network, such as
+// Ollama or a llama.cpp server. Only images are sent inline, and only to
+// vision models.
+type LocalProvider struct {
+	api           string
+	baseURL       string
+	apiKey        string
+	model         string
+	contextTokens int
+	maxTokens     int
+	client        *http.Client
+	log           *zap.Logger
+	// noVision is set once the model rejects images, so later requests
+	// carry placeholders instead
+	noVision atomic.Bool
+}
+
+type LocalConfig struct {
+	API           string // LocalAPIOllama or LocalAPILlamaCpp
+	BaseURL       string
+	APIKey        string // optional bearer token of a proxy in front of the server
+	Model         string
+	ContextTokens int
+	MaxTokens     int
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   map[string]any  `json:"format,omitempty"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+type ollamaResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+type llamaCppRequest struct {
+	Model          string            `json:"model,omitempty"`
+	Messages       []llamaCppMessage `json:"messages"`
+	MaxTokens      int               `json:"max_tokens,omitempty"`
+	ResponseFormat map[string]any    `json:"response_format,omitempty"`
+}
+
+type llamaCppMessage struct {
+	Role    string         `json:"role"`
+	Content []llamaCppPart `json:"content"`
+}
+
+type llamaCppPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+type llamaCppResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func NewLocalProvider(cfg LocalConfig) *LocalProvider {
+	p := &LocalProvider{
+		api:           cfg.API,
+		baseURL:       strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:        cfg.APIKey,
+		model:         cfg.Model,
+		contextTokens: cfg.ContextTokens,
+		maxTokens:     cfg.MaxTokens,
+		client:        &http.Client{Timeout: anthropicTimeout},
+		log:           logging.Named(cfg.API),
+	}
+	if p.contextTokens <= 0 {
+		p.contextTokens = defaultLocalContextTokens
+	}
+
+	p.log.Info("Local provider initialized",
+		zap.String("api", cfg.API),
+		zap.String("model", cfg.Model),
+		zap.String("baseURL", p.baseURL),
+		zap.Int("contextTokens", p.contextTokens))
+
+	return p
+}
+
+// Capabilities leaves half of the context window for the prompt, previous
+// minutes and the response. Images are accepted until the model rejects
+// one.
+func (p *LocalProvider) Capabilities() Capabilities {
+	caps := Capabilities{
+		MaxInlineBytes:  10 << 20,
+		MaxRequestBytes: 10 << 20,
+		ContextTokens:   p.contextTokens / 2,
+	}
+	if !p.noVision.Load() {
+		caps.MimeTypes = []string{"image/jpeg", "image/png"}
+	}
+	return caps
+}
+
+func (p *LocalProvider) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	return p.generate(ctx, systemPrompt, contents, nil)
+}
+
+// GenerateStructured constrains the response with the server's JSON schema
+// support: Ollama's format and llama.cpp's response_format.
+func (p *LocalProvider) GenerateStructured(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
+	return p.generate(ctx, systemPrompt, contents, schema)
+}
+
+// generate sends the request. When the model turns out to have no vision,
+// images are dropped from Capabilities and the error is reported as a
+// rejected request, so it is retried as text rather than as an outage.
+func (p *LocalProvider) generate(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
+	text, err := p.send(ctx, systemPrompt, contents, schema)
+	var apiErr *APIError
+	if err != nil && hasImages(contents) && isVisionError(err) && errors.As(err, &apiErr) {
+		p.noVision.Store(true)
+		p.log.Warn("Model does not support images, sending placeholders from now on", zap.String("model", p.model), zap.Error(err))
+		apiErr.Kind = ErrBadRequest
+	}
+	return text, err
+}
+
+func (p *LocalProvider) send(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
+	p.log.Debug("Sending request to local model",
+		zap.String("model", p.model),
+		zap.Int("contents", len(contents)),
+		zap.Bool("structured", schema != nil))
+
+	if p.api == LocalAPILlamaCpp {
+		req := llamaCppRequest{
+			Model:     p.model,
+			MaxTokens: p.maxTokens,
+			Messages: []llamaCppMessage{
+				{Role: "system", Content: []llamaCppPart{{Type: "text", Text: systemPrompt}}},
+				{Role: "user", Content: p.llamaCppParts(contents)},
+			},
+		}
+		if schema != nil {
+			req.ResponseFormat = map[string]any{
+				"type":        "json_schema",
+				"json_schema": map[string]any{"name": schema.Name, "schema": schema.JSONSchema(), "strict": true},
+			}
+		}
+		var resp llamaCppResponse
+		if err := p.post(ctx, "/v1/chat/completions", req, &resp); err != nil {
+			return "", err
+		}
+		recordUsage(ctx, Usage{
+			Model:            p.model,
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+		})
+		if len(resp.Choices) == 0 {
+			return "", fmt.Errorf("no response from llama.cpp")
+		}
+		if resp.Choices[0].FinishReason == "length" {
+			p.log.Warn("Local model response truncated", zap.String("model", p.model))
+		}
+		return resp.Choices[0].Message.Content, nil
+	}
+
+	text, images := p.ollamaContent(contents)
+	req := ollamaRequest{
+		Model: p.model,
+		Messages: []ollamaMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: text, Images: images},
+		},
+		Options: map[string]any{"num_ctx": p.contextTokens},
+	}
+	if p.maxTokens > 0 {
+		req.Options["num_predict"] = p.maxTokens
+	}
+	if schema != nil {
+		req.Format = schema.JSONSchema()
+	}
+	var resp ollamaResponse
+	if err := p.post(ctx, "/api/chat", req, &resp); err != nil {
+		return "", err
+	}
+	recordUsage(ctx, Usage{
+		Model:            p.model,
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+	})
+	if resp.DoneReason == "length" {
+		p.log.Warn("Local model response truncated", zap.String("model", p.model))
+	}
+	return resp.Message.Content, nil
+}
+
+func (p *LocalProvider) post(ctx context.Context, path string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal local model request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to build local model request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		p.log.Error("Local model API error", zap.Error(err))
+		return newAPIError("Local model", 0, nil, err)
+	}
+	defer httpResp.Body.Close()
+
+	data, err = io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read local model response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		message := localErrorMessage(data)
+		p.log.Error("Local model API error", zap.Int("status", httpResp.StatusCode), zap.String("error", message))
+		return newAPIError("Local model", httpResp.StatusCode, httpResp.Header, errors.New(message))
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse local model response: %w", err)
+	}
+	return nil
+}
+
+// ollamaContent flattens contents into the message text and its images.
+// Ollama attaches images to the message rather than placing them inline.
+func (p *LocalProvider) ollamaContent(contents []*chat.Content) (string, []string) {
+	var sb strings.Builder
+	var images []string
+	for _, c := range contents {
+		switch {
+		case c.Type == chat.ContentTypeText:
+			sb.WriteString(c.Text)
+		case c.Type == chat.ContentTypeImage && len(c.Data) > 0:
+			images = append(images, base64.StdEncoding.EncodeToString(c.Data))
+			fmt.Fprintf(&sb, "[图片 %d]", len(images))
+		default:
+			sb.WriteString(c.Description())
+		}
+	}
+	return sb.String(), images
+}
+
+func (p *LocalProvider) llamaCppParts(contents []*chat.Content) []llamaCppPart {
+	var parts []llamaCppPart
+	for _, c := range contents {
+		switch {
+		case c.Type == chat.ContentTypeText:
+			parts = append(parts, llamaCppPart{Type: "text", Text: c.Text})
+		case c.Type == chat.ContentTypeImage && len(c.Data) > 0:
+			part := llamaCppPart{Type: "image_url", ImageURL: &struct {
+				URL string `json:"url"`
+			}{URL: fmt.Sprintf("data:%s;base64,%s", c.MimeType, base64.StdEncoding.EncodeToString(c.Data))}}
+			parts = append(parts, part)
+		default:
+			parts = append(parts, llamaCppPart{Type: "text", Text: c.Description()})
+		}
+	}
+	return parts
+}
+
+func hasImages(contents []*chat.Content) bool {
+	for _, c := range contents {
+		if c.Type == chat.ContentTypeImage && len(c.Data) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isVisionError reports whether a request failed because the model cannot
+// take images.
+func isVisionError(err error) bool {
+	if ErrorKindOf(err) != ErrBadRequest && ErrorKindOf(err) != ErrUnavailable {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "image") || strings.Contains(msg, "vision") || strings.Contains(msg, "multimodal")
+}
+
+// localErrorMessage extracts the message of an Ollama ({"error": "..."}) or
+// llama.cpp ({"error": {"message": "..."}}) error body.
+func localErrorMessage(data []byte) string {
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && len(body.Error) > 0 {
+		var text string
+		if json.Unmarshal(body.Error, &text) == nil {
+			return text
+		}
+		var obj struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body.Error, &obj) == nil && obj.Message != "" {
+			return obj.Message
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
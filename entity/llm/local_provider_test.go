@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+)
+
+func newLocalServer(t *testing.T, api, path string, handler func(body map[string]any) (int, string)) *LocalProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			t.Errorf("path = %s, want %s", r.URL.Path, path)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		status, resp := handler(body)
+		w.WriteHeader(status)
+		w.Write([]byte(resp))
+	}))
+	t.Cleanup(srv.Close)
+
+	return NewLocalProvider(LocalConfig{
+		API:           api,
+		BaseURL:       srv.URL,
+		Model:         "qwen2.5vl",
+		ContextTokens: 16384,
+	})
+}
+
+var localContents = []*chat.Content{
+	{Type: chat.ContentTypeText, Text: "[10:00] 张三: 看图"},
+	{Type: chat.ContentTypeImage, Data: []byte("png"), MimeType: "image/png"},
+	{Type: chat.ContentTypeVideo, Data: []byte("mp4"), MimeType: "video/mp4"},
+}
+
+func TestOllamaChat(t *testing.T) {
+	var received []map[string]any
+	p := newLocalServer(t, LocalAPIOllama, "/api/chat", func(body map[string]any) (int, string) {
+		received = append(received, body)
+		return http.StatusOK, `{"message":{"role":"assistant","content":"纪要"},"done":true,"prompt_eval_count":80,"eval_count":12}`
+	})
+
+	ctx, collector := withUsage(context.Background())
+	text, err := p.GenerateContent(ctx, "system", localContents)
+	if err != nil || text != "纪要" {
+		t.Fatalf("GenerateContent() = %q, %v", text, err)
+	}
+	want := Usage{Model: "qwen2.5vl", Requests: 1, PromptTokens: 80, CompletionTokens: 12}
+	if used := collector.usage(); len(used) != 1 || used[0] != want {
+		t.Errorf("usage = %+v, want %+v", used, want)
+	}
+
+	req := received[0]
+	if req["stream"] != false || req["options"].(map[string]any)["num_ctx"] != float64(16384) {
+		t.Errorf("request = %v", req)
+	}
+	messages := req["messages"].([]any)
+	user := messages[1].(map[string]any)
+	if images := user["images"].([]any); len(images) != 1 || images[0] != "cG5n" {
+		t.Errorf("images = %v", user["images"])
+	}
+	content := user["content"].(string)
+	if !strings.Contains(content, "[图片 1]") || !strings.Contains(content, localContents[2].Description()) {
+		t.Errorf("content = %q", content)
+	}
+
+	if limits := limitsFor(p, 0, 0); limits.MaxTokens != 8192 {
+		t.Errorf("limitsFor() = %+v", limits)
+	}
+}
+
+func TestOllamaWithoutVision(t *testing.T) {
+	var received []map[string]any
+	local := newLocalServer(t, LocalAPIOllama, "/api/chat", func(body map[string]any) (int, string) {
+		received = append(received, body)
+		user := body["messages"].([]any)[1].(map[string]any)
+		if _, ok := user["images"]; ok {
+			return http.StatusInternalServerError, `{"error":"this model is missing data required for image input"}`
+		}
+		return http.StatusOK, `{"message":{"content":"纪要"},"done":true}`
+	})
+	p := withCapabilities(local, "local")
+
+	for i := 0; i < 2; i++ {
+		if text, err := p.GenerateContent(context.Background(), "system", localContents); err != nil || text != "纪要" {
+			t.Fatalf("GenerateContent() = %q, %v", text, err)
+		}
+	}
+	// The rejected request is retried as text, and later ones skip images
+	if len(received) != 3 {
+		t.Fatalf("requests = %d, want 3", len(received))
+	}
+	user := received[1]["messages"].([]any)[1].(map[string]any)
+	if !strings.Contains(user["content"].(string), localContents[1].Description()) {
+		t.Errorf("content = %q", user["content"])
+	}
+}
+
+func TestLlamaCppStructured(t *testing.T) {
+	var received map[string]any
+	p := newLocalServer(t, LocalAPILlamaCpp, "/v1/chat/completions", func(body map[string]any) (int, string) {
+		received = body
+		return http.StatusOK, `{"choices":[{"message":{"content":"{\"has_update\":false}"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":50,"completion_tokens":5}}`
+	})
+
+	text, err := p.GenerateStructured(context.Background(), "system", localContents, MinutesSchema)
+	if err != nil {
+		t.Fatalf("GenerateStructured() error = %v", err)
+	}
+	if m, err := ParseMinutes(text); err != nil || m.HasUpdate {
+		t.Errorf("ParseMinutes(%q) = %+v, %v", text, m, err)
+	}
+
+	format := received["response_format"].(map[string]any)
+	if format["type"] != "json_schema" {
+		t.Errorf("response_format = %v", format)
+	}
+	parts := received["messages"].([]any)[1].(map[string]any)["content"].([]any)
+	image := parts[1].(map[string]any)["image_url"].(map[string]any)
+	if image["url"] != "data:image/png;base64,cG5n" {
+		t.Errorf("image_url = %v", image)
+	}
+}
+
+func TestLocalError(t *testing.T) {
+	p := newLocalServer(t, LocalAPIOllama, "/api/chat", func(map[string]any) (int, string) {
+		return http.StatusNotFound, `{"error":"model \"qwen2.5vl\" not found, try pulling it first"}`
+	})
+
+	_, err := p.GenerateContent(context.Background(), "system", nil)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("GenerateContent() error = %v", err)
+	}
+	if kind := ErrorKindOf(err); kind != ErrBadRequest {
+		t.Errorf("ErrorKindOf() = %v, want %v", kind, ErrBadRequest)
+	}
+}
@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// mapConcurrency bounds the chunk requests in flight for one summary.
+	mapConcurrency = 3
+	// maxReduceRounds stops merging notes that refuse to shrink.
+	maxReduceRounds = 4
+)
+
+const mapSystemPrompt = `你是群聊记录员。请把给出的这一段群聊消息整理成详细要点，供之后与其他段落合并成完整纪要。
+- 按时间顺序列出讨论的话题、各方观点和结论
+- 完整保留决定、待办事项（负责人、截止时间）、数据、链接和具体名称
+- 图片、语音、视频、文件中的关键信息也要写成文字
+- 不要评论，不要省略，只输出要点`
+
+const mergeSystemPrompt = `你是群聊记录员。请把按时间顺序排列的多段讨论要点合并成一份要点。
+- 合并重复内容，保持时间顺序
+- 完整保留决定、待办事项（负责人、截止时间）、数据、链接和具体名称
+- 不要评论，只输出要点`
+
+// condenseChunks prepares messages too large for one request: each chunk is
+// condensed into notes (map) and notes are merged until they fit in one
+// request (reduce). The minutes are then written from the notes with the
+// group's prompt.
+func condenseChunks(ctx context.Context, p Provider, req SummaryRequest, chunks [][]*chat.Content, limits ChunkLimits) ([]string, error) {
+	logging.Info("Summarizing in chunks",
+		zap.String("group", req.GroupTopic),
+		zap.Int("chunks", len(chunks)),
+		zap.Int("maxTokens", limits.MaxTokens),
+		zap.Int64("maxBytes", limits.MaxBytes))
+
+	notes, err := mapConcurrent(ctx, len(chunks), func(ctx context.Context, i int) (string, error) {
+		contents := wrap(
+			fmt.Sprintf("群聊名称：%s\n这是第 %d/%d 段消息。\n请整理本段要点：\n<messages>\n", req.GroupTopic, i+1, len(chunks)),
+			chunks[i],
+			"\n</messages>",
+		)
+		return p.GenerateContent(ctx, mapSystemPrompt, contents)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize chunk: %w", err)
+	}
+
+	notes, err = reduceNotes(ctx, p, req.GroupTopic, notes, limits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge chunk notes: %w", err)
+	}
+	return notes, nil
+}
+
+// reduceNotes merges adjacent notes until all of them fit in one request.
+func reduceNotes(ctx context.Context, p Provider, groupTopic string, notes []string, limits ChunkLimits) ([]string, error) {
+	for round := 0; round < maxReduceRounds && len(notes) > 1; round++ {
+		batches := splitChunks(notesContents(notes), limits)
+		if len(batches) == 1 {
+			return notes, nil
+		}
+		if len(batches) == len(notes) {
+			// Every note fills a chunk on its own; merge pairwise to make progress
+			batches = pairs(notesContents(notes))
+		}
+
+		logging.Debug("Merging chunk notes",
+			zap.String("group", groupTopic),
+			zap.Int("round", round+1),
+			zap.Int("notes", len(notes)),
+			zap.Int("batches", len(batches)))
+
+		starts := make([]int, len(batches))
+		for i := 1; i < len(batches); i++ {
+			starts[i] = starts[i-1] + len(batches[i-1])
+		}
+
+		merged, err := mapConcurrent(ctx, len(batches), func(ctx context.Context, i int) (string, error) {
+			if len(batches[i]) == 1 {
+				return notes[starts[i]], nil
+			}
+			contents := wrap(fmt.Sprintf("群聊名称：%s\n请合并以下要点：\n<notes>\n", groupTopic), batches[i], "\n</notes>")
+			return p.GenerateContent(ctx, mergeSystemPrompt, contents)
+		})
+		if err != nil {
+			return nil, err
+		}
+		notes = merged
+	}
+	return notes, nil
+}
+
+// mapConcurrent runs fn for 0..n-1 with at most mapConcurrency calls in
+// flight and returns the results in order. The first error cancels the rest.
+func mapConcurrent(ctx context.Context, n int, fn func(ctx context.Context, i int) (string, error)) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]string, n)
+	errs := make([]error, n)
+	sem := make(chan struct{}, mapConcurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			results[i], errs[i] = fn(ctx, i)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var firstErr error
+	for _, err := range errs {
+		if err != nil && (firstErr == nil || errors.Is(firstErr, context.Canceled)) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+func notesContents(notes []string) []*chat.Content {
+	contents := make([]*chat.Content, len(notes))
+	for i, note := range notes {
+		contents[i] = &chat.Content{
+			Type: chat.ContentTypeText,
+			Text: fmt.Sprintf("【第 %d 段】\n%s\n", i+1, strings.TrimSpace(note)),
+		}
+	}
+	return contents
+}
+
+func pairs(contents []*chat.Content) [][]*chat.Content {
+	var batches [][]*chat.Content
+	for i := 0; i < len(contents); i += 2 {
+		batches = append(batches, contents[i:min(i+2, len(contents))])
+	}
+	return batches
+}
+
+// wrap surrounds contents with an opening and a closing text part.
+func wrap(opening string, contents []*chat.Content, closing string) []*chat.Content {
+	wrapped := make([]*chat.Content, 0, len(contents)+2)
+	wrapped = append(wrapped, &chat.Content{Type: chat.ContentTypeText, Text: opening})
+	wrapped = append(wrapped, contents...)
+	wrapped = append(wrapped, &chat.Content{Type: chat.ContentTypeText, Text: closing})
+	return wrapped
+}
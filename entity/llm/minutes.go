@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Minutes is the structured form of a summary, returned by providers that
+// support response schemas.
+type Minutes struct {
+	HasUpdate    bool         `json:"has_update"`
+	KeyPoints    []string     `json:"key_points"`
+	Decisions    []string     `json:"decisions"`
+	ActionItems  []ActionItem `json:"action_items"`
+	Participants []string     `json:"participants"`
+	Risks        []string     `json:"risks"`
+}
+
+// ActionItem is a task agreed on in the chat.
+type ActionItem struct {
+	Task   string `json:"task"`
+	Owner  string `json:"owner"`
+	Due    string `json:"due"`
+	Source string `json:"source"` // the message the item came from
+}
+
+// MinutesSchema constrains summary responses to Minutes.
+var MinutesSchema = &Schema{
+	Name:        "minutes",
+	Type:        SchemaObject,
+	Description: "群聊纪要",
+	Properties: []Property{
+		{"has_update", &Schema{Type: SchemaBoolean, Description: "是否有值得报告的重要信息；为 false 时其余字段留空"}},
+		{"key_points", arraySchema("关键事项、进展和变更，每条一句话", stringSchema(""))},
+		{"decisions", arraySchema("已经做出的决定", stringSchema(""))},
+		{"action_items", arraySchema("待办事项", &Schema{
+			Type: SchemaObject,
+			Properties: []Property{
+				{"task", stringSchema("要做的事")},
+				{"owner", stringSchema("负责人，未指定时留空")},
+				{"due", stringSchema("截止时间，如 2025-03-01 或 周五，未提及时留空")},
+				{"source", stringSchema("提出该待办的原消息，格式为“发送者: 内容”")},
+			},
+		})},
+		{"participants", arraySchema("参与重要讨论的成员", stringSchema(""))},
+		{"risks", arraySchema("风险、阻塞和需要注意的问题", stringSchema(""))},
+	},
+}
+
+// ParseMinutes decodes a structured summary response. Code fences some
+// models wrap around JSON are tolerated.
+func ParseMinutes(text string) (*Minutes, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+
+	var m Minutes
+	if err := json.Unmarshal([]byte(text), &m); err != nil {
+		return nil, fmt.Errorf("failed to parse minutes: %w", err)
+	}
+	return &m, nil
+}
+
+// Empty reports whether the minutes contain nothing to deliver.
+func (m *Minutes) Empty() bool {
+	return len(m.KeyPoints) == 0 && len(m.Decisions) == 0 && len(m.ActionItems) == 0 && len(m.Risks) == 0
+}
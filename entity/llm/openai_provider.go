@@ -3,7 +3,9 @@ package llm
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/http"
 	"sync/atomic"
 
 	openai "github.com/openai/openai-go/v3"
@@ -35,6 +37,8 @@ func NewOpenAIProvider(cfg OpenAIConfig) *OpenAIProvider {
 	client := openai.NewClient(
 		option.WithAPIKey(cfg.APIKey),
 		option.WithBaseURL(cfg.BaseURL),
+		// Service retries with its own backoff and fallbacks
+		option.WithMaxRetries(0),
 	)
 	p.client.Store(&client)
 
@@ -45,7 +49,28 @@ func NewOpenAIProvider(cfg OpenAIConfig) *OpenAIProvider {
 	return p
 }
 
+// Capabilities assumes a 128K context. Media is sent base64-encoded, which
+// grows it by a third. Audio input takes only WAV and MP3.
+func (p *OpenAIProvider) Capabilities() Capabilities {
+	return Capabilities{
+		MimeTypes:       []string{"image/jpeg", "image/png", "image/gif", "image/webp", "audio/wav", "audio/mpeg", "audio/mp3"},
+		MaxInlineBytes:  10 << 20,
+		MaxRequestBytes: 10 << 20,
+		ContextTokens:   100_000,
+	}
+}
+
 func (p *OpenAIProvider) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	return p.generate(ctx, systemPrompt, contents, nil)
+}
+
+// GenerateStructured asks for JSON matching schema via a strict json_schema
+// response format.
+func (p *OpenAIProvider) GenerateStructured(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
+	return p.generate(ctx, systemPrompt, contents, schema)
+}
+
+func (p *OpenAIProvider) generate(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
 	client := p.client.Load()
 	model := shared.ChatModel(p.model)
 
@@ -53,24 +78,44 @@ func (p *OpenAIProvider) GenerateContent(ctx context.Context, systemPrompt strin
 
 	p.log.Debug("Sending request to OpenAI",
 		zap.String("model", p.model),
-		zap.Int("contentParts", len(parts)))
-
-	resp, err := client.Chat.Completions.New(
-		ctx,
-		openai.ChatCompletionNewParams{
-			Model: model,
-			Messages: []openai.ChatCompletionMessageParamUnion{
-				openai.SystemMessage(systemPrompt),
-				openai.UserMessage(parts),
-			},
+		zap.Int("contentParts", len(parts)),
+		zap.Bool("structured", schema != nil))
+
+	params := openai.ChatCompletionNewParams{
+		Model: model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(systemPrompt),
+			openai.UserMessage(parts),
 		},
-	)
+	}
+	if schema != nil {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:        schema.Name,
+					Description: openai.String(schema.Description),
+					Schema:      schema.JSONSchema(),
+					Strict:      openai.Bool(true),
+				},
+			},
+		}
+	}
+
+	resp, err := client.Chat.Completions.New(ctx, params)
 
 	if err != nil {
 		p.log.Error("OpenAI API error", zap.Error(err))
-		return "", fmt.Errorf("OpenAI API error: %w", err)
+		return "", openaiError(err)
 	}
 
+	recordUsage(ctx, Usage{
+		Model:            p.model,
+		PromptTokens:     int(resp.Usage.PromptTokens),
+		CompletionTokens: int(resp.Usage.CompletionTokens),
+		CachedTokens:     int(resp.Usage.PromptTokensDetails.CachedTokens),
+		MediaTokens:      int(resp.Usage.PromptTokensDetails.AudioTokens),
+	})
+
 	if len(resp.Choices) == 0 {
 		p.log.Warn("No response choices from OpenAI")
 		return "", fmt.Errorf("no response from OpenAI")
@@ -82,6 +127,25 @@ func (p *OpenAIProvider) GenerateContent(ctx context.Context, systemPrompt strin
 	return result, nil
 }
 
+// openaiError classifies an OpenAI error. A 429 with code insufficient_quota
+// means the account is out of credit rather than rate limited.
+func openaiError(err error) *APIError {
+	var oErr *openai.Error
+	if !errors.As(err, &oErr) {
+		return newAPIError("OpenAI", 0, nil, err)
+	}
+
+	var header http.Header
+	if oErr.Response != nil {
+		header = oErr.Response.Header
+	}
+	apiErr := newAPIError("OpenAI", oErr.StatusCode, header, err)
+	if oErr.Code == "insufficient_quota" {
+		apiErr.Kind = ErrQuotaExhausted
+	}
+	return apiErr
+}
+
 func (p *OpenAIProvider) buildContentParts(contents []*chat.Content) []openai.ChatCompletionContentPartUnionParam {
 	var parts []openai.ChatCompletionContentPartUnionParam
 
@@ -127,10 +191,10 @@ func (p *OpenAIProvider) buildContentParts(contents []*chat.Content) []openai.Ch
 	return parts
 }
 
+// getAudioFormat names the input_audio format of mimeType, which
+// Capabilities limits to WAV and MP3.
 func getAudioFormat(mimeType string) string {
 	switch mimeType {
-	case "audio/wav":
-		return "wav"
 	case "audio/mpeg", "audio/mp3":
 		return "mp3"
 	default:
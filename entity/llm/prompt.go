@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/soaringk/msg-asst/entity/config"
+)
+
+// userTemplateName is the block a prompt file defines to replace the default
+// user preamble.
+const userTemplateName = "user"
+
+// defaultUserTemplate is the preamble placed before the messages, or before
+// the merged notes of a chunked summary, when a prompt file defines none.
+const defaultUserTemplate = `群聊名称：{{.Group}}
+消息时间范围：{{.TimeRange}}
+消息数量：{{.MessageCount}}
+
+{{.Instructions}}
+{{- if .Notes}}以下是按时间顺序分段整理的讨论要点，共 {{.Notes}} 段。请把它们合并为一份完整、连贯的纪要，只输出结果本身：
+{{- else}}请基于以下消息生成纪要，只输出结果本身：{{end}}`
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+var defaultUser = template.Must(template.New(userTemplateName).Funcs(promptFuncs).Parse(defaultUserTemplate))
+
+// PromptData holds the variables available to prompt templates, e.g.
+// {{.Group}} or {{join .Participants "、"}}.
+type PromptData struct {
+	Group        string
+	Date         string
+	TimeRange    string
+	MessageCount int
+	Participants []string
+	Owner        string // nickname of the logged-in account
+	Previous     string // the group's last minutes for rolling summaries
+	Detailed     bool
+	Structured   bool
+	Notes        int    // note segments of a chunked summary, 0 otherwise
+	Instructions string // built-in requirements for rolling and detailed summaries
+}
+
+// promptTemplate is a parsed prompt file. Its body is the system prompt; an
+// optional {{define "user"}} block replaces the default user preamble.
+type promptTemplate struct {
+	source string
+	tmpl   *template.Template
+}
+
+func parsePrompt(path, source string) (*promptTemplate, error) {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(promptFuncs).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template %s: %w", path, err)
+	}
+	return &promptTemplate{source: source, tmpl: tmpl}, nil
+}
+
+// render returns the system prompt and the user preamble for data.
+func (p *promptTemplate) render(data PromptData) (string, string, error) {
+	var system, user strings.Builder
+	if err := p.tmpl.Execute(&system, data); err != nil {
+		return "", "", fmt.Errorf("failed to render system prompt: %w", err)
+	}
+
+	userTmpl := p.tmpl.Lookup(userTemplateName)
+	if userTmpl == nil {
+		userTmpl = defaultUser
+	}
+	if err := userTmpl.Execute(&user, data); err != nil {
+		return "", "", fmt.Errorf("failed to render user prompt: %w", err)
+	}
+	return strings.TrimSpace(system.String()), strings.TrimSpace(user.String()), nil
+}
+
+// promptPath returns the prompt file for a request: the named template in
+// PROMPT_DIR if a command asked for one, else the group's system prompt file.
+func promptPath(cfg *config.Config, name string) (string, error) {
+	if name == "" {
+		return cfg.SystemPromptFile, nil
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid prompt template name %q", name)
+	}
+	if filepath.Ext(name) == "" {
+		name += ".txt"
+	}
+	return filepath.Join(cfg.PromptDir, name), nil
+}
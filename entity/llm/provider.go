@@ -8,4 +8,13 @@ import (
 
 type Provider interface {
 	GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error)
+	// Capabilities describes the media and request sizes the provider
+	// accepts
+	Capabilities() Capabilities
+}
+
+// StructuredProvider is implemented by providers that can constrain their
+// response to a JSON schema. The returned text is the JSON document.
+type StructuredProvider interface {
+	GenerateStructured(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error)
 }
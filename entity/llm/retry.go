@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// retryPolicy controls retries of rate-limited and unavailable requests.
+type retryPolicy struct {
+	maxRetries int
+	base       time.Duration // first backoff, doubled on each retry
+	maxDelay   time.Duration // cap on backoff and on a server's Retry-After
+	timeout    time.Duration // per attempt, 0 for none
+}
+
+func retryPolicyFor(cfg *config.Config) retryPolicy {
+	return retryPolicy{
+		maxRetries: cfg.LLMMaxRetries,
+		base:       time.Duration(cfg.LLMRetryBaseSeconds) * time.Second,
+		maxDelay:   time.Duration(cfg.LLMRetryMaxSeconds) * time.Second,
+		timeout:    time.Duration(cfg.LLMTimeoutSeconds) * time.Second,
+	}
+}
+
+// do calls fn until it succeeds, fails with an error that is not retryable,
+// or runs out of retries. A Retry-After longer than maxDelay is not waited
+// for, so a fallback provider can take over instead.
+func (rp retryPolicy) do(ctx context.Context, name string, fn func(ctx context.Context) (string, error)) (string, error) {
+	for attempt := 0; ; attempt++ {
+		text, err := rp.attempt(ctx, fn)
+		if err == nil {
+			return text, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		kind := ErrorKindOf(err)
+		if !kind.Retryable() || attempt >= rp.maxRetries {
+			return "", err
+		}
+		delay := rp.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			if apiErr.RetryAfter > rp.maxDelay {
+				return "", err
+			}
+			delay = apiErr.RetryAfter
+		}
+
+		logging.Warn("LLM request failed, retrying",
+			zap.String("provider", name),
+			zap.Stringer("kind", kind),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (rp retryPolicy) attempt(ctx context.Context, fn func(ctx context.Context) (string, error)) (string, error) {
+	if rp.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rp.timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// backoff returns the jittered delay before retry attempt+1: a random value
+// between half and all of base*2^attempt, capped at maxDelay.
+func (rp retryPolicy) backoff(attempt int) time.Duration {
+	d := rp.base << attempt
+	if d <= 0 || d > rp.maxDelay {
+		d = rp.maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryProvider retries the requests of the provider it wraps.
+type retryProvider struct {
+	Provider
+	name   string
+	policy retryPolicy
+}
+
+// retryStructuredProvider is a retryProvider for a StructuredProvider.
+type retryStructuredProvider struct {
+	*retryProvider
+	structured StructuredProvider
+}
+
+// withRetry wraps p so that transient errors are retried with policy,
+// keeping p's optional interfaces.
+func withRetry(p Provider, name string, policy retryPolicy) Provider {
+	r := &retryProvider{Provider: p, name: name, policy: policy}
+	if sp, ok := p.(StructuredProvider); ok {
+		return &retryStructuredProvider{retryProvider: r, structured: sp}
+	}
+	return r
+}
+
+func (r *retryProvider) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	return r.policy.do(ctx, r.name, func(ctx context.Context) (string, error) {
+		return r.Provider.GenerateContent(ctx, systemPrompt, contents)
+	})
+}
+
+func (r *retryStructuredProvider) GenerateStructured(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
+	return r.policy.do(ctx, r.name, func(ctx context.Context) (string, error) {
+		return r.structured.GenerateStructured(ctx, systemPrompt, contents, schema)
+	})
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"google.golang.org/genai"
+)
+
+// flakyProvider fails with the queued errors before answering.
+type flakyProvider struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyProvider) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return "", err
+	}
+	return "ok", nil
+}
+
+func (f *flakyProvider) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+func TestRetry(t *testing.T) {
+	policy := retryPolicy{maxRetries: 2, base: time.Millisecond, maxDelay: 10 * time.Millisecond}
+	rateLimited := &APIError{Provider: "test", Kind: ErrRateLimited, StatusCode: 429, Err: errors.New("slow down")}
+
+	tests := []struct {
+		name  string
+		errs  []error
+		calls int
+		kind  ErrorKind // of the returned error, ErrUnknown for success
+	}{
+		{"transient", []error{rateLimited, &APIError{Kind: ErrUnavailable, Err: errors.New("503")}}, 3, ErrUnknown},
+		{"out of retries", []error{rateLimited, rateLimited, rateLimited}, 3, ErrRateLimited},
+		{"bad request", []error{&APIError{Kind: ErrBadRequest, Err: errors.New("400")}}, 1, ErrBadRequest},
+		{"quota", []error{&APIError{Kind: ErrQuotaExhausted, Err: errors.New("429")}}, 1, ErrQuotaExhausted},
+		{"long retry-after", []error{&APIError{Kind: ErrRateLimited, RetryAfter: time.Hour, Err: errors.New("429")}}, 1, ErrRateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &flakyProvider{errs: tt.errs}
+			p := withRetry(inner, "test", policy)
+			text, err := p.GenerateContent(context.Background(), "", nil)
+			if inner.calls != tt.calls {
+				t.Errorf("calls = %d, want %d", inner.calls, tt.calls)
+			}
+			if tt.kind == ErrUnknown {
+				if err != nil || text != "ok" {
+					t.Errorf("GenerateContent() = %q, %v", text, err)
+				}
+				return
+			}
+			if got := ErrorKindOf(err); got != tt.kind {
+				t.Errorf("ErrorKindOf(%v) = %v, want %v", err, got, tt.kind)
+			}
+		})
+	}
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	policy := retryPolicy{timeout: 10 * time.Millisecond}
+	_, err := policy.do(context.Background(), "test", func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	if ErrorKindOf(err) != ErrUnavailable {
+		t.Errorf("timed-out attempt: ErrorKindOf(%v) = %v", err, ErrorKindOf(err))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := retryPolicy{base: 2 * time.Second, maxDelay: 10 * time.Second}
+	for attempt, want := range []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if d := policy.backoff(attempt); d < want/2 || d > want {
+			t.Errorf("backoff(%d) = %v, want within [%v, %v]", attempt, d, want/2, want)
+		}
+	}
+}
+
+func TestClassifyErrors(t *testing.T) {
+	now := time.Date(2025, 3, 5, 10, 0, 0, 0, time.UTC)
+	header := http.Header{}
+	header.Set("Retry-After", "7")
+	if d := parseRetryAfter(header, now); d != 7*time.Second {
+		t.Errorf("parseRetryAfter(seconds) = %v", d)
+	}
+	header.Set("Retry-After", now.Add(time.Minute).Format(http.TimeFormat))
+	if d := parseRetryAfter(header, now); d != time.Minute {
+		t.Errorf("parseRetryAfter(date) = %v", d)
+	}
+
+	daily := genai.APIError{Code: 429, Status: "RESOURCE_EXHAUSTED", Details: []map[string]any{
+		{"@type": "type.googleapis.com/google.rpc.QuotaFailure", "violations": []any{
+			map[string]any{"quotaId": "GenerateRequestsPerDayPerProjectPerModel-FreeTier"},
+		}},
+		{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "33s"},
+	}}
+	if err := geminiError(daily); err.Kind != ErrQuotaExhausted || err.RetryAfter != 33*time.Second {
+		t.Errorf("geminiError(daily quota) = %+v", err)
+	}
+	if err := geminiError(genai.APIError{Code: 503}); err.Kind != ErrUnavailable {
+		t.Errorf("geminiError(503) = %+v", err)
+	}
+	if err := geminiError(genai.APIError{Code: 400}); err.Kind != ErrBadRequest {
+		t.Errorf("geminiError(400) = %+v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package llm
+
+// SchemaType is the JSON type of a schema node.
+type SchemaType string
+
+const (
+	SchemaObject  SchemaType = "object"
+	SchemaArray   SchemaType = "array"
+	SchemaString  SchemaType = "string"
+	SchemaBoolean SchemaType = "boolean"
+)
+
+// Schema is the subset of JSON Schema that both Gemini and OpenAI structured
+// output accept. Every property of an object is required, as OpenAI's strict
+// mode demands, so optional values are modelled as empty strings or arrays.
+type Schema struct {
+	Name        string // names the top-level schema, for OpenAI
+	Type        SchemaType
+	Description string
+	Properties  []Property // objects only, in output order
+	Items       *Schema    // arrays only
+}
+
+// Property is one named field of an object schema.
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+// JSONSchema returns s as a JSON Schema document.
+func (s *Schema) JSONSchema() map[string]any {
+	out := map[string]any{"type": string(s.Type)}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+
+	switch s.Type {
+	case SchemaObject:
+		properties := make(map[string]any, len(s.Properties))
+		required := make([]string, len(s.Properties))
+		for i, p := range s.Properties {
+			properties[p.Name] = p.Schema.JSONSchema()
+			required[i] = p.Name
+		}
+		out["properties"] = properties
+		out["required"] = required
+		out["additionalProperties"] = false
+	case SchemaArray:
+		if s.Items != nil {
+			out["items"] = s.Items.JSONSchema()
+		}
+	}
+	return out
+}
+
+func stringSchema(description string) *Schema {
+	return &Schema{Type: SchemaString, Description: description}
+}
+
+func arraySchema(description string, items *Schema) *Schema {
+	return &Schema{Type: SchemaArray, Description: description, Items: items}
+}
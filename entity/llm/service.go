@@ -2,10 +2,15 @@ package llm
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/soaringk/msg-asst/entity/chat"
@@ -15,10 +20,12 @@ import (
 )
 
 type Service struct {
-	provider     atomic.Pointer[Provider]
-	systemPrompt atomic.Value
-	watcher      *fsnotify.Watcher
-	stopWatcher  chan struct{}
+	provider    atomic.Pointer[Provider]
+	overrides   sync.Map // model or name/model -> Provider, for groups overriding LLM_MODEL or selecting a provider
+	noSchema    sync.Map // Provider -> struct{}, for providers that rejected the minutes schema
+	prompts     sync.Map // prompt file -> prompt text
+	watcher     *fsnotify.Watcher
+	stopWatcher chan struct{}
 }
 
 func New() *Service {
@@ -26,7 +33,9 @@ func New() *Service {
 		stopWatcher: make(chan struct{}),
 	}
 
-	if err := s.loadSystemPrompt(); err != nil {
+	s.startSystemPromptWatcher()
+
+	if err := s.loadSystemPrompt(config.GetConfig().SystemPromptFile); err != nil {
 		logging.Fatal("Failed to load initial system prompt", zap.Error(err))
 	}
 
@@ -37,87 +46,353 @@ func New() *Service {
 		s.recreateProvider()
 	})
 
-	s.startSystemPromptWatcher()
-
 	return s
 }
 
 func (s *Service) recreateProvider() {
 	cfg := config.GetConfig()
-	providerType := cfg.LLMProvider
+	p, err := newChain(cfg, cfg.PrimaryProvider())
+	if err != nil {
+		logging.Error("Failed to create provider", zap.Error(err))
+		return
+	}
+
+	s.provider.Store(&p)
+	s.overrides.Clear()
+	s.noSchema.Clear()
+	logging.Info("LLM provider active", zap.String("type", cfg.LLMProvider), zap.Int("fallbacks", len(cfg.LLMFallbacks)))
+}
+
+// providerFor returns the provider for a group's effective config and the
+// model it answers with. Groups overriding the model get their own provider,
+// created on first use. Groups selecting a named provider use only that
+// provider, so their messages never reach the primary provider or a
+// fallback.
+func (s *Service) providerFor(cfg *config.Config) (Provider, string, error) {
+	if cfg.LLMGroupProvider != "" {
+		pc, ok := cfg.NamedProvider(cfg.LLMGroupProvider)
+		if !ok {
+			return nil, "", fmt.Errorf("unknown provider %q, add it to LLM_PROVIDERS", cfg.LLMGroupProvider)
+		}
+		if cfg.LLMModel != config.GetConfig().LLMModel {
+			pc.Model = cfg.LLMModel
+		}
+		key := pc.Name + "/" + pc.Model
+		if cached, ok := s.overrides.Load(key); ok {
+			return cached.(Provider), pc.Model, nil
+		}
+		p, err := newProvider(pc, retryPolicyFor(cfg))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create provider %s: %w", pc.Name, err)
+		}
+		actual, _ := s.overrides.LoadOrStore(key, p)
+		return actual.(Provider), pc.Model, nil
+	}
+
+	p := s.provider.Load()
+	if p == nil {
+		return nil, "", fmt.Errorf("provider not initialized")
+	}
+	if cfg.LLMModel == config.GetConfig().LLMModel {
+		return *p, cfg.LLMModel, nil
+	}
 
+	if cached, ok := s.overrides.Load(cfg.LLMModel); ok {
+		return cached.(Provider), cfg.LLMModel, nil
+	}
+	override, err := newChain(cfg, cfg.PrimaryProvider())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create provider for model %s: %w", cfg.LLMModel, err)
+	}
+	actual, _ := s.overrides.LoadOrStore(cfg.LLMModel, override)
+	return actual.(Provider), cfg.LLMModel, nil
+}
+
+// newChain returns the provider for primary, wrapped in a fallback chain
+// with circuit breakers when LLM_FALLBACKS is set.
+func newChain(cfg *config.Config, primary config.ProviderConfig) (Provider, error) {
+	policy := retryPolicyFor(cfg)
+	p, err := newProvider(primary, policy)
+	if err != nil || len(cfg.LLMFallbacks) == 0 {
+		return p, err
+	}
+
+	cooldown := time.Duration(cfg.LLMBreakerCooldownSeconds) * time.Second
+	chain := &fallbackChain{}
+	for i, pc := range append([]config.ProviderConfig{primary}, cfg.LLMFallbacks...) {
+		if i > 0 {
+			if p, err = newProvider(pc, policy); err != nil {
+				return nil, fmt.Errorf("failed to create fallback provider %s: %w", pc.Name, err)
+			}
+		}
+		chain.members = append(chain.members, &chainMember{
+			name:     pc.Name,
+			model:    pc.Model,
+			provider: p,
+			breaker:  newBreaker(cfg.LLMBreakerFailures, cooldown),
+		})
+	}
+	return chain, nil
+}
+
+// newProvider creates the provider described by pc, retrying transient
+// errors with policy and degrading media it cannot take.
+func newProvider(pc config.ProviderConfig, policy retryPolicy) (Provider, error) {
 	var p Provider
 	var err error
 
-	if providerType == "gemini" {
+	switch pc.Provider {
+	case "gemini":
 		p, err = NewGeminiProvider(context.Background(), GeminiConfig{
-			APIKey: cfg.LLMAPIKey,
-			Model:  cfg.LLMModel,
+			APIKey: pc.APIKey,
+			Model:  pc.Model,
+		})
+	case "anthropic":
+		p = NewAnthropicProvider(AnthropicConfig{
+			APIKey:    pc.APIKey,
+			BaseURL:   pc.BaseURL,
+			Model:     pc.Model,
+			MaxTokens: pc.MaxOutputTokens,
 		})
-	} else {
+	case LocalAPIOllama, LocalAPILlamaCpp:
+		p = NewLocalProvider(LocalConfig{
+			API:           pc.Provider,
+			BaseURL:       pc.BaseURL,
+			APIKey:        pc.APIKey,
+			Model:         pc.Model,
+			ContextTokens: pc.ContextTokens,
+			MaxTokens:     pc.MaxOutputTokens,
+		})
+	default:
 		// Default to OpenAI
 		p = NewOpenAIProvider(OpenAIConfig{
-			APIKey:  cfg.LLMAPIKey,
-			BaseURL: cfg.LLMBaseURL,
-			Model:   cfg.LLMModel,
+			APIKey:  pc.APIKey,
+			BaseURL: pc.BaseURL,
+			Model:   pc.Model,
 		})
 	}
-
 	if err != nil {
-		logging.Error("Failed to create provider", zap.Error(err))
-		return
+		return nil, err
 	}
 
-	s.provider.Store(&p)
-	logging.Info("LLM provider active", zap.String("type", providerType))
+	return withCapabilities(withRetry(p, pc.Name, policy), pc.Name), nil
 }
 
-func (s *Service) loadSystemPrompt() error {
-	cfg := config.GetConfig()
-	systemPromptBytes, err := os.ReadFile(cfg.SystemPromptFile)
+// loadSystemPrompt reads and parses a prompt file into the cache and watches
+// it for changes. A file that fails to parse leaves the cached version in use.
+func (s *Service) loadSystemPrompt(path string) error {
+	systemPromptBytes, err := os.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to read system prompt: %w", err)
 	}
 
-	prompt := strings.TrimSpace(string(systemPromptBytes))
-	s.systemPrompt.Store(prompt)
+	prompt, err := parsePrompt(path, strings.TrimSpace(string(systemPromptBytes)))
+	if err != nil {
+		return err
+	}
+	if _, loaded := s.prompts.Swap(path, prompt); !loaded {
+		if err := s.watcher.Add(path); err != nil {
+			logging.Warn("Failed to watch system prompt file", zap.String("file", path), zap.Error(err))
+		}
+	}
 
-	logging.Info("System prompt loaded", zap.Int("length", len(prompt)))
+	logging.Info("System prompt loaded", zap.String("file", path), zap.Int("length", len(prompt.source)))
 	return nil
 }
 
-func (s *Service) getSystemPrompt() string {
-	return s.systemPrompt.Load().(string)
+// ReloadPrompts re-reads every prompt file loaded so far and returns how many
+// were reloaded.
+func (s *Service) ReloadPrompts() (int, error) {
+	var paths []string
+	s.prompts.Range(func(key, _ any) bool {
+		paths = append(paths, key.(string))
+		return true
+	})
+
+	var errs []error
+	for _, path := range paths {
+		if err := s.loadSystemPrompt(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(paths) - len(errs), errors.Join(errs...)
+}
+
+func (s *Service) getSystemPrompt(path string) (*promptTemplate, error) {
+	if prompt, ok := s.prompts.Load(path); ok {
+		return prompt.(*promptTemplate), nil
+	}
+	if err := s.loadSystemPrompt(path); err != nil {
+		return nil, err
+	}
+	prompt, _ := s.prompts.Load(path)
+	return prompt.(*promptTemplate), nil
+}
+
+// Summary is the model output along with what produced it, so archived
+// minutes can be traced back to a model and prompt version.
+type Summary struct {
+	Text       string
+	Minutes    *Minutes // set when the provider returned structured output
+	Model      string
+	PromptHash string
+	Usage      []Usage // tokens used per model, including chunk and retried requests
 }
 
-func (s *Service) GenerateSummary(ctx context.Context, groupTopic string, timeRange string, messageCount int, messages []*chat.Content) (string, error) {
-	p := s.provider.Load()
-	if p == nil {
-		return "", fmt.Errorf("provider not initialized")
+// SummaryRequest describes one summary to generate.
+type SummaryRequest struct {
+	GroupTopic   string
+	TimeRange    string
+	MessageCount int
+	Messages     []*chat.Content
+	Participants []string
+	Owner        string // nickname of the logged-in account
+	Template     string // prompt template in PROMPT_DIR to use instead of the group's
+	Detailed     bool   // ask for a longer summary that keeps arguments and figures
+	Previous     string // the group's last minutes, to report only what changed since
+}
+
+// GenerateSummary summarizes the messages of req. When it fails, the
+// returned Summary still carries the usage of the requests that were made.
+func (s *Service) GenerateSummary(ctx context.Context, req SummaryRequest) (Summary, error) {
+	ctx, usage := withUsage(ctx)
+	cfg := config.ForGroup(req.GroupTopic)
+	p, model, err := s.providerFor(cfg)
+	if err != nil {
+		return Summary{}, err
+	}
+	path, err := promptPath(cfg, req.Template)
+	if err != nil {
+		return Summary{}, err
+	}
+	prompt, err := s.getSystemPrompt(path)
+	if err != nil {
+		return Summary{}, err
 	}
 
-	// Prepare the preamble text
-	preamble := fmt.Sprintf(
-		"群聊名称：%s\n消息时间范围：%s\n消息数量：%d\n\n请基于以下消息生成纪要，只输出结果本身：\n<messages>\n",
-		groupTopic, timeRange, messageCount,
-	)
+	data := PromptData{
+		Group:        req.GroupTopic,
+		Date:         time.Now().Format("2006年1月2日 Monday"),
+		TimeRange:    req.TimeRange,
+		MessageCount: req.MessageCount,
+		Participants: req.Participants,
+		Owner:        req.Owner,
+		Previous:     req.Previous,
+		Detailed:     req.Detailed,
+	}
 
-	var requestContents []*chat.Content
-	requestContents = append(requestContents, &chat.Content{
-		Type: chat.ContentTypeText,
-		Text: preamble,
-	})
+	limits := limitsFor(p, cfg.LLMChunkMaxTokens, cfg.LLMChunkMaxBytes)
+	chunks := splitChunks(req.Messages, limits)
 
-	// Append actual messages
-	requestContents = append(requestContents, messages...)
+	var notes []string
+	if len(chunks) > 1 {
+		notes, err = condenseChunks(ctx, p, req, chunks, limits)
+		if err != nil {
+			return Summary{Usage: usage.usage()}, err
+		}
+		data.Notes = len(notes)
+	}
 
-	// Append closing tag
-	requestContents = append(requestContents, &chat.Content{
-		Type: chat.ContentTypeText,
-		Text: "\n</messages>",
+	var summary Summary
+	err = tryProviders(ctx, p, model, func(p Provider, model string) error {
+		render := func(structured bool) (string, []*chat.Content, error) {
+			data.Structured = structured
+			data.Instructions = instructions(req, structured)
+			systemPrompt, preamble, err := prompt.render(data)
+			if err != nil {
+				return "", nil, err
+			}
+			if len(chunks) > 1 {
+				return systemPrompt, wrap(preamble+"\n<notes>\n", notesContents(notes), "\n</notes>"), nil
+			}
+			messages := req.Messages
+			if len(chunks) == 1 {
+				// Oversized media may have been replaced by placeholders
+				messages = chunks[0]
+			}
+			return systemPrompt, wrap(preamble+"\n<messages>\n", messages, "\n</messages>"), nil
+		}
+
+		summary = Summary{
+			Model:      model,
+			PromptHash: promptHash(prompt.source),
+		}
+		sp, structured := p.(StructuredProvider)
+		_, noSchema := s.noSchema.Load(p)
+		schemaRejected := false
+		if structured && cfg.LLMStructuredOutput && !noSchema {
+			systemPrompt, contents, err := render(true)
+			if err != nil {
+				return err
+			}
+			text, err := sp.GenerateStructured(ctx, systemPrompt, contents, MinutesSchema)
+			switch {
+			case ErrorKindOf(err) == ErrBadRequest && ctx.Err() == nil:
+				// Some OpenAI-compatible servers reject response_format
+				schemaRejected = true
+				logging.Warn("Structured summary rejected, retrying as text",
+					zap.String("group", req.GroupTopic),
+					zap.String("model", model),
+					zap.Error(err))
+			case err != nil:
+				return err
+			default:
+				minutes, err := ParseMinutes(text)
+				if err == nil {
+					summary.Text = text
+					summary.Minutes = minutes
+					return nil
+				}
+				logging.Warn("Invalid structured summary, retrying as text",
+					zap.String("group", req.GroupTopic),
+					zap.Error(err))
+			}
+		}
+
+		systemPrompt, contents, err := render(false)
+		if err != nil {
+			return err
+		}
+		summary.Text, err = p.GenerateContent(ctx, systemPrompt, contents)
+		if err == nil && schemaRejected {
+			// The text request went through, so it was the schema the
+			// provider rejected; skip it from now on
+			s.noSchema.Store(p, struct{}{})
+		}
+		return err
 	})
+	if err != nil {
+		return Summary{Usage: usage.usage()}, err
+	}
+	summary.Usage = usage.usage()
+	return summary, nil
+}
+
+// instructions returns the per-request requirements placed before the
+// messages.
+func instructions(req SummaryRequest, structured bool) string {
+	nothingNew := "只输出“暂无重要更新”"
+	if structured {
+		nothingNew = "has_update 为 false"
+	}
 
-	return (*p).GenerateContent(ctx, s.getSystemPrompt(), requestContents)
+	var sb strings.Builder
+	if previous := strings.TrimSpace(req.Previous); previous != "" {
+		sb.WriteString("上一次已发送的纪要（仅作背景，不要重复）：\n<previous>\n")
+		sb.WriteString(previous)
+		sb.WriteString("\n</previous>\n\n")
+		sb.WriteString("要求：只报告上一次纪要之后的新进展。每条话题、决定或待办的状态变化前标注【新增】、【更新】或【已解决】；" +
+			"上次已报告且没有变化的内容不要再写。如果没有任何新进展，" + nothingNew + "。\n")
+	}
+	if req.Detailed {
+		sb.WriteString("要求：请输出详细版纪要，保留关键论据、数据和每位参与者的主要观点。\n")
+	}
+	return sb.String()
+}
+
+func promptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
 }
 
 func (s *Service) startSystemPromptWatcher() {
@@ -127,12 +402,6 @@ func (s *Service) startSystemPromptWatcher() {
 	}
 	s.watcher = watcher
 
-	cfg := config.GetConfig()
-	if err := watcher.Add(cfg.SystemPromptFile); err != nil {
-		watcher.Close()
-		logging.Fatal("Failed to watch system prompt file", zap.Error(err))
-	}
-
 	go func() {
 		defer watcher.Close()
 		for {
@@ -142,8 +411,8 @@ func (s *Service) startSystemPromptWatcher() {
 					return
 				}
 				if event.Has(fsnotify.Write) {
-					logging.Info("System prompt file changed, reloading...")
-					if err := s.loadSystemPrompt(); err != nil {
+					logging.Info("System prompt file changed, reloading...", zap.String("file", event.Name))
+					if err := s.loadSystemPrompt(event.Name); err != nil {
 						logging.Error("Error reloading system prompt", zap.Error(err))
 					}
 				}
@@ -157,7 +426,7 @@ func (s *Service) startSystemPromptWatcher() {
 			}
 		}
 	}()
-	logging.Debug("File watcher started", zap.String("file", cfg.SystemPromptFile))
+	logging.Debug("File watcher started")
 }
 
 func (s *Service) Close() {
@@ -2,8 +2,12 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/soaringk/msg-asst/entity/chat"
@@ -16,6 +20,7 @@ type MockProvider struct {
 	LastContents     []*chat.Content
 	MockResponse     string
 	MockError        error
+	Caps             Capabilities
 }
 
 func (m *MockProvider) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
@@ -24,6 +29,10 @@ func (m *MockProvider) GenerateContent(ctx context.Context, systemPrompt string,
 	return m.MockResponse, m.MockError
 }
 
+func (m *MockProvider) Capabilities() Capabilities {
+	return m.Caps
+}
+
 func TestGenerateSummaryPromptFormatting(t *testing.T) {
 	// Setup env
 	os.Setenv("LLM_API_KEY", "test-key")
@@ -65,7 +74,12 @@ func TestGenerateSummaryPromptFormatting(t *testing.T) {
 	count := 5
 
 	// Execute
-	_, err = svc.GenerateSummary(context.Background(), group, timeRange, count, messages)
+	_, err = svc.GenerateSummary(context.Background(), SummaryRequest{
+		GroupTopic:   group,
+		TimeRange:    timeRange,
+		MessageCount: count,
+		Messages:     messages,
+	})
 	if err != nil {
 		t.Fatalf("GenerateSummary failed: %v", err)
 	}
@@ -103,3 +117,373 @@ func TestGenerateSummaryPromptFormatting(t *testing.T) {
 		t.Errorf("Expected closing tag, got %q", contents[2].Text)
 	}
 }
+
+// recordingProvider records every request and answers with a canned text
+// per system prompt. It is safe for the concurrent chunk requests.
+type recordingProvider struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (r *recordingProvider) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sb strings.Builder
+	for _, c := range contents {
+		sb.WriteString(c.Text)
+	}
+	r.requests = append(r.requests, sb.String())
+
+	switch systemPrompt {
+	case mapSystemPrompt:
+		return "chunk notes", nil
+	case mergeSystemPrompt:
+		return "merged notes", nil
+	default:
+		return "final minutes", nil
+	}
+}
+
+func (r *recordingProvider) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+func TestGenerateSummaryMapReduce(t *testing.T) {
+	os.Setenv("LLM_API_KEY", "test-key")
+	os.Setenv("SYSTEM_PROMPT_FILE", "test_prompt.txt")
+	os.Setenv("LLM_CHUNK_MAX_TOKENS", "100")
+	defer func() {
+		os.Unsetenv("LLM_API_KEY")
+		os.Unsetenv("SYSTEM_PROMPT_FILE")
+		os.Unsetenv("LLM_CHUNK_MAX_TOKENS")
+		os.Remove("test_prompt.txt")
+	}()
+
+	if err := os.WriteFile("test_prompt.txt", []byte("You are a bot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := New()
+	defer svc.Close()
+
+	recorder := &recordingProvider{}
+	var p Provider = recorder
+	svc.provider.Store(&p)
+
+	// 20 messages of ~30 tokens each, 3 per chunk
+	var messages []*chat.Content
+	for i := 0; i < 20; i++ {
+		messages = append(messages, &chat.Content{
+			Type: chat.ContentTypeText,
+			Text: fmt.Sprintf("[10:%02d] 张三: %s", i, strings.Repeat("讨论", 14)),
+		})
+	}
+
+	summary, err := svc.GenerateSummary(context.Background(), SummaryRequest{
+		GroupTopic:   "Big Group",
+		TimeRange:    "10:00 - 10:19",
+		MessageCount: len(messages),
+		Messages:     messages,
+	})
+	if err != nil {
+		t.Fatalf("GenerateSummary failed: %v", err)
+	}
+	if summary.Text != "final minutes" {
+		t.Errorf("Expected final minutes, got %q", summary.Text)
+	}
+
+	var maps int
+	for _, req := range recorder.requests {
+		if strings.Contains(req, "请整理本段要点") {
+			maps++
+		}
+	}
+	if maps != 7 {
+		t.Errorf("Expected 7 chunk requests, got %d", maps)
+	}
+
+	final := recorder.requests[len(recorder.requests)-1]
+	if !strings.Contains(final, "10:00 - 10:19") || !strings.Contains(final, "<notes>") {
+		t.Errorf("Final request should merge notes with the original preamble:\n%s", final)
+	}
+	if strings.Contains(final, "讨论讨论") {
+		t.Error("Final request should not contain raw messages")
+	}
+}
+
+func TestPromptTemplate(t *testing.T) {
+	source := `你是 {{.Owner}} 的助理，正在整理「{{.Group}}」的纪要。
+{{define "user"}}参与者：{{join .Participants "、"}}
+{{if .Previous}}上次：{{.Previous}}
+{{end}}请总结 {{.MessageCount}} 条消息：{{end}}`
+
+	prompt, err := parsePrompt("weekly.txt", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	system, user, err := prompt.render(PromptData{
+		Group:        "产品群",
+		Owner:        "王五",
+		Participants: []string{"张三", "李四"},
+		MessageCount: 12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if system != "你是 王五 的助理，正在整理「产品群」的纪要。" {
+		t.Errorf("Unexpected system prompt %q", system)
+	}
+	if user != "参与者：张三、李四\n请总结 12 条消息：" {
+		t.Errorf("Unexpected user prompt %q", user)
+	}
+
+	// A plain prompt file gets the default preamble
+	plain, err := parsePrompt("system_prompt.txt", "You are a bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, user, err = plain.render(PromptData{Group: "产品群", TimeRange: "10:00 - 11:00", MessageCount: 3, Notes: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "群聊名称：产品群\n消息时间范围：10:00 - 11:00\n消息数量：3\n\n以下是按时间顺序分段整理的讨论要点，共 2 段。请把它们合并为一份完整、连贯的纪要，只输出结果本身："
+	if user != want {
+		t.Errorf("Unexpected default preamble %q", user)
+	}
+
+	if _, err := parsePrompt("broken.txt", "{{.Group"); err == nil {
+		t.Error("Expected a parse error")
+	}
+	cfg := &config.Config{PromptDir: "prompts"}
+	if _, err := promptPath(cfg, "../secret"); err == nil {
+		t.Error("Expected template names with paths to be rejected")
+	}
+	if path, _ := promptPath(cfg, "周报"); path != filepath.Join("prompts", "周报.txt") {
+		t.Errorf("Unexpected template path %q", path)
+	}
+}
+
+func TestInstructionsCarryPreviousSummary(t *testing.T) {
+	if got := instructions(SummaryRequest{}, false); got != "" {
+		t.Errorf("Expected no instructions for a plain request, got %q", got)
+	}
+
+	got := instructions(SummaryRequest{Previous: "  - 决定周五发布  ", Detailed: true}, false)
+	for _, want := range []string{"<previous>\n- 决定周五发布\n</previous>", "【新增】", "【更新】", "【已解决】", "暂无重要更新", "详细版"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Instructions missing %q:\n%s", want, got)
+		}
+	}
+}
+
+// structuredMock answers schema requests with StructuredResponse and falls
+// back to MockProvider for plain ones.
+type structuredMock struct {
+	MockProvider
+	StructuredResponse string
+	StructuredError    error // returned by schema requests only
+	StructuredCalls    int
+	LastSchema         *Schema
+}
+
+func (m *structuredMock) GenerateStructured(ctx context.Context, systemPrompt string, contents []*chat.Content, schema *Schema) (string, error) {
+	m.LastSystemPrompt = systemPrompt
+	m.LastContents = contents
+	m.LastSchema = schema
+	m.StructuredCalls++
+	if m.StructuredError != nil {
+		return "", m.StructuredError
+	}
+	return m.StructuredResponse, m.MockError
+}
+
+func TestGenerateSummaryStructured(t *testing.T) {
+	os.Setenv("LLM_API_KEY", "test-key")
+	os.Setenv("SYSTEM_PROMPT_FILE", "test_prompt.txt")
+	defer func() {
+		os.Unsetenv("LLM_API_KEY")
+		os.Unsetenv("SYSTEM_PROMPT_FILE")
+		os.Remove("test_prompt.txt")
+	}()
+
+	if err := os.WriteFile("test_prompt.txt", []byte("You are a bot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := New()
+	defer svc.Close()
+
+	mock := &structuredMock{
+		MockProvider: MockProvider{MockResponse: "plain minutes"},
+		StructuredResponse: "```json\n" + `{"has_update": true, "key_points": ["周五发布"], "decisions": [],
+			"action_items": [{"task": "写发布说明", "owner": "李四", "due": "周四"}], "participants": ["李四"], "risks": []}` + "\n```",
+	}
+	var p Provider = mock
+	svc.provider.Store(&p)
+
+	req := SummaryRequest{
+		GroupTopic: "Test Group",
+		Messages:   []*chat.Content{{Type: chat.ContentTypeText, Text: "Hello"}},
+		Previous:   "上次的纪要",
+	}
+	summary, err := svc.GenerateSummary(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GenerateSummary failed: %v", err)
+	}
+	if mock.LastSchema != MinutesSchema {
+		t.Error("Expected the minutes schema to be requested")
+	}
+	if !strings.Contains(mock.LastContents[0].Text, "has_update 为 false") {
+		t.Errorf("Structured request should describe has_update:\n%s", mock.LastContents[0].Text)
+	}
+	m := summary.Minutes
+	if m == nil || !m.HasUpdate || len(m.KeyPoints) != 1 || len(m.ActionItems) != 1 || m.ActionItems[0].Owner != "李四" {
+		t.Fatalf("Unexpected minutes: %+v", m)
+	}
+
+	// Invalid JSON falls back to a plain request
+	mock.StructuredResponse = "not json"
+	summary, err = svc.GenerateSummary(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GenerateSummary failed: %v", err)
+	}
+	if summary.Minutes != nil || summary.Text != "plain minutes" {
+		t.Errorf("Expected plain fallback, got %+v", summary)
+	}
+
+	// A rejected schema falls back to a plain request and is not sent again
+	mock.StructuredError = &APIError{Provider: "test", Kind: ErrBadRequest, StatusCode: 400, Err: errors.New("response_format is not supported")}
+	mock.StructuredCalls = 0
+	for i := 0; i < 2; i++ {
+		summary, err = svc.GenerateSummary(context.Background(), req)
+		if err != nil {
+			t.Fatalf("GenerateSummary failed: %v", err)
+		}
+		if summary.Minutes != nil || summary.Text != "plain minutes" {
+			t.Errorf("Expected plain fallback, got %+v", summary)
+		}
+		if strings.Contains(mock.LastContents[0].Text, "has_update") {
+			t.Errorf("Plain request should not describe has_update:\n%s", mock.LastContents[0].Text)
+		}
+	}
+	if mock.StructuredCalls != 1 {
+		t.Errorf("Structured requests = %d, want 1", mock.StructuredCalls)
+	}
+}
+
+// billingMock reports usage for every request, then fails with err.
+type billingMock struct {
+	MockProvider
+	err error
+}
+
+func (m *billingMock) GenerateContent(ctx context.Context, systemPrompt string, contents []*chat.Content) (string, error) {
+	recordUsage(ctx, Usage{Model: "test-model", PromptTokens: 100, CompletionTokens: 10})
+	return "", m.err
+}
+
+func TestGenerateSummaryUsageOnError(t *testing.T) {
+	os.Setenv("LLM_API_KEY", "test-key")
+	os.Setenv("SYSTEM_PROMPT_FILE", "test_prompt.txt")
+	defer func() {
+		os.Unsetenv("LLM_API_KEY")
+		os.Unsetenv("SYSTEM_PROMPT_FILE")
+		os.Remove("test_prompt.txt")
+	}()
+
+	if err := os.WriteFile("test_prompt.txt", []byte("You are a bot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := New()
+	defer svc.Close()
+
+	var p Provider = &billingMock{err: errors.New("context length exceeded")}
+	svc.provider.Store(&p)
+
+	summary, err := svc.GenerateSummary(context.Background(), SummaryRequest{
+		GroupTopic: "Test Group",
+		Messages:   []*chat.Content{{Type: chat.ContentTypeText, Text: "Hello"}},
+	})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if len(summary.Usage) != 1 || summary.Usage[0].Requests != 1 || summary.Usage[0].PromptTokens != 100 {
+		t.Errorf("Usage of the failed summary = %+v", summary.Usage)
+	}
+}
+
+func TestMinutesSchemaIsStrict(t *testing.T) {
+	doc := MinutesSchema.JSONSchema()
+	if doc["additionalProperties"] != false {
+		t.Error("Objects must forbid additional properties")
+	}
+	required, _ := doc["required"].([]string)
+	if len(required) != len(MinutesSchema.Properties) || required[0] != "has_update" {
+		t.Errorf("Every property must be required, got %v", required)
+	}
+	items := doc["properties"].(map[string]any)["action_items"].(map[string]any)["items"].(map[string]any)
+	if items["additionalProperties"] != false {
+		t.Error("Nested objects must forbid additional properties")
+	}
+}
+
+func TestGenerateDigest(t *testing.T) {
+	os.Setenv("LLM_API_KEY", "test-key")
+	os.Setenv("SYSTEM_PROMPT_FILE", "test_prompt.txt")
+	defer func() {
+		os.Unsetenv("LLM_API_KEY")
+		os.Unsetenv("SYSTEM_PROMPT_FILE")
+		os.Remove("test_prompt.txt")
+	}()
+
+	if err := os.WriteFile("test_prompt.txt", []byte("You are a bot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := New()
+	defer svc.Close()
+
+	mockProvider := &MockProvider{MockResponse: "digest"}
+	var p Provider = mockProvider
+	svc.provider.Store(&p)
+
+	_, err := svc.GenerateDigest(context.Background(), DigestRequest{
+		Date: "2025年3月10日",
+		Items: []DigestItem{
+			{GroupTopic: "研发群", TimeRange: "09:00 - 10:00", Text: "决定周五发布"},
+			{GroupTopic: "产品群", TimeRange: "14:00 - 15:00", Text: "周五发布需要文案"},
+			{GroupTopic: "研发群", TimeRange: "16:00 - 17:00", Text: "发布推迟到周一"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GenerateDigest failed: %v", err)
+	}
+
+	if mockProvider.LastSystemPrompt != digestSystemPrompt {
+		t.Error("Digest should use the digest system prompt")
+	}
+	contents := mockProvider.LastContents
+	if len(contents) != 5 {
+		t.Fatalf("Expected 5 content parts (preamble, 3 summaries, closing), got %d", len(contents))
+	}
+	if !strings.Contains(contents[0].Text, "群聊数量：2") || !strings.Contains(contents[0].Text, "纪要数量：3") {
+		t.Errorf("Preamble should count distinct groups and summaries: %q", contents[0].Text)
+	}
+	if !strings.Contains(contents[2].Text, `group="产品群"`) || !strings.Contains(contents[2].Text, "周五发布需要文案") {
+		t.Errorf("Summary part malformed: %q", contents[2].Text)
+	}
+}
@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// ErrUnsupportedAudio is returned for voice messages in a format the
+// transcriber cannot read.
+var ErrUnsupportedAudio = errors.New("audio format not supported")
+
+// Transcriber turns a voice message into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio *chat.Content) (string, error)
+}
+
+// transcriptionFormats are the formats of OpenAI's /audio/transcriptions,
+// which self-hosted whisper servers accept too.
+var transcriptionFormats = Capabilities{MimeTypes: []string{
+	"audio/wav", "audio/x-wav", "audio/mpeg", "audio/mp3", "audio/ogg",
+	"audio/flac", "audio/mp4", "audio/m4a", "audio/webm",
+}}
+
+const transcribeSystemPrompt = "将这段语音逐字转写为文字，只输出转写内容，不要添加任何说明。听不清的部分用[听不清]标注；没有人声时输出空。"
+
+// NewTranscriber creates the transcriber selected by cfg.Transcribe, retrying
+// transient errors like summary requests do. It returns nil when
+// transcription is off.
+func NewTranscriber(cfg *config.Config) (Transcriber, error) {
+	tc := cfg.Transcribe
+	policy := retryPolicyFor(cfg)
+	policy.timeout = time.Duration(tc.TimeoutSeconds) * time.Second
+
+	var t Transcriber
+	switch tc.Provider {
+	case "":
+		return nil, nil
+	case config.TranscribeGemini:
+		p, err := NewGeminiProvider(context.Background(), GeminiConfig{
+			APIKey: tc.APIKey,
+			Model:  tc.Model,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Gemini transcriber: %w", err)
+		}
+		t = &providerTranscriber{provider: p, language: tc.Language}
+	default:
+		t = &httpTranscriber{
+			name:     tc.Provider,
+			baseURL:  strings.TrimRight(tc.BaseURL, "/"),
+			apiKey:   tc.APIKey,
+			model:    tc.Model,
+			language: tc.Language,
+			client:   &http.Client{},
+		}
+	}
+
+	logging.Info("Transcriber initialized",
+		zap.String("provider", tc.Provider),
+		zap.String("model", tc.Model))
+	return &retryTranscriber{Transcriber: t, name: tc.Provider, policy: policy}, nil
+}
+
+// retryTranscriber retries the requests of the transcriber it wraps.
+type retryTranscriber struct {
+	Transcriber
+	name   string
+	policy retryPolicy
+}
+
+func (r *retryTranscriber) Transcribe(ctx context.Context, audio *chat.Content) (string, error) {
+	return r.policy.do(ctx, r.name, func(ctx context.Context) (string, error) {
+		return r.Transcriber.Transcribe(ctx, audio)
+	})
+}
+
+// httpTranscriber posts audio to an OpenAI-compatible /audio/transcriptions
+// endpoint.
+type httpTranscriber struct {
+	name     string
+	baseURL  string
+	apiKey   string
+	model    string
+	language string
+	client   *http.Client
+}
+
+func (t *httpTranscriber) Transcribe(ctx context.Context, audio *chat.Content) (string, error) {
+	if !transcriptionFormats.Accepts(audio.MimeType) {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedAudio, audio.MimeType)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("model", t.model)
+	form.WriteField("response_format", "json")
+	if t.language != "" {
+		form.WriteField("language", t.language)
+	}
+	file, err := form.CreateFormFile("file", "voice."+audioExt(audio.MimeType))
+	if err != nil {
+		return "", fmt.Errorf("failed to build transcription request: %w", err)
+	}
+	file.Write(audio.Data)
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("failed to build transcription request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to build transcription request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", newAPIError(t.name, 0, nil, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read transcription response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError(t.name, resp.StatusCode, resp.Header, errors.New(localErrorMessage(data)))
+	}
+
+	var result struct {
+		Text  string `json:"text"`
+		Usage struct {
+			InputTokens       int `json:"input_tokens"`
+			OutputTokens      int `json:"output_tokens"`
+			InputTokenDetails struct {
+				AudioTokens int `json:"audio_tokens"`
+			} `json:"input_token_details"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to parse transcription response: %w", err)
+	}
+	// Only token-billed models report tokens; the others count as a request
+	recordUsage(ctx, Usage{
+		Model:            t.model,
+		PromptTokens:     result.Usage.InputTokens,
+		CompletionTokens: result.Usage.OutputTokens,
+		MediaTokens:      result.Usage.InputTokenDetails.AudioTokens,
+	})
+	return strings.TrimSpace(result.Text), nil
+}
+
+// providerTranscriber asks a multimodal model to transcribe the audio.
+type providerTranscriber struct {
+	provider Provider
+	language string
+}
+
+func (t *providerTranscriber) Transcribe(ctx context.Context, audio *chat.Content) (string, error) {
+	if !t.provider.Capabilities().Accepts(audio.MimeType) {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedAudio, audio.MimeType)
+	}
+
+	prompt := transcribeSystemPrompt
+	if t.language != "" {
+		prompt += fmt.Sprintf("语音的主要语言是 %s。", t.language)
+	}
+	text, err := t.provider.GenerateContent(ctx, prompt, []*chat.Content{audio})
+	return strings.TrimSpace(text), err
+}
+
+func audioExt(mimeType string) string {
+	switch mimeType {
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	case "audio/ogg":
+		return "ogg"
+	case "audio/flac":
+		return "flac"
+	case "audio/mp4", "audio/m4a":
+		return "m4a"
+	case "audio/webm":
+		return "webm"
+	default:
+		return "wav"
+	}
+}
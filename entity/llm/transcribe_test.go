@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+)
+
+func TestHTTPTranscriber(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("request = %s %v", r.URL.Path, r.Header)
+		}
+		if r.FormValue("model") != "whisper-1" || r.FormValue("language") != "zh" {
+			t.Errorf("form = %v", r.MultipartForm.Value)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("FormFile() error = %v", err)
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "voice.mp3" || string(data) != "ID3" {
+			t.Errorf("file = %s %q", header.Filename, data)
+		}
+		w.Write([]byte(`{"text":" 周五发布 ","usage":{"type":"tokens","input_tokens":30,"output_tokens":5,"input_token_details":{"audio_tokens":28}}}`))
+	}))
+	defer srv.Close()
+
+	tr := &httpTranscriber{name: "whisper", baseURL: srv.URL + "/v1", apiKey: "key", model: "whisper-1", language: "zh", client: srv.Client()}
+	ctx, used := CollectUsage(context.Background())
+	text, err := tr.Transcribe(ctx, &chat.Content{Type: chat.ContentTypeAudio, Data: []byte("ID3"), MimeType: "audio/mpeg"})
+	if err != nil || text != "周五发布" {
+		t.Errorf("Transcribe() = %q, %v", text, err)
+	}
+	want := []Usage{{Model: "whisper-1", Requests: 1, PromptTokens: 30, CompletionTokens: 5, MediaTokens: 28}}
+	if got := used(); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("usage = %+v, want %+v", got, want)
+	}
+
+	_, err = tr.Transcribe(context.Background(), &chat.Content{Type: chat.ContentTypeAudio, Data: []byte("#!AMR"), MimeType: "audio/amr"})
+	if !errors.Is(err, ErrUnsupportedAudio) {
+		t.Errorf("Transcribe(amr) error = %v, want ErrUnsupportedAudio", err)
+	}
+}
+
+func TestProviderTranscriber(t *testing.T) {
+	mock := &MockProvider{MockResponse: "好的\n", Caps: Capabilities{MimeTypes: []string{"audio/wav"}}}
+	tr := &providerTranscriber{provider: mock, language: "zh"}
+
+	audio := &chat.Content{Type: chat.ContentTypeAudio, Data: []byte("RIFF"), MimeType: "audio/wav"}
+	text, err := tr.Transcribe(context.Background(), audio)
+	if err != nil || text != "好的" {
+		t.Errorf("Transcribe() = %q, %v", text, err)
+	}
+	if len(mock.LastContents) != 1 || mock.LastContents[0] != audio {
+		t.Errorf("contents = %v", mock.LastContents)
+	}
+
+	audio.MimeType = "audio/amr"
+	if _, err := tr.Transcribe(context.Background(), audio); !errors.Is(err, ErrUnsupportedAudio) {
+		t.Errorf("Transcribe(amr) error = %v, want ErrUnsupportedAudio", err)
+	}
+}
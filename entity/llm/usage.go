@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// Usage is the token usage of the requests made with one model. Prompt
+// tokens include the cached and media tokens; completion tokens include
+// reasoning tokens, which are billed as output.
+type Usage struct {
+	Model            string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	CachedTokens     int // prompt tokens served from the provider's cache
+	MediaTokens      int // image, audio and video prompt tokens, where reported
+}
+
+func (u *Usage) add(o Usage) {
+	u.Requests += o.Requests
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.CachedTokens += o.CachedTokens
+	u.MediaTokens += o.MediaTokens
+}
+
+type usageKey struct{}
+
+// usageCollector adds up the usage providers report while serving one
+// summary, including its chunk, retry and fallback requests.
+type usageCollector struct {
+	mu      sync.Mutex
+	byModel map[string]*Usage
+	order   []string
+}
+
+// withUsage returns a context whose provider requests are counted by the
+// returned collector.
+func withUsage(ctx context.Context) (context.Context, *usageCollector) {
+	c := &usageCollector{byModel: make(map[string]*Usage)}
+	return context.WithValue(ctx, usageKey{}, c), c
+}
+
+// CollectUsage returns a context whose provider requests are counted, and a
+// function returning the totals so far, for requests made outside Service
+// such as transcription and captioning.
+func CollectUsage(ctx context.Context) (context.Context, func() []Usage) {
+	ctx, c := withUsage(ctx)
+	return ctx, c.usage
+}
+
+// recordUsage reports the usage of one request made with ctx.
+func recordUsage(ctx context.Context, u Usage) {
+	c, ok := ctx.Value(usageKey{}).(*usageCollector)
+	if !ok {
+		return
+	}
+	u.Requests = 1
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	total, ok := c.byModel[u.Model]
+	if !ok {
+		total = &Usage{Model: u.Model}
+		c.byModel[u.Model] = total
+		c.order = append(c.order, u.Model)
+	}
+	total.add(u)
+}
+
+// usage returns the totals per model in the order the models were first used.
+func (c *usageCollector) usage() []Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Usage, len(c.order))
+	for i, model := range c.order {
+		out[i] = *c.byModel[model]
+	}
+	return out
+}
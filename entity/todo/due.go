@@ -0,0 +1,149 @@
+package todo
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultDueHour is the deadline assumed for a due date without a time.
+const defaultDueHour = 18
+
+var (
+	isoDateRe   = regexp.MustCompile(`(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})`)
+	cnDateRe    = regexp.MustCompile(`(?:(\d{4})年)?(\d{1,2})月(\d{1,2})[日号]`)
+	shortDateRe = regexp.MustCompile(`^(\d{1,2})[-/](\d{1,2})\b`)
+	weekdayRe   = regexp.MustCompile(`(下下|下个?|本|这个?)?(?:周|星期|礼拜)([一二三四五六日天])`)
+	clockRe     = regexp.MustCompile(`(\d{1,2})[:：](\d{2})`)
+	hourRe      = regexp.MustCompile(`(上午|早上|中午|下午|晚上)?(\d{1,2})点`)
+)
+
+var relativeDays = []struct {
+	word string
+	days int
+}{
+	// Longest first, so 大后天 is not read as 后天
+	{"大后天", 3},
+	{"后天", 2},
+	{"明天", 1},
+	{"明早", 1},
+	{"明晚", 1},
+	{"今天", 0},
+	{"今晚", 0},
+	{"今日", 0},
+}
+
+var weekdays = map[string]time.Weekday{
+	"一": time.Monday, "二": time.Tuesday, "三": time.Wednesday, "四": time.Thursday,
+	"五": time.Friday, "六": time.Saturday, "日": time.Sunday, "天": time.Sunday,
+}
+
+// ParseDue interprets a due date as the model copied it from the chat, e.g.
+// "2025-03-07", "3月7日", "周五", "下周一 10:00" or "明天下午3点", relative to
+// now. Dates without a time are due at 18:00. It reports false for anything it
+// cannot place on the calendar, such as "尽快".
+func ParseDue(s string, now time.Time) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+
+	day, ok := parseDay(s, now)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	hour, minute := defaultDueHour, 0
+	if m := clockRe.FindStringSubmatch(s); m != nil {
+		hour, _ = strconv.Atoi(m[1])
+		minute, _ = strconv.Atoi(m[2])
+	} else if m := hourRe.FindStringSubmatch(s); m != nil {
+		hour, _ = strconv.Atoi(m[2])
+		// A bare "3点" in a work chat means the afternoon
+		if (m[1] == "下午" || m[1] == "晚上" || (m[1] == "" && hour < 7)) && hour < 12 {
+			hour += 12
+		}
+	} else if strings.Contains(s, "晚") {
+		hour = 21
+	} else if strings.Contains(s, "早") || strings.Contains(s, "上午") {
+		hour = 9
+	}
+	if hour > 23 || minute > 59 {
+		return time.Time{}, false
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location()), true
+}
+
+func parseDay(s string, now time.Time) (time.Time, bool) {
+	if m := isoDateRe.FindStringSubmatch(s); m != nil {
+		return date(atoi(m[1]), atoi(m[2]), atoi(m[3]), now)
+	}
+	if m := cnDateRe.FindStringSubmatch(s); m != nil {
+		year := atoi(m[1])
+		if year == 0 {
+			return nextDate(atoi(m[2]), atoi(m[3]), now)
+		}
+		return date(year, atoi(m[2]), atoi(m[3]), now)
+	}
+	if m := shortDateRe.FindStringSubmatch(s); m != nil {
+		return nextDate(atoi(m[1]), atoi(m[2]), now)
+	}
+	for _, rel := range relativeDays {
+		if strings.Contains(s, rel.word) {
+			return now.AddDate(0, 0, rel.days), true
+		}
+	}
+	if m := weekdayRe.FindStringSubmatch(s); m != nil {
+		return weekday(m[1], weekdays[m[2]], now), true
+	}
+	return time.Time{}, false
+}
+
+// weekday resolves 周五 to the coming Friday (today included), and 下周五 to
+// the Friday of next week, with weeks starting on Monday.
+func weekday(prefix string, wd time.Weekday, now time.Time) time.Time {
+	offset := (int(wd) + 6) % 7 // days since Monday
+	today := (int(now.Weekday()) + 6) % 7
+
+	switch {
+	case strings.HasPrefix(prefix, "下下"):
+		return now.AddDate(0, 0, 14-today+offset)
+	case strings.HasPrefix(prefix, "下"):
+		return now.AddDate(0, 0, 7-today+offset)
+	case prefix != "":
+		return now.AddDate(0, 0, offset-today)
+	}
+	days := offset - today
+	if days < 0 {
+		days += 7
+	}
+	return now.AddDate(0, 0, days)
+}
+
+func date(year, month, day int, now time.Time) (time.Time, bool) {
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, now.Location())
+	if t.Month() != time.Month(month) || t.Day() != day {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// nextDate places a month and day without a year on or after today.
+func nextDate(month, day int, now time.Time) (time.Time, bool) {
+	t, ok := date(now.Year(), month, day, now)
+	if !ok {
+		return t, false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if t.Before(today) {
+		return date(now.Year()+1, month, day, now)
+	}
+	return t, true
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
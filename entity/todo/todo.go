@@ -0,0 +1,262 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/soaringk/msg-asst/entity/llm"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// doneRetention is how long completed items are kept before being dropped.
+const doneRetention = 30 * 24 * time.Hour
+
+// Markers rolling summaries put in front of changed items.
+const (
+	markerNew      = "【新增】"
+	markerUpdated  = "【更新】"
+	markerResolved = "【已解决】"
+)
+
+// Item is an action item extracted from a summary.
+type Item struct {
+	ID         int       `json:"id"`
+	Task       string    `json:"task"`
+	Owner      string    `json:"owner,omitempty"`
+	Due        string    `json:"due,omitempty"`   // as written in the chat
+	DueAt      time.Time `json:"due_at,omitzero"` // zero when Due names no date
+	Group      string    `json:"group"`
+	Source     string    `json:"source,omitempty"` // the message the item came from
+	CreatedAt  time.Time `json:"created_at"`
+	DoneAt     time.Time `json:"done_at,omitzero"`
+	RemindedAt time.Time `json:"reminded_at,omitzero"`
+}
+
+// Done reports whether the item has been completed.
+func (it Item) Done() bool {
+	return !it.DoneAt.IsZero()
+}
+
+// Tracker keeps the action items of all groups in a JSON file. Items are
+// matched by task text within a group, so a task repeated by later rolling
+// summaries updates the existing item instead of adding a new one.
+type Tracker struct {
+	path string
+	log  *zap.Logger
+
+	mu     sync.Mutex
+	items  []*Item
+	nextID int
+}
+
+type trackerFile struct {
+	NextID int     `json:"next_id"`
+	Items  []*Item `json:"items"`
+}
+
+// Open loads the tracker stored at path, starting empty if it does not exist.
+func Open(path string) (*Tracker, error) {
+	t := &Tracker{
+		path:   path,
+		log:    logging.Named("todo"),
+		nextID: 1,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var f trackerFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	t.items = f.Items
+	t.nextID = max(f.NextID, 1)
+	t.log.Info("Loaded action items", zap.Int("items", len(t.items)))
+	return t, nil
+}
+
+// Add records the action items of a group's summary. Items marked 【已解决】
+// complete their open counterpart; items already tracked are updated with
+// the latest owner and due date. It returns the number of new items.
+func (t *Tracker) Add(group string, actions []llm.ActionItem, now time.Time) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	added := 0
+	for _, action := range actions {
+		task, resolved := stripMarkers(action.Task)
+		if task == "" {
+			continue
+		}
+
+		existing := t.findOpen(group, task)
+		switch {
+		case resolved:
+			if existing != nil {
+				existing.DoneAt = now
+			}
+		case existing != nil:
+			if action.Owner != "" {
+				existing.Owner = action.Owner
+			}
+			if action.Due != "" && action.Due != existing.Due {
+				existing.Due = action.Due
+				existing.DueAt, _ = ParseDue(action.Due, now)
+				existing.RemindedAt = time.Time{}
+			}
+		default:
+			item := &Item{
+				ID:        t.nextID,
+				Task:      task,
+				Owner:     action.Owner,
+				Due:       action.Due,
+				Group:     group,
+				Source:    action.Source,
+				CreatedAt: now,
+			}
+			item.DueAt, _ = ParseDue(action.Due, now)
+			t.items = append(t.items, item)
+			t.nextID++
+			added++
+		}
+	}
+	return added, t.save(now)
+}
+
+// Complete marks an item as done.
+func (t *Tracker) Complete(id int, now time.Time) (Item, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, item := range t.items {
+		if item.ID != id {
+			continue
+		}
+		if item.Done() {
+			return *item, fmt.Errorf("#%d is already done", id)
+		}
+		item.DoneAt = now
+		return *item, t.save(now)
+	}
+	return Item{}, fmt.Errorf("no action item #%d", id)
+}
+
+// Pending returns the open items, those with the earliest due date first
+// and those without one last.
+func (t *Tracker) Pending() []Item {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var open []Item
+	for _, item := range t.items {
+		if !item.Done() {
+			open = append(open, *item)
+		}
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		a, b := open[i].DueAt, open[j].DueAt
+		if a.IsZero() != b.IsZero() {
+			return b.IsZero()
+		}
+		return a.Before(b)
+	})
+	return open
+}
+
+// Due returns the open items due within before of now that have not been
+// reminded of yet, including overdue ones.
+func (t *Tracker) Due(now time.Time, before time.Duration) []Item {
+	var due []Item
+	for _, item := range t.Pending() {
+		if !item.DueAt.IsZero() && item.RemindedAt.IsZero() && !now.Before(item.DueAt.Add(-before)) {
+			due = append(due, item)
+		}
+	}
+	return due
+}
+
+// MarkReminded records that a reminder for the items was delivered.
+func (t *Tracker) MarkReminded(ids []int, now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, item := range t.items {
+		for _, id := range ids {
+			if item.ID == id {
+				item.RemindedAt = now
+			}
+		}
+	}
+	return t.save(now)
+}
+
+// findOpen returns the open item of group with the same task. Must be called
+// with mu held.
+func (t *Tracker) findOpen(group, task string) *Item {
+	key := normalize(task)
+	for _, item := range t.items {
+		if !item.Done() && item.Group == group && normalize(item.Task) == key {
+			return item
+		}
+	}
+	return nil
+}
+
+// save drops items completed long ago and writes the tracker atomically.
+// Must be called with mu held.
+func (t *Tracker) save(now time.Time) error {
+	kept := t.items[:0]
+	for _, item := range t.items {
+		if !item.Done() || now.Sub(item.DoneAt) < doneRetention {
+			kept = append(kept, item)
+		}
+	}
+	t.items = kept
+
+	data, err := json.MarshalIndent(trackerFile{NextID: t.nextID, Items: t.items}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal action items: %w", err)
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write action items: %w", err)
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// stripMarkers removes the rolling summary markers from a task and reports
+// whether it was marked resolved.
+func stripMarkers(task string) (string, bool) {
+	task = strings.TrimSpace(task)
+	resolved := strings.Contains(task, markerResolved)
+	for _, marker := range []string{markerNew, markerUpdated, markerResolved} {
+		task = strings.ReplaceAll(task, marker, "")
+	}
+	return strings.TrimSpace(task), resolved
+}
+
+// normalize reduces a task to its letters and digits, so rewordings that
+// only differ in spacing or punctuation still match.
+func normalize(task string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(task) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
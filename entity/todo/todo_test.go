@@ -0,0 +1,125 @@
+package todo
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/llm"
+)
+
+func TestParseDue(t *testing.T) {
+	// Wednesday
+	now := time.Date(2025, 3, 5, 10, 0, 0, 0, time.Local)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"2025-03-07", at(3, 7, 18, 0), true},
+		{"3月10日 10:30", at(3, 10, 10, 30), true},
+		{"明天", at(3, 6, 18, 0), true},
+		{"大后天上午", at(3, 8, 9, 0), true},
+		{"今晚", at(3, 5, 21, 0), true},
+		{"周五", at(3, 7, 18, 0), true},
+		{"周一", at(3, 10, 18, 0), true},
+		{"本周一", at(3, 3, 18, 0), true},
+		{"下周三下午3点", at(3, 12, 15, 0), true},
+		{"明天3点", at(3, 6, 15, 0), true},
+		{"3/20", at(3, 20, 18, 0), true},
+		{"1月2日", time.Date(2026, 1, 2, 18, 0, 0, 0, time.Local), true},
+		{"2月30日", time.Time{}, false},
+		{"尽快", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseDue(tt.in, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("ParseDue(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	now := time.Date(2025, 3, 5, 10, 0, 0, 0, time.Local)
+
+	tracker, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := tracker.Add("产品群", []llm.ActionItem{
+		{Task: "写发布说明", Owner: "李四", Due: "周四", Source: "张三: 李四周四前把发布说明写好"},
+		{Task: "通知客户"},
+	}, now)
+	if err != nil || added != 2 {
+		t.Fatalf("Add() = %d, %v; want 2 new items", added, err)
+	}
+
+	// A later rolling summary repeats one item with a new due date and
+	// resolves the other
+	added, err = tracker.Add("产品群", []llm.ActionItem{
+		{Task: "【更新】写发布说明。", Due: "周五"},
+		{Task: "【已解决】通知客户"},
+	}, now.Add(time.Hour))
+	if err != nil || added != 0 {
+		t.Fatalf("Add() = %d, %v; want no new items", added, err)
+	}
+
+	// Reload from disk
+	tracker, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := tracker.Pending()
+	if len(pending) != 1 {
+		t.Fatalf("Expected 1 pending item, got %+v", pending)
+	}
+	item := pending[0]
+	if item.ID != 1 || item.Owner != "李四" || item.Due != "周五" || item.Source == "" {
+		t.Errorf("Unexpected item: %+v", item)
+	}
+	friday := time.Date(2025, 3, 7, 18, 0, 0, 0, time.Local)
+	if !item.DueAt.Equal(friday) {
+		t.Errorf("Expected due %v, got %v", friday, item.DueAt)
+	}
+
+	// Reminded once, within a day of the due date
+	if due := tracker.Due(friday.Add(-25*time.Hour), 24*time.Hour); len(due) != 0 {
+		t.Errorf("Reminder too early: %+v", due)
+	}
+	due := tracker.Due(friday.Add(-23*time.Hour), 24*time.Hour)
+	if len(due) != 1 {
+		t.Fatalf("Expected a reminder, got %+v", due)
+	}
+	if err := tracker.MarkReminded([]int{due[0].ID}, now); err != nil {
+		t.Fatal(err)
+	}
+	if due := tracker.Due(friday, 24*time.Hour); len(due) != 0 {
+		t.Errorf("Reminder repeated: %+v", due)
+	}
+
+	if _, err := tracker.Complete(1, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.Complete(1, now); err == nil {
+		t.Error("Expected an error completing an item twice")
+	}
+	if len(tracker.Pending()) != 0 {
+		t.Error("Expected no pending items")
+	}
+
+	// A new item gets a fresh ID even after earlier ones are done
+	if _, err := tracker.Add("产品群", []llm.ActionItem{{Task: "复盘"}}, now); err != nil {
+		t.Fatal(err)
+	}
+	if pending := tracker.Pending(); len(pending) != 1 || pending[0].ID != 3 {
+		t.Errorf("Expected item #3, got %+v", pending)
+	}
+}
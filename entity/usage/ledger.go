@@ -0,0 +1,190 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/entity/llm"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// retention is how long daily entries are kept.
+const retention = 90 * 24 * time.Hour
+
+const dayLayout = "2006-01-02"
+
+// Entry is the usage of one model by one group on one day.
+type Entry struct {
+	Day              string  `json:"day"`
+	Group            string  `json:"group"`
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CachedTokens     int     `json:"cached_tokens,omitempty"`
+	MediaTokens      int     `json:"media_tokens,omitempty"`
+	Cost             float64 `json:"cost"`
+}
+
+// Ledger aggregates LLM usage and spend by day, group and model in a JSON
+// file.
+type Ledger struct {
+	path string
+	log  *zap.Logger
+
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+type ledgerFile struct {
+	Entries []*Entry `json:"entries"`
+}
+
+// Open loads the ledger stored at path, starting empty if it does not exist.
+func Open(path string) (*Ledger, error) {
+	l := &Ledger{
+		path: path,
+		log:  logging.Named("usage"),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var f ledgerFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	l.entries = f.Entries
+	l.log.Info("Loaded usage ledger", zap.Int("entries", len(l.entries)))
+	return l, nil
+}
+
+// Cost prices u with the matching model price. Models without a price cost
+// nothing.
+func Cost(u llm.Usage, cfg *config.UsageConfig) float64 {
+	price, ok := cfg.PriceFor(u.Model)
+	if !ok {
+		return 0
+	}
+	cached := price.Cached
+	if cached == 0 {
+		cached = price.Input
+	}
+	uncached := u.PromptTokens - u.CachedTokens
+	return (float64(uncached)*price.Input + float64(u.CachedTokens)*cached + float64(u.CompletionTokens)*price.Output) / 1e6
+}
+
+// Record adds the usage of one summary of group to the ledger and returns
+// its cost.
+func (l *Ledger) Record(now time.Time, group string, usage []llm.Usage, cfg *config.UsageConfig) (float64, error) {
+	if len(usage) == 0 {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	day := now.Format(dayLayout)
+	var total float64
+	for _, u := range usage {
+		cost := Cost(u, cfg)
+		total += cost
+
+		entry := l.find(day, group, u.Model)
+		if entry == nil {
+			entry = &Entry{Day: day, Group: group, Model: u.Model}
+			l.entries = append(l.entries, entry)
+		}
+		entry.Requests += u.Requests
+		entry.PromptTokens += u.PromptTokens
+		entry.CompletionTokens += u.CompletionTokens
+		entry.CachedTokens += u.CachedTokens
+		entry.MediaTokens += u.MediaTokens
+		entry.Cost += cost
+	}
+	return total, l.save(now)
+}
+
+// Spent returns what group spent on the day of now.
+func (l *Ledger) Spent(now time.Time, group string) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	day := now.Format(dayLayout)
+	var spent float64
+	for _, e := range l.entries {
+		if e.Day == day && e.Group == group {
+			spent += e.Cost
+		}
+	}
+	return spent
+}
+
+// Entries returns the entries from the day of since onwards, newest day
+// first, then by cost.
+func (l *Ledger) Entries(since time.Time) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	from := since.Format(dayLayout)
+	var out []Entry
+	for _, e := range l.entries {
+		if e.Day >= from {
+			out = append(out, *e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Day != out[j].Day {
+			return out[i].Day > out[j].Day
+		}
+		return out[i].Cost > out[j].Cost
+	})
+	return out
+}
+
+// find returns the entry of day, group and model. Must be called with mu
+// held.
+func (l *Ledger) find(day, group, model string) *Entry {
+	for _, e := range l.entries {
+		if e.Day == day && e.Group == group && e.Model == model {
+			return e
+		}
+	}
+	return nil
+}
+
+// save drops entries past retention and writes the ledger atomically. Must
+// be called with mu held.
+func (l *Ledger) save(now time.Time) error {
+	oldest := now.Add(-retention).Format(dayLayout)
+	kept := l.entries[:0]
+	for _, e := range l.entries {
+		if e.Day >= oldest {
+			kept = append(kept, e)
+		}
+	}
+	l.entries = kept
+
+	data, err := json.MarshalIndent(ledgerFile{Entries: l.entries}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage ledger: %w", err)
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write usage ledger: %w", err)
+	}
+	return os.Rename(tmp, l.path)
+}
@@ -0,0 +1,86 @@
+package usage
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/entity/llm"
+)
+
+func TestCost(t *testing.T) {
+	cfg := &config.UsageConfig{Prices: []config.ModelPrice{
+		{Model: "gemini-2.5-flash", Input: 0.30, Output: 2.50, Cached: 0.075},
+		{Model: "gpt-4o", Input: 2.50, Output: 10},
+	}}
+
+	tests := []struct {
+		usage llm.Usage
+		want  float64
+	}{
+		{llm.Usage{Model: "gemini-2.5-flash", PromptTokens: 1_000_000, CompletionTokens: 100_000}, 0.30 + 0.25},
+		{llm.Usage{Model: "gemini-2.5-flash", PromptTokens: 1_000_000, CachedTokens: 400_000}, 0.6*0.30 + 0.4*0.075},
+		// Without a cached price, cached tokens cost the input price
+		{llm.Usage{Model: "gpt-4o", PromptTokens: 200_000, CachedTokens: 100_000}, 0.5},
+		{llm.Usage{Model: "unknown", PromptTokens: 1_000_000}, 0},
+	}
+	for _, tt := range tests {
+		if got := Cost(tt.usage, cfg); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Cost(%+v) = %v, want %v", tt.usage, got, tt.want)
+		}
+	}
+}
+
+func TestLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	cfg := &config.UsageConfig{Prices: []config.ModelPrice{{Model: "m", Input: 1, Output: 2}}}
+	now := time.Date(2025, 3, 5, 10, 0, 0, 0, time.Local)
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := []llm.Usage{{Model: "m", Requests: 3, PromptTokens: 1_000_000, CompletionTokens: 500_000}}
+	if cost, err := l.Record(now, "研发群", used, cfg); err != nil || cost != 2 {
+		t.Fatalf("Record() = %v, %v; want 2", cost, err)
+	}
+	if _, err := l.Record(now.Add(time.Hour), "研发群", used, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Record(now, "产品群", used, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Record(now.AddDate(0, 0, -1), "研发群", used, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if spent := l.Spent(now, "研发群"); spent != 4 {
+		t.Errorf("Spent() = %v, want 4", spent)
+	}
+
+	// Entries of one day, group and model are merged and survive a reload
+	reloaded, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := reloaded.Entries(now)
+	if len(entries) != 2 {
+		t.Fatalf("Entries() = %+v, want 2 entries today", entries)
+	}
+	if e := entries[0]; e.Group != "研发群" || e.Requests != 6 || e.PromptTokens != 2_000_000 || e.Cost != 4 {
+		t.Errorf("Entries()[0] = %+v", e)
+	}
+	if got := len(reloaded.Entries(now.AddDate(0, 0, -1))); got != 3 {
+		t.Errorf("Entries(yesterday) = %d entries, want 3", got)
+	}
+
+	// Old days are dropped on the next save
+	if _, err := reloaded.Record(now.Add(retention+48*time.Hour), "研发群", used, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(reloaded.Entries(time.Time{})); got != 1 {
+		t.Errorf("Entries() after retention = %d, want 1", got)
+	}
+}
@@ -7,6 +7,7 @@ require (
 	github.com/eatmoreapple/openwechat v1.4.10
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/joho/godotenv v1.5.1
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/openai/openai-go/v3 v3.15.0
 	go.uber.org/zap v1.27.1
 	google.golang.org/genai v1.40.0
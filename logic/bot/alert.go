@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/llm"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// llmAlertInterval limits how often the owner is told about LLM failures
+// that need their attention.
+const llmAlertInterval = time.Hour
+
+// reportLLMError logs a failed LLM request for subject, e.g. a group name,
+// and tells the owner through the File Transfer Helper when retrying on
+// its own will not help: an exhausted quota or a rejected API key. Messages
+// stay buffered either way.
+func (b *Bot) reportLLMError(subject string, err error) {
+	kind := llm.ErrorKindOf(err)
+	logging.Error("LLM request failed",
+		zap.String("subject", subject),
+		zap.Stringer("kind", kind),
+		zap.Error(err))
+
+	var reason string
+	switch kind {
+	case llm.ErrQuotaExhausted:
+		reason = "LLM 配额已用尽，请充值或等待额度恢复"
+	case llm.ErrAuth:
+		reason = "LLM API Key 无效或无权限，请检查 LLM_API_KEY"
+	default:
+		return
+	}
+
+	now := time.Now()
+	last := b.llmAlertedAt.Load()
+	if now.Sub(time.Unix(0, last)) < llmAlertInterval || !b.llmAlertedAt.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	if b.self == nil {
+		return
+	}
+	text := fmt.Sprintf("⚠️ %s 生成失败：%s。消息已保留，恢复后会在下次总结时处理。", subject, reason)
+	if _, err := b.self.FileHelper().SendText(text); err != nil {
+		logging.Error("Failed to send LLM error alert", zap.Error(err))
+	}
+}
@@ -3,28 +3,48 @@ package bot
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/eatmoreapple/openwechat"
+	"github.com/soaringk/msg-asst/entity/archive"
 	"github.com/soaringk/msg-asst/entity/chat"
 	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/entity/todo"
+	"github.com/soaringk/msg-asst/entity/usage"
+	"github.com/soaringk/msg-asst/logic/delivery"
+	"github.com/soaringk/msg-asst/logic/ingest"
+	"github.com/soaringk/msg-asst/logic/scheduler"
 	"github.com/soaringk/msg-asst/logic/summary"
 	"github.com/soaringk/msg-asst/pkg/logging"
 	"go.uber.org/zap"
 )
 
+const intervalCheckPeriod = time.Minute
+
 type Bot struct {
 	bot             *openwechat.Bot
 	buffer          *chat.MessageBuffer
+	archive         *archive.Store // nil when archiving is disabled
+	todos           *todo.Tracker  // nil when action item tracking is disabled
+	usage           *usage.Ledger  // nil when the usage ledger cannot be opened
 	generator       *summary.Generator
+	ingest          *ingest.Pipeline
+	router          *delivery.Router
+	scheduler       *scheduler.Scheduler
 	self            *openwechat.Self
 	stopTimer       chan struct{}
 	activeSummaries sync.Map // map[string]bool - tracks groups with in-progress summaries
+	paused          sync.Map // map[string]struct{} - group patterns paused from the console
+	untracked       sync.Map // map[string]struct{} - groups whose last summary had no structured minutes
+	startedAt       time.Time
+	llmAlertedAt    atomic.Int64 // unix nanoseconds of the last LLM failure alert
 	stopOnce        sync.Once
 	ctx             context.Context
 	cancel          context.CancelFunc
@@ -34,14 +54,60 @@ type Bot struct {
 func New() *Bot {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Bot{
+	b := &Bot{
 		bot:       openwechat.DefaultBot(openwechat.Desktop),
-		buffer:    chat.New(),
+		buffer:    newBuffer(),
+		archive:   newArchive(),
+		todos:     newTodos(),
+		usage:     newUsage(),
 		generator: summary.New(),
+		ingest:    ingest.New(),
 		stopTimer: make(chan struct{}),
+		startedAt: time.Now(),
 		ctx:       ctx,
 		cancel:    cancel,
 	}
+	b.buffer.OnIdle(b.handleIdle)
+	b.ingest.SetUsage(b.generator.RecordUsage)
+	if b.archive != nil {
+		b.generator.SetHistory(b.lastArchivedSummary)
+	}
+	if b.usage != nil {
+		b.generator.SetUsage(b.usage)
+	}
+	return b
+}
+
+func newBuffer() *chat.MessageBuffer {
+	cfg := config.GetConfig()
+	if !cfg.BufferWALEnabled {
+		return chat.New()
+	}
+
+	buf, err := chat.Open(cfg.BufferWALFile)
+	if err != nil {
+		logging.Error("Failed to open buffer WAL, messages will not survive restarts",
+			zap.String("path", cfg.BufferWALFile),
+			zap.Error(err))
+		return chat.New()
+	}
+	return buf
+}
+
+func newArchive() *archive.Store {
+	cfg := config.GetConfig().Archive
+	if !cfg.Enabled {
+		return nil
+	}
+
+	store, err := archive.Open(cfg.DBFile, cfg.MediaDir)
+	if err != nil {
+		logging.Error("Failed to open archive, messages will not be archived",
+			zap.String("path", cfg.DBFile),
+			zap.Error(err))
+		return nil
+	}
+	return store
 }
 
 func (b *Bot) Start(selectGroups bool) error {
@@ -68,6 +134,8 @@ func (b *Bot) Start(selectGroups bool) error {
 		return err
 	}
 	b.self = self
+	b.router = delivery.NewRouter(self)
+	b.generator.SetOwner(self.NickName)
 
 	logging.Info("Logged in successfully", zap.String("user", self.NickName))
 
@@ -79,9 +147,12 @@ func (b *Bot) Start(selectGroups bool) error {
 
 	logging.Info("Bot is now active and monitoring messages")
 
-	if config.GetConfig().SummaryTrigger.IntervalMinutes > 0 {
-		b.startIntervalTimer()
-	}
+	b.startIntervalTimer()
+
+	b.scheduler = scheduler.New(config.GetConfig().SummaryTrigger.ScheduleStateFile, b.buffer.GetGroupTopics, b.triggerScheduledSummary)
+	b.scheduler.Start()
+	b.startDigest()
+	b.startReminders()
 
 	b.bot.Block()
 	return nil
@@ -158,8 +229,20 @@ func (b *Bot) Stop() {
 		logging.Info("Stopping bot...")
 		b.cancel()
 		b.stopIntervalTimer()
+		if b.scheduler != nil {
+			b.scheduler.Stop()
+		}
 		b.wg.Wait()
+		b.ingest.Close()
 		b.generator.Close()
+		if err := b.buffer.Close(); err != nil {
+			logging.Error("Failed to close buffer WAL", zap.Error(err))
+		}
+		if b.archive != nil {
+			if err := b.archive.Close(); err != nil {
+				logging.Error("Failed to close archive", zap.Error(err))
+			}
+		}
 		config.StopWatchers()
 		logging.Info("Bot stopped gracefully")
 	})
@@ -167,6 +250,9 @@ func (b *Bot) Stop() {
 
 func (b *Bot) handleMessage(msg *openwechat.Message) {
 	if msg.IsSendBySelf() {
+		if isConsoleMessage(msg) {
+			b.handleConsole(msg)
+		}
 		return
 	}
 
@@ -186,7 +272,7 @@ func (b *Bot) handleMessage(msg *openwechat.Message) {
 	group := openwechat.Group{User: sender}
 	groupName := group.NickName
 
-	if !b.isTargetGroup(groupName) {
+	if !b.isTargetGroup(groupName) || b.isPaused(groupName) {
 		return
 	}
 
@@ -200,7 +286,7 @@ func (b *Bot) handleMessage(msg *openwechat.Message) {
 		return
 	}
 
-	if !b.isMediaAllowed(extractedContent) {
+	if !b.isMediaAllowed(groupName, extractedContent) {
 		return
 	}
 
@@ -208,16 +294,34 @@ func (b *Bot) handleMessage(msg *openwechat.Message) {
 		return
 	}
 
-	b.buffer.Add(chat.Message{
+	message := chat.Message{
 		ID:         msg.MsgId,
 		Timestamp:  time.Now(),
 		Sender:     senderUser.NickName,
 		GroupTopic: groupName,
 		Content:    extractedContent,
+		ReplyTo:    chat.ExtractReplyTo(msg),
+	}
+	// Transcripts and captions arrive later and replace the media in the
+	// buffer; the archive keeps the media
+	pending := b.ingest.Wants(extractedContent)
+	if pending {
+		b.buffer.AddPending(message)
+	} else {
+		b.buffer.Add(message)
+	}
+	b.ingest.Submit(b.ctx, groupName, extractedContent, func(c *chat.Content) {
+		processed := message
+		processed.Content = c
+		b.archiveMessage(processed)
+		if pending {
+			b.buffer.Patch(groupName, message.ID, c.Compact())
+		}
 	})
 
-	if b.buffer.ShouldSummarize(groupName, b.checkKeywordTrigger(extractedContent.Text)) {
-		b.triggerSummary(groupName)
+	cmd, requested := b.parseCommand(groupName, extractedContent.Text)
+	if b.buffer.ShouldSummarize(groupName, requested) {
+		b.triggerSummary(groupName, cmd)
 	}
 }
 
@@ -225,8 +329,8 @@ func (b *Bot) isSupportedMessageType(msg *openwechat.Message) bool {
 	return msg.IsText() || msg.IsPicture() || msg.IsVideo() || msg.IsVoice() || msg.IsMedia()
 }
 
-func (b *Bot) isMediaAllowed(c *chat.Content) bool {
-	cfg := config.GetConfig()
+func (b *Bot) isMediaAllowed(groupTopic string, c *chat.Content) bool {
+	cfg := config.ForGroup(groupTopic)
 	ms := cfg.MediaSupport
 
 	var enabled bool
@@ -261,24 +365,24 @@ func (b *Bot) isTargetGroup(groupName string) bool {
 		return true
 	}
 
-	groupNameLower := strings.ToLower(groupName)
 	for _, target := range targetGroups {
-		if strings.Contains(groupNameLower, strings.ToLower(target)) {
+		if config.MatchGroup(target, groupName) {
 			return true
 		}
 	}
 	return false
 }
 
-func (b *Bot) checkKeywordTrigger(text string) bool {
-	keyword := config.GetConfig().SummaryTrigger.Keyword
-	if keyword == "" {
-		return false
-	}
-	return strings.Contains(text, keyword)
+// parseCommand reports whether text asks for a summary with the group's
+// keyword, along with the arguments given after it.
+func (b *Bot) parseCommand(groupTopic, text string) (summary.Command, bool) {
+	keyword := config.ForGroup(groupTopic).SummaryTrigger.Keyword
+	return summary.ParseCommand(text, keyword, time.Now())
 }
 
-func (b *Bot) triggerSummary(groupTopic string) {
+// triggerSummary starts a summary in the background unless one is already
+// running for the group.
+func (b *Bot) triggerSummary(groupTopic string, cmd summary.Command) {
 	if _, loaded := b.activeSummaries.LoadOrStore(groupTopic, true); loaded {
 		return
 	}
@@ -287,65 +391,150 @@ func (b *Bot) triggerSummary(groupTopic string) {
 	go func() {
 		defer b.wg.Done()
 		defer b.activeSummaries.Delete(groupTopic)
-		b.generateAndSendSummary(groupTopic)
+		b.generateAndSendSummary(groupTopic, cmd)
 	}()
 }
 
-func (b *Bot) generateAndSendSummary(groupTopic string) {
-	logging.Info("Generating summary", zap.String("group", groupTopic))
+func (b *Bot) handleIdle(groupTopic string) {
+	if b.ctx.Err() != nil || b.isPaused(groupTopic) {
+		return
+	}
+	if b.buffer.ShouldSummarize(groupTopic, false) {
+		b.triggerSummary(groupTopic, summary.Command{})
+	}
+}
 
-	result, err := b.generator.Generate(b.ctx, b.buffer, groupTopic)
+func (b *Bot) triggerScheduledSummary(groupTopic string) {
+	if b.isPaused(groupTopic) {
+		logging.Info("Scheduled summary skipped, group paused", zap.String("group", groupTopic))
+		return
+	}
+	count := b.buffer.Count(groupTopic)
+	minMessages := config.ForGroup(groupTopic).SummaryTrigger.MinMessagesForSummary
+	if count < minMessages {
+		logging.Info("Scheduled summary skipped, not enough messages",
+			zap.String("group", groupTopic),
+			zap.Int("count", count),
+			zap.Int("min", minMessages))
+		return
+	}
+
+	logging.Info("Summary triggered by schedule", zap.String("group", groupTopic))
+	b.triggerSummary(groupTopic, summary.Command{})
+}
+
+// generateAndSendSummary summarizes and delivers a group's messages. Only a
+// summary of the whole buffer clears it; a windowed summary requested by a
+// command leaves the buffer for the next regular summary.
+func (b *Bot) generateAndSendSummary(groupTopic string, cmd summary.Command) {
+	logging.Info("Generating summary", zap.String("group", groupTopic), zap.String("scope", cmd.Describe()))
+
+	result, err := b.generator.Generate(b.ctx, b.buffer, groupTopic, cmd)
 	if err != nil {
-		if err == context.Canceled {
+		if errors.Is(err, context.Canceled) {
 			logging.Info("Summary generation cancelled", zap.String("group", groupTopic))
 			return
 		}
-		logging.Error("Error generating summary", zap.String("group", groupTopic), zap.Error(err))
+		b.reportLLMError(groupTopic+" 的纪要", err)
 		return
 	}
 
 	if result.SkipReason != "" {
 		logging.Info("Summary skipped", zap.String("group", groupTopic), zap.String("reason", result.SkipReason))
-		b.buffer.Clear(groupTopic)
+		if cmd.Window.IsZero() && result.SkipReason != summary.SkipBudgetExceeded {
+			b.buffer.Clear(groupTopic)
+		}
 		return
 	}
 
-	if sendErr := b.sendToSelf(result.Text); sendErr != nil {
-		logging.Error("Error sending summary", zap.Error(sendErr))
+	if sendErr := b.router.Deliver(b.ctx, result); sendErr != nil {
+		logging.Error("Error sending summary", zap.String("group", groupTopic), zap.Error(sendErr))
 		return
 	}
 
-	b.buffer.Clear(groupTopic)
+	if cmd.Window.IsZero() {
+		b.buffer.Clear(groupTopic)
+		b.generator.Remember(result)
+	}
+	b.archiveSummary(result, cmd)
+	b.trackActionItems(result)
 	logging.Info("Summary sent successfully", zap.String("group", groupTopic))
 }
 
-func (b *Bot) sendToSelf(message string) error {
-	if b.self == nil {
-		return fmt.Errorf("self user not available")
+func (b *Bot) archiveMessage(msg chat.Message) {
+	if b.archive == nil {
+		return
+	}
+	if err := b.archive.RecordMessage(msg); err != nil {
+		logging.Error("Failed to archive message",
+			zap.String("group", msg.GroupTopic),
+			zap.Error(err))
 	}
+}
 
-	fileHelper := b.self.FileHelper()
-	_, err := fileHelper.SendText(message)
-	return err
+// archiveSummary records a delivered summary. Windowed summaries keep their
+// scope so they are never taken for the rolling context.
+func (b *Bot) archiveSummary(result summary.Result, cmd summary.Command) {
+	if b.archive == nil {
+		return
+	}
+	var scope string
+	if !cmd.Window.IsZero() {
+		scope = cmd.Describe()
+	}
+	_, err := b.archive.RecordSummary(archive.SummaryRecord{
+		GroupTopic:   result.GroupTopic,
+		FirstMsgTime: result.FirstMsgTime,
+		LastMsgTime:  result.LastMsgTime,
+		MessageCount: result.MessageCount,
+		Text:         result.Text,
+		PromptHash:   result.PromptHash,
+		Model:        result.Model,
+		Scope:        scope,
+	})
+	if err != nil {
+		logging.Error("Failed to archive summary",
+			zap.String("group", result.GroupTopic),
+			zap.Error(err))
+	}
+}
+
+// lastArchivedSummary returns the body of the most recent archived full
+// summary of a group, matching what Remember keeps for summaries sent since
+// startup.
+func (b *Bot) lastArchivedSummary(groupTopic string) (string, time.Time, bool) {
+	records, err := b.archive.Summaries(archive.Query{GroupTopic: groupTopic, FullOnly: true, Limit: 1})
+	if err != nil {
+		logging.Warn("Failed to load previous summary from archive",
+			zap.String("group", groupTopic),
+			zap.Error(err))
+		return "", time.Time{}, false
+	}
+	if len(records) == 0 {
+		return "", time.Time{}, false
+	}
+	return summary.StripHeader(records[0].Text), records[0].CreatedAt, true
 }
 
+// startIntervalTimer checks every group once per intervalCheckPeriod. The
+// interval itself is resolved per group by ShouldSummarize, so groups.json
+// overrides and .env changes apply without a restart.
 func (b *Bot) startIntervalTimer() {
-	intervalMinutes := config.GetConfig().SummaryTrigger.IntervalMinutes
-	logging.Info("Starting interval timer", zap.Int("interval", intervalMinutes))
+	logging.Info("Starting interval timer", zap.Duration("check", intervalCheckPeriod))
 
-	ticker := time.NewTicker(time.Duration(intervalMinutes) * time.Minute)
+	ticker := time.NewTicker(intervalCheckPeriod)
 
 	go func() {
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
-				logging.Info("Interval timer triggered")
+				logging.Debug("Interval timer triggered")
 				groupTopics := b.buffer.GetGroupTopics()
 				for _, topic := range groupTopics {
-					if b.buffer.ShouldSummarize(topic, false) {
+					if !b.isPaused(topic) && b.buffer.ShouldSummarize(topic, false) {
 						logging.Info("Processing scheduled summary", zap.String("group", topic))
-						b.triggerSummary(topic)
+						b.triggerSummary(topic, summary.Command{})
 					}
 				}
 			case <-b.stopTimer:
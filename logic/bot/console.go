@@ -0,0 +1,322 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eatmoreapple/openwechat"
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/logic/summary"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// fileHelperUserName is the user name WeChat gives the File Transfer Helper.
+const fileHelperUserName = "filehelper"
+
+const consoleUsage = `可用命令：
+/status - 运行状态
+/groups - 已缓冲的群及消息数
+/pause [群] - 暂停监听某个群，不带参数则暂停全部
+/resume [群] - 恢复监听某个群，不带参数则恢复全部
+/summarize <群> [参数] - 立即总结，参数同群内指令，如 2h、30m、100条、@张三、详细；数字须带单位，单独的数字会被忽略；群名含空格时可加引号
+/buffer <群> - 查看缓冲区
+/prompt reload - 重新加载系统提示词
+/digest - 立即生成每日汇总
+/todo [群] - 未完成的待办
+/todo done <编号> - 标记待办已完成
+/usage [天数] [群] - 用量和费用，默认今天`
+
+var consoleCommands = map[string]func(b *Bot, args string) string{
+	"help":      (*Bot).consoleHelp,
+	"status":    (*Bot).consoleStatus,
+	"groups":    (*Bot).consoleGroups,
+	"pause":     (*Bot).consolePause,
+	"resume":    (*Bot).consoleResume,
+	"summarize": (*Bot).consoleSummarize,
+	"buffer":    (*Bot).consoleBuffer,
+	"prompt":    (*Bot).consolePrompt,
+	"digest":    (*Bot).consoleDigest,
+	"todo":      (*Bot).consoleTodo,
+	"usage":     (*Bot).consoleUsage,
+}
+
+// isConsoleMessage reports whether msg is a command the owner sent to their
+// own File Transfer Helper. Summaries delivered there never start with "/".
+func isConsoleMessage(msg *openwechat.Message) bool {
+	return msg.IsText() &&
+		msg.ToUserName == fileHelperUserName &&
+		strings.HasPrefix(strings.TrimSpace(msg.Content), "/")
+}
+
+func (b *Bot) handleConsole(msg *openwechat.Message) {
+	name, args, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(msg.Content), "/"), " ")
+	name = strings.ToLower(name)
+	args = strings.TrimSpace(args)
+
+	logging.Info("Console command received", zap.String("command", name), zap.String("args", args))
+
+	reply := fmt.Sprintf("未知命令 /%s\n\n%s", name, consoleUsage)
+	if run, ok := consoleCommands[name]; ok {
+		reply = run(b, args)
+	}
+
+	if _, err := b.self.FileHelper().SendText(reply); err != nil {
+		logging.Error("Failed to send console reply", zap.String("command", name), zap.Error(err))
+	}
+}
+
+func (b *Bot) consoleHelp(string) string {
+	return consoleUsage
+}
+
+func (b *Bot) consoleStatus(string) string {
+	cfg := config.GetConfig()
+	topics := b.buffer.GetGroupTopics()
+	buffered := 0
+	for _, topic := range topics {
+		buffered += b.buffer.Count(topic)
+	}
+
+	var active []string
+	b.activeSummaries.Range(func(key, _ any) bool {
+		active = append(active, key.(string))
+		return true
+	})
+	sort.Strings(active)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "运行时间：%s\n", time.Since(b.startedAt).Round(time.Second))
+	fmt.Fprintf(&sb, "模型：%s (%s)\n", cfg.LLMModel, cfg.LLMProvider)
+	fmt.Fprintf(&sb, "缓冲：%d 个群，%d 条消息\n", len(topics), buffered)
+	if len(active) > 0 {
+		fmt.Fprintf(&sb, "正在总结：%s\n", strings.Join(active, "、"))
+	}
+	if paused := b.pausedGroups(); len(paused) > 0 {
+		fmt.Fprintf(&sb, "已暂停：%s\n", strings.Join(paused, "、"))
+	}
+	if targets := config.GetTargetGroups(); len(targets) > 0 {
+		fmt.Fprintf(&sb, "监听：%s", strings.Join(targets, "、"))
+	} else {
+		sb.WriteString("监听：全部群")
+	}
+	return sb.String()
+}
+
+func (b *Bot) consoleGroups(string) string {
+	topics := b.buffer.GetGroupTopics()
+	if len(topics) == 0 {
+		return "缓冲区为空"
+	}
+	sort.Strings(topics)
+
+	var sb strings.Builder
+	for _, topic := range topics {
+		fmt.Fprintf(&sb, "• %s：%d 条", topic, b.buffer.Count(topic))
+		if b.isPaused(topic) {
+			sb.WriteString("（已暂停）")
+		}
+		if b.scheduler != nil {
+			if next := b.scheduler.Next(topic); !next.IsZero() {
+				fmt.Fprintf(&sb, "，下次定时 %s", next.Format("01-02 15:04"))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (b *Bot) consolePause(args string) string {
+	pattern := args
+	if pattern == "" {
+		pattern = "*"
+	}
+	b.paused.Store(pattern, struct{}{})
+	logging.Info("Group paused from console", zap.String("pattern", pattern))
+
+	if pattern == "*" {
+		return "已暂停全部群，/resume 恢复"
+	}
+	return fmt.Sprintf("已暂停名称包含「%s」的群，/resume %s 恢复", pattern, pattern)
+}
+
+func (b *Bot) consoleResume(args string) string {
+	if args == "" {
+		b.paused.Clear()
+		logging.Info("All groups resumed from console")
+		return "已恢复全部群"
+	}
+
+	if _, ok := b.paused.LoadAndDelete(args); !ok {
+		return fmt.Sprintf("「%s」未被暂停，当前暂停：%s", args, b.describePaused())
+	}
+	logging.Info("Group resumed from console", zap.String("pattern", args))
+	return fmt.Sprintf("已恢复「%s」", args)
+}
+
+func (b *Bot) consoleSummarize(args string) string {
+	query, rest := splitGroupArgs(args, b.buffer.GetGroupTopics())
+	topic, errMsg := b.resolveGroup(query)
+	if errMsg != "" {
+		return errMsg
+	}
+
+	cmd := summary.ParseArgs(rest, time.Now())
+	if _, running := b.activeSummaries.Load(topic); running {
+		return fmt.Sprintf("「%s」正在总结中", topic)
+	}
+	b.triggerSummary(topic, cmd)
+
+	scope := cmd.Describe()
+	if scope == "" {
+		scope = "全部"
+	}
+	return fmt.Sprintf("开始总结「%s」（%s，%d 条缓冲）", topic, scope, b.buffer.Count(topic))
+}
+
+const consoleBufferPreview = 5
+
+func (b *Bot) consoleBuffer(args string) string {
+	topic, errMsg := b.resolveGroup(args)
+	if errMsg != "" {
+		return errMsg
+	}
+
+	snapshot := b.buffer.GetSnapshot(topic)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "「%s」缓冲 %d 条", topic, snapshot.Count)
+	if snapshot.FirstMsgTime != nil && snapshot.LastMsgTime != nil {
+		fmt.Fprintf(&sb, "，%s - %s", snapshot.FirstMsgTime.Format("01-02 15:04"), snapshot.LastMsgTime.Format("01-02 15:04"))
+	}
+	fmt.Fprintf(&sb, "，%d 人参与", len(snapshot.Participants))
+
+	for _, msg := range b.buffer.GetMessages(topic, chat.Window{Last: consoleBufferPreview}) {
+		text := "[空]"
+		if msg.Content != nil {
+			text = truncate(msg.Content.Description(), 40)
+		}
+		fmt.Fprintf(&sb, "\n[%s] %s: %s", msg.Timestamp.Format("15:04"), msg.Sender, text)
+	}
+	return sb.String()
+}
+
+func (b *Bot) consolePrompt(args string) string {
+	if args != "reload" {
+		return "用法：/prompt reload"
+	}
+	n, err := b.generator.ReloadPrompts()
+	if err != nil {
+		return fmt.Sprintf("已重新加载 %d 个提示词文件，部分失败：%v", n, err)
+	}
+	return fmt.Sprintf("已重新加载 %d 个提示词文件", n)
+}
+
+func (b *Bot) consoleDigest(string) string {
+	if b.archive == nil {
+		return "每日汇总需要启用归档（ARCHIVE_ENABLED）"
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.sendDigest(time.Now())
+	}()
+	return "开始生成今天的汇总"
+}
+
+// consoleQuotes are the quote pairs accepted around a group name.
+var consoleQuotes = [][2]string{{`"`, `"`}, {"“", "”"}, {"「", "」"}}
+
+// splitGroupArgs splits console arguments into a group query and the rest.
+// The query is a quoted name, else the longest of topics the arguments start
+// with, so names containing spaces need no quotes, else the first word.
+func splitGroupArgs(args string, topics []string) (string, string) {
+	for _, q := range consoleQuotes {
+		if quoted, ok := strings.CutPrefix(args, q[0]); ok {
+			if query, rest, found := strings.Cut(quoted, q[1]); found {
+				return strings.TrimSpace(query), strings.TrimSpace(rest)
+			}
+		}
+	}
+
+	query := ""
+	for _, topic := range topics {
+		if len(topic) > len(query) && (args == topic || strings.HasPrefix(args, topic+" ")) {
+			query = topic
+		}
+	}
+	if query != "" {
+		return query, strings.TrimSpace(args[len(query):])
+	}
+
+	query, rest, _ := strings.Cut(args, " ")
+	return query, strings.TrimSpace(rest)
+}
+
+// resolveGroup finds the buffered group named by query: an exact name, or
+// the only name containing it. It returns a reply for the owner on failure.
+func (b *Bot) resolveGroup(query string) (string, string) {
+	if query == "" {
+		return "", "请指定群名"
+	}
+
+	var matches []string
+	for _, topic := range b.buffer.GetGroupTopics() {
+		if topic == query {
+			return topic, ""
+		}
+		if config.MatchGroup(query, topic) {
+			matches = append(matches, topic)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Sprintf("缓冲区中没有名称包含「%s」的群", query)
+	case 1:
+		return matches[0], ""
+	default:
+		sort.Strings(matches)
+		return "", fmt.Sprintf("「%s」匹配多个群：%s", query, strings.Join(matches, "、"))
+	}
+}
+
+// isPaused reports whether a group was paused from the console. Pauses last
+// until /resume or a restart.
+func (b *Bot) isPaused(groupTopic string) bool {
+	paused := false
+	b.paused.Range(func(key, _ any) bool {
+		paused = config.MatchGroup(key.(string), groupTopic)
+		return !paused
+	})
+	return paused
+}
+
+func (b *Bot) pausedGroups() []string {
+	var patterns []string
+	b.paused.Range(func(key, _ any) bool {
+		patterns = append(patterns, key.(string))
+		return true
+	})
+	sort.Strings(patterns)
+	return patterns
+}
+
+func (b *Bot) describePaused() string {
+	if paused := b.pausedGroups(); len(paused) > 0 {
+		return strings.Join(paused, "、")
+	}
+	return "无"
+}
+
+func truncate(s string, maxRunes int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	return string([]rune(s)[:maxRunes]) + "…"
+}
@@ -0,0 +1,37 @@
+package bot
+
+import "testing"
+
+func TestSplitGroupArgs(t *testing.T) {
+	topics := []string{"产品讨论群", "Team Alpha", "Team Alpha Ops", "Team"}
+
+	tests := []struct {
+		name  string
+		args  string
+		query string
+		rest  string
+	}{
+		{name: "empty"},
+		{name: "name only", args: "产品讨论群", query: "产品讨论群"},
+		{name: "name and args", args: "产品讨论群 2h 详细", query: "产品讨论群", rest: "2h 详细"},
+		{name: "partial name", args: "产品 最近100条", query: "产品", rest: "最近100条"},
+		{name: "name with space", args: "Team Alpha 2h", query: "Team Alpha", rest: "2h"},
+		{name: "longest name", args: "Team Alpha Ops 2h", query: "Team Alpha Ops", rest: "2h"},
+		{name: "shorter name", args: "Team 2h", query: "Team", rest: "2h"},
+		{name: "name prefix of word", args: "Teams 2h", query: "Teams", rest: "2h"},
+		{name: "quoted", args: `"Team Beta" @张三`, query: "Team Beta", rest: "@张三"},
+		{name: "quoted known name", args: `"Team" Alpha`, query: "Team", rest: "Alpha"},
+		{name: "chinese quotes", args: "「产品 讨论」 2h", query: "产品 讨论", rest: "2h"},
+		{name: "curly quotes", args: "“Team Beta”详细", query: "Team Beta", rest: "详细"},
+		{name: "unclosed quote", args: `"Team Beta 2h`, query: `"Team`, rest: "Beta 2h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, rest := splitGroupArgs(tt.args, topics)
+			if query != tt.query || rest != tt.rest {
+				t.Errorf("splitGroupArgs(%q) = %q, %q, want %q, %q", tt.args, query, rest, tt.query, tt.rest)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/archive"
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/logic/summary"
+	"github.com/soaringk/msg-asst/pkg/cron"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const digestCheckPeriod = 30 * time.Second
+
+// digestMaxSummaries caps the summaries read from the archive for one digest.
+const digestMaxSummaries = 500
+
+// startDigest sends the cross-group digest on DIGEST_SCHEDULE. The schedule
+// is re-read on every check, so it can be changed or enabled without a
+// restart. Digests missed while the bot was down are not sent afterwards.
+func (b *Bot) startDigest() {
+	if b.archive == nil {
+		if config.GetConfig().Digest.Schedule != "" {
+			logging.Warn("Daily digest needs the archive, ARCHIVE_ENABLED is false")
+		}
+		return
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(digestCheckPeriod)
+		defer ticker.Stop()
+
+		var expr string
+		var sched *cron.Schedule
+		var next time.Time
+		for {
+			select {
+			case now := <-ticker.C:
+				if cfg := config.GetConfig().Digest; cfg.Schedule != expr {
+					expr, sched, next = cfg.Schedule, nil, time.Time{}
+					if expr != "" {
+						parsed, err := cron.Parse(expr)
+						if err != nil {
+							logging.Error("Invalid digest schedule, ignoring", zap.String("schedule", expr), zap.Error(err))
+							continue
+						}
+						sched, next = parsed, parsed.Next(now)
+						logging.Info("Daily digest scheduled", zap.String("schedule", expr), zap.Time("next", next))
+					}
+				}
+				if sched == nil || next.IsZero() || now.Before(next) {
+					continue
+				}
+
+				b.sendDigest(now)
+				next = sched.Next(now)
+			case <-b.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// sendDigest merges the full summaries archived since midnight of now's day,
+// in local time, and delivers the digest. Windowed command summaries are
+// left out, as their messages are summarized again in full. The digest itself
+// is not archived, so it never feeds into the next one.
+func (b *Bot) sendDigest(now time.Time) {
+	cfg := config.GetConfig()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	records, err := b.archive.Summaries(archive.Query{Since: since, Until: now, FullOnly: true, Limit: digestMaxSummaries})
+	if err != nil {
+		logging.Error("Failed to load summaries for digest", zap.Error(err))
+		return
+	}
+
+	results := make([]summary.Result, len(records))
+	for i, rec := range records {
+		results[i] = summary.Result{
+			Text:         rec.Text,
+			Body:         summary.StripHeader(rec.Text),
+			GroupTopic:   rec.GroupTopic,
+			MessageCount: rec.MessageCount,
+			FirstMsgTime: rec.FirstMsgTime,
+			LastMsgTime:  rec.LastMsgTime,
+			Model:        rec.Model,
+			PromptHash:   rec.PromptHash,
+		}
+	}
+
+	logging.Info("Generating daily digest", zap.Int("summaries", len(results)))
+	digest, err := b.generator.GenerateDigest(b.ctx, now, results)
+	if err != nil {
+		b.reportLLMError("每日汇总", err)
+		return
+	}
+	if digest.SkipReason != "" {
+		logging.Info("Digest skipped", zap.String("reason", digest.SkipReason))
+		return
+	}
+
+	targets := cfg.Digest.Targets
+	if len(targets) == 0 {
+		targets = cfg.Delivery.Targets
+	}
+	if err := b.router.DeliverTo(b.ctx, targets, digest); err != nil {
+		logging.Error("Error sending digest", zap.Error(err))
+		return
+	}
+	logging.Info("Daily digest sent", zap.Int("summaries", len(results)))
+}
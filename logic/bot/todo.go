@@ -0,0 +1,221 @@
+package bot
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/entity/todo"
+	"github.com/soaringk/msg-asst/logic/summary"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const reminderCheckPeriod = time.Minute
+
+// reminderTopic labels reminder results, which may cover several groups.
+const reminderTopic = "待办提醒"
+
+func newTodos() *todo.Tracker {
+	cfg := config.GetConfig().Todo
+	if !cfg.Enabled {
+		return nil
+	}
+
+	tracker, err := todo.Open(cfg.File)
+	if err != nil {
+		logging.Error("Failed to open action item tracker, action items will not be tracked",
+			zap.String("path", cfg.File),
+			zap.Error(err))
+		return nil
+	}
+	return tracker
+}
+
+// trackActionItems records the action items of a delivered summary. Only
+// structured minutes carry them, so a summary without them is noted for
+// /todo and logged once until structured minutes return.
+func (b *Bot) trackActionItems(result summary.Result) {
+	if b.todos == nil {
+		return
+	}
+	if result.Minutes == nil {
+		if _, loaded := b.untracked.LoadOrStore(result.GroupTopic, struct{}{}); !loaded {
+			logging.Warn("Summary has no structured minutes, its action items are not tracked",
+				zap.String("group", result.GroupTopic),
+				zap.Bool("structuredOutput", config.GetConfig().LLMStructuredOutput))
+		}
+		return
+	}
+	b.untracked.Delete(result.GroupTopic)
+	if len(result.Minutes.ActionItems) == 0 {
+		return
+	}
+
+	added, err := b.todos.Add(result.GroupTopic, result.Minutes.ActionItems, time.Now())
+	if err != nil {
+		logging.Error("Failed to save action items", zap.String("group", result.GroupTopic), zap.Error(err))
+		return
+	}
+	logging.Debug("Action items tracked",
+		zap.String("group", result.GroupTopic),
+		zap.Int("items", len(result.Minutes.ActionItems)),
+		zap.Int("added", added))
+}
+
+// startReminders pushes a reminder for action items coming due within
+// TODO_REMIND_BEFORE_HOURS, once per item.
+func (b *Bot) startReminders() {
+	if b.todos == nil {
+		return
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(reminderCheckPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				b.sendReminders(now)
+			case <-b.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (b *Bot) sendReminders(now time.Time) {
+	cfg := config.GetConfig().Todo
+	due := b.todos.Due(now, time.Duration(cfg.RemindBeforeHours)*time.Hour)
+	if len(due) == 0 {
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("⏰ 待办提醒\n")
+	ids := make([]int, len(due))
+	for i, item := range due {
+		ids[i] = item.ID
+		sb.WriteString(formatTodo(item, now))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n完成后发送 /todo done <编号>")
+
+	text := sb.String()
+	result := summary.Result{Text: text, Body: text, GroupTopic: reminderTopic}
+	if err := b.router.DeliverTo(b.ctx, cfg.RemindTargets, result); err != nil {
+		logging.Error("Error sending action item reminder", zap.Error(err))
+		return
+	}
+	if err := b.todos.MarkReminded(ids, now); err != nil {
+		logging.Error("Failed to save reminder state", zap.Error(err))
+	}
+	logging.Info("Action item reminder sent", zap.Int("items", len(due)))
+}
+
+// consoleTodo lists open action items, optionally of groups matching args,
+// or marks items done with "done <id>...".
+func (b *Bot) consoleTodo(args string) string {
+	if b.todos == nil {
+		return "待办跟踪未启用（TODO_ENABLED）"
+	}
+
+	if rest, ok := strings.CutPrefix(args, "done"); ok && (rest == "" || rest[0] == ' ') {
+		return b.consoleTodoDone(rest)
+	}
+
+	now := time.Now()
+	var sb strings.Builder
+	count := 0
+	for _, item := range b.todos.Pending() {
+		if args != "" && !config.MatchGroup(args, item.Group) {
+			continue
+		}
+		count++
+		sb.WriteString(formatTodo(item, now))
+		if item.Source != "" {
+			fmt.Fprintf(&sb, "\n  ↳ %s", truncate(item.Source, 40))
+		}
+		sb.WriteString("\n")
+	}
+	note := b.untrackedNote(args)
+	if count == 0 {
+		return "没有未完成的待办" + note
+	}
+	return fmt.Sprintf("未完成待办 %d 项：\n%s\n完成后发送 /todo done <编号>%s", count, sb.String(), note)
+}
+
+// untrackedNote explains why action items may be missing: they are only read
+// from structured minutes, which LLM_STRUCTURED_OUTPUT or the provider may
+// rule out.
+func (b *Bot) untrackedNote(args string) string {
+	if !config.GetConfig().LLMStructuredOutput {
+		return "\n\n待办只从结构化纪要中提取，LLM_STRUCTURED_OUTPUT 已关闭"
+	}
+	var groups []string
+	b.untracked.Range(func(key, _ any) bool {
+		if group := key.(string); args == "" || config.MatchGroup(args, group) {
+			groups = append(groups, group)
+		}
+		return true
+	})
+	if len(groups) == 0 {
+		return ""
+	}
+	slices.Sort(groups)
+	return fmt.Sprintf("\n\n以下群最近的纪要不是结构化输出（模型可能不支持），其中的待办未被记录：%s", strings.Join(groups, "、"))
+}
+
+func (b *Bot) consoleTodoDone(args string) string {
+	fields := strings.FieldsFunc(args, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '，' || r == '#'
+	})
+	if len(fields) == 0 {
+		return "用法：/todo done <编号>"
+	}
+
+	var lines []string
+	for _, field := range fields {
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("无效编号「%s」", field))
+			continue
+		}
+		item, err := b.todos.Complete(id, time.Now())
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("#%d：%v", id, err))
+			continue
+		}
+		logging.Info("Action item completed from console", zap.Int("id", id), zap.String("group", item.Group))
+		lines = append(lines, fmt.Sprintf("✅ #%d %s", id, item.Task))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatTodo renders an item as "#3 写发布说明（@李四，截止：周四）[产品群]".
+func formatTodo(item todo.Item, now time.Time) string {
+	var meta []string
+	if item.Owner != "" {
+		meta = append(meta, "@"+item.Owner)
+	}
+	switch {
+	case !item.DueAt.IsZero() && item.DueAt.Before(now):
+		meta = append(meta, fmt.Sprintf("已逾期，截止：%s", item.DueAt.Format("01-02 15:04")))
+	case !item.DueAt.IsZero():
+		meta = append(meta, fmt.Sprintf("截止：%s", item.DueAt.Format("01-02 15:04")))
+	case item.Due != "":
+		meta = append(meta, "截止："+item.Due)
+	}
+
+	line := fmt.Sprintf("#%d %s", item.ID, item.Task)
+	if len(meta) > 0 {
+		line += fmt.Sprintf("（%s）", strings.Join(meta, "，"))
+	}
+	return line + fmt.Sprintf("[%s]", item.Group)
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/entity/usage"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// maxUsageDays bounds the period /usage reports on.
+const maxUsageDays = 31
+
+func newUsage() *usage.Ledger {
+	path := config.GetConfig().Usage.File
+	ledger, err := usage.Open(path)
+	if err != nil {
+		logging.Error("Failed to open usage ledger, usage will only be logged",
+			zap.String("path", path),
+			zap.Error(err))
+		return nil
+	}
+	return ledger
+}
+
+// consoleUsage reports token usage and spend per day, group and model. args
+// may give the number of days, default today, and a group filter.
+func (b *Bot) consoleUsage(args string) string {
+	if b.usage == nil {
+		return "用量记录未启用（USAGE_FILE 无法打开）"
+	}
+
+	days := 1
+	var filter string
+	for _, arg := range strings.Fields(args) {
+		if n, err := strconv.Atoi(strings.TrimSuffix(arg, "天")); err == nil && n > 0 {
+			days = min(n, maxUsageDays)
+		} else {
+			filter = arg
+		}
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, 0, 1-days)
+	cfg := config.GetConfig().Usage
+
+	var sb strings.Builder
+	today := now.Format("2006-01-02")
+	var total, spentToday float64
+	day := ""
+	for _, e := range b.usage.Entries(since) {
+		if filter != "" && !config.MatchGroup(filter, e.Group) {
+			continue
+		}
+		if e.Day != day {
+			day = e.Day
+			fmt.Fprintf(&sb, "\n📅 %s\n", day)
+		}
+		total += e.Cost
+		if e.Day == today {
+			spentToday += e.Cost
+		}
+		fmt.Fprintf(&sb, "%s · %s：%d 次，输入 %s", e.Group, e.Model, e.Requests, formatTokens(e.PromptTokens))
+		if e.CachedTokens > 0 || e.MediaTokens > 0 {
+			fmt.Fprintf(&sb, "（缓存 %s，媒体 %s）", formatTokens(e.CachedTokens), formatTokens(e.MediaTokens))
+		}
+		fmt.Fprintf(&sb, "，输出 %s，费用 %.4f\n", formatTokens(e.CompletionTokens), e.Cost)
+	}
+	if day == "" {
+		return fmt.Sprintf("最近 %d 天没有用量记录", days)
+	}
+
+	header := fmt.Sprintf("💰 最近 %d 天用量，合计费用 %.4f", days, total)
+	if len(cfg.Prices) == 0 {
+		header += "\n未配置 LLM_PRICES，费用按 0 计"
+	}
+	// Budgets are per group, so show one only for a group filter
+	if filter != "" {
+		if budget := config.ForGroup(filter).Usage.DailyBudget; budget > 0 {
+			header += fmt.Sprintf("\n%s 每日预算 %.4f，今日已用 %.4f", filter, budget, spentToday)
+		}
+	}
+	return header + "\n" + sb.String()
+}
+
+// formatTokens renders a token count as "850" or "12.3k".
+func formatTokens(n int) string {
+	if n < 1000 {
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}
@@ -0,0 +1,82 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/soaringk/msg-asst/logic/summary"
+)
+
+// maxFileAttempts bounds the numbered names tried when summaries of a group
+// are written within the same second.
+const maxFileAttempts = 100
+
+// dirSink writes each summary to its own Markdown file in a local directory.
+type dirSink struct {
+	dir string
+}
+
+func (s *dirSink) Name() string {
+	return "dir:" + s.dir
+}
+
+func (s *dirSink) Deliver(_ context.Context, result summary.Result) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create delivery directory: %w", err)
+	}
+
+	base := fmt.Sprintf("%s-%s", sanitizeFileName(result.GroupTopic), time.Now().Format("20060102-150405"))
+	file, err := createUnique(s.dir, base)
+	if err != nil {
+		return fmt.Errorf("failed to create summary file: %w", err)
+	}
+	_, err = file.WriteString(result.Text + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write summary file: %w", err)
+	}
+	return nil
+}
+
+// createUnique creates base.md in dir, or base-2.md, base-3.md and so on when
+// the name is taken, so an earlier summary is never overwritten.
+func createUnique(dir, base string) (*os.File, error) {
+	for seq := 1; ; seq++ {
+		name := base + ".md"
+		if seq > 1 {
+			name = fmt.Sprintf("%s-%d.md", base, seq)
+		}
+		file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) && seq < maxFileAttempts {
+			continue
+		}
+		return file, err
+	}
+}
+
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 0x20 {
+			return '_'
+		}
+		return r
+	}, name)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "group"
+	}
+	return name
+}
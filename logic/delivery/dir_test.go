@@ -0,0 +1,24 @@
+package delivery
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestDirSinkSameSecond(t *testing.T) {
+	sink := &dirSink{dir: t.TempDir()}
+	for range 3 {
+		if err := sink.Deliver(context.Background(), testResult()); err != nil {
+			t.Fatalf("Deliver() failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(sink.dir)
+	if err != nil {
+		t.Fatalf("Failed to read target dir: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("Expected 3 summary files, got %d", len(entries))
+	}
+}
@@ -0,0 +1,143 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/eatmoreapple/openwechat"
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/logic/summary"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Sink delivers a finished summary to one destination.
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, result summary.Result) error
+}
+
+// Router resolves the configured targets of a group into sinks and delivers
+// summaries to all of them. Targets are re-read on every delivery, so routing
+// changes in .env take effect without a restart.
+type Router struct {
+	self *openwechat.Self
+	log  *zap.Logger
+}
+
+func NewRouter(self *openwechat.Self) *Router {
+	return &Router{
+		self: self,
+		log:  logging.Named("delivery"),
+	}
+}
+
+// Sinks returns the sinks configured for a group. Invalid targets are logged
+// and skipped.
+func (r *Router) Sinks(groupTopic string) []Sink {
+	return r.sinks(groupTopic, config.ForGroup(groupTopic).Delivery.Targets)
+}
+
+func (r *Router) sinks(groupTopic string, targets []string) []Sink {
+	sinks := make([]Sink, 0, len(targets))
+	for _, target := range targets {
+		sink, err := r.parseTarget(target)
+		if err != nil {
+			r.log.Warn("Invalid delivery target, skipping",
+				zap.String("group", groupTopic),
+				zap.String("target", target),
+				zap.Error(err))
+			continue
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks
+}
+
+// Deliver sends result to every sink of its group. It only fails when no sink
+// succeeded, so a summary that reached at least one destination is not
+// regenerated and sent again to the others.
+func (r *Router) Deliver(ctx context.Context, result summary.Result) error {
+	return r.deliver(ctx, r.Sinks(result.GroupTopic), result)
+}
+
+// DeliverTo sends result to explicit targets instead of those of its group,
+// e.g. for the daily digest, which belongs to no group. The group target is
+// skipped since there is no group to post back to.
+func (r *Router) DeliverTo(ctx context.Context, targets []string, result summary.Result) error {
+	sinks := slices.DeleteFunc(r.sinks(result.GroupTopic, targets), func(sink Sink) bool {
+		if _, ok := sink.(*groupSink); !ok {
+			return false
+		}
+		r.log.Warn("Group target does not apply here, skipping", zap.String("group", result.GroupTopic))
+		return true
+	})
+	return r.deliver(ctx, sinks, result)
+}
+
+func (r *Router) deliver(ctx context.Context, sinks []Sink, result summary.Result) error {
+	if len(sinks) == 0 {
+		return fmt.Errorf("no delivery targets configured for %q", result.GroupTopic)
+	}
+
+	var errs []error
+	delivered := 0
+	for _, sink := range sinks {
+		if err := sink.Deliver(ctx, result); err != nil {
+			r.log.Error("Delivery failed",
+				zap.String("group", result.GroupTopic),
+				zap.String("sink", sink.Name()),
+				zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+			continue
+		}
+		delivered++
+		r.log.Debug("Summary delivered",
+			zap.String("group", result.GroupTopic),
+			zap.String("sink", sink.Name()))
+	}
+
+	if delivered == 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// parseTarget builds a sink from a target spec: "filehelper", "group",
+// "contact:<name>", "dir:<path>" or "webhook[:<url>]".
+func (r *Router) parseTarget(target string) (Sink, error) {
+	kind, arg, _ := strings.Cut(target, ":")
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	arg = strings.TrimSpace(arg)
+
+	switch kind {
+	case "filehelper":
+		return &fileHelperSink{self: r.self}, nil
+	case "group":
+		return &groupSink{self: r.self}, nil
+	case "contact":
+		if arg == "" {
+			return nil, errors.New("contact target requires a name")
+		}
+		return &contactSink{self: r.self, name: arg}, nil
+	case "dir":
+		if arg == "" {
+			return nil, errors.New("dir target requires a path")
+		}
+		return &dirSink{dir: arg}, nil
+	case "webhook":
+		cfg := config.GetConfig().Webhook
+		if arg == "" {
+			arg = cfg.URL
+		}
+		if arg == "" {
+			return nil, errors.New("webhook target requires WEBHOOK_URL or an explicit URL")
+		}
+		return newWebhookSink(arg, cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown target type %q", kind)
+	}
+}
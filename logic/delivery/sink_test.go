@@ -0,0 +1,29 @@
+package delivery
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/soaringk/msg-asst/pkg/logging"
+)
+
+func TestDeliverToSkipsGroup(t *testing.T) {
+	router := &Router{log: logging.Named("delivery-test")}
+	dir := t.TempDir()
+
+	if err := router.DeliverTo(context.Background(), []string{"group", "dir:" + dir}, testResult()); err != nil {
+		t.Fatalf("DeliverTo() failed: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read target dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected 1 delivered file, got %d", len(entries))
+	}
+
+	if err := router.DeliverTo(context.Background(), []string{"group"}, testResult()); err == nil {
+		t.Error("Expected DeliverTo() to fail with only a group target")
+	}
+}
@@ -0,0 +1,243 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/entity/llm"
+	"github.com/soaringk/msg-asst/logic/summary"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	headerTimestamp = "X-MsgAsst-Timestamp"
+	headerSignature = "X-MsgAsst-Signature"
+	headerDelivery  = "X-MsgAsst-Delivery"
+
+	maxRetryDelay = time.Minute
+)
+
+type webhookPayload struct {
+	ID           string       `json:"id"`
+	Group        string       `json:"group"`
+	StartTime    time.Time    `json:"start_time,omitzero"`
+	EndTime      time.Time    `json:"end_time,omitzero"`
+	Participants []string     `json:"participants"`
+	MessageCount int          `json:"message_count"`
+	Summary      string       `json:"summary"`
+	Sections     *llm.Minutes `json:"sections,omitempty"`
+	Model        string       `json:"model,omitempty"`
+	GeneratedAt  time.Time    `json:"generated_at"`
+}
+
+type deadLetter struct {
+	URL      string          `json:"url"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failed_at"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// permanentError marks a response that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// webhookSink POSTs each summary as signed JSON. Failed deliveries are retried
+// with jittered exponential backoff and written to the dead-letter directory
+// once the retries are exhausted. A delivery cancelled by ctx, e.g. on
+// shutdown, is not a failure and leaves no dead letter.
+type webhookSink struct {
+	url           string
+	secret        string
+	client        *http.Client
+	maxRetries    int
+	baseDelay     time.Duration
+	deadLetterDir string
+	log           *zap.Logger
+}
+
+func newWebhookSink(url string, cfg config.WebhookConfig) *webhookSink {
+	return &webhookSink{
+		url:           url,
+		secret:        cfg.Secret,
+		client:        &http.Client{Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second},
+		maxRetries:    cfg.MaxRetries,
+		baseDelay:     time.Duration(cfg.RetryBaseSeconds) * time.Second,
+		deadLetterDir: cfg.DeadLetterDir,
+		log:           logging.Named("webhook"),
+	}
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Deliver(ctx context.Context, result summary.Result) error {
+	payload := webhookPayload{
+		ID:           newDeliveryID(),
+		Group:        result.GroupTopic,
+		StartTime:    result.FirstMsgTime,
+		EndTime:      result.LastMsgTime,
+		Participants: result.Participants,
+		MessageCount: result.MessageCount,
+		Summary:      result.Text,
+		Sections:     result.Minutes,
+		Model:        result.Model,
+		GeneratedAt:  time.Now(),
+	}
+	if payload.Participants == nil {
+		payload.Participants = []string{}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	attempts := 0
+	for {
+		attempts++
+		err = s.post(ctx, payload.ID, body)
+		if err == nil {
+			s.log.Debug("Webhook delivered",
+				zap.String("id", payload.ID),
+				zap.Int("attempts", attempts))
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempts > s.maxRetries {
+			break
+		}
+
+		delay := s.backoff(attempts)
+		s.log.Warn("Webhook delivery failed, retrying",
+			zap.String("id", payload.ID),
+			zap.Int("attempt", attempts),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if dlErr := s.deadLetter(payload.ID, body, attempts, err); dlErr != nil {
+		s.log.Error("Failed to write webhook dead letter", zap.String("id", payload.ID), zap.Error(dlErr))
+	}
+	return fmt.Errorf("webhook delivery failed after %d attempts: %w", attempts, err)
+}
+
+func (s *webhookSink) post(ctx context.Context, id string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to build webhook request: %w", err)}
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerDelivery, id)
+	req.Header.Set(headerTimestamp, timestamp)
+	if s.secret != "" {
+		req.Header.Set(headerSignature, "sha256="+sign(s.secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	statusErr := fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return statusErr
+	}
+	return &permanentError{statusErr}
+}
+
+// backoff returns the delay before retry attempt+1: a random value between
+// half and all of baseDelay*2^(attempt-1), capped at maxRetryDelay, so
+// receivers recovering from an outage are not hit by every sender at once.
+func (s *webhookSink) backoff(attempt int) time.Duration {
+	delay := s.baseDelay << (attempt - 1)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay/2 + mathrand.N(delay/2+1)
+}
+
+func (s *webhookSink) deadLetter(id string, body []byte, attempts int, cause error) error {
+	if s.deadLetterDir == "" {
+		return errors.New("dead-letter directory not configured")
+	}
+	if err := os.MkdirAll(s.deadLetterDir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(deadLetter{
+		URL:      s.url,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		FailedAt: time.Now(),
+		Payload:  body,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.deadLetterDir, fmt.Sprintf("%s-%s.json", time.Now().Format("20060102-150405"), id))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+
+	s.log.Warn("Webhook payload dead-lettered", zap.String("id", id), zap.String("path", path))
+	return nil
+}
+
+// sign returns the hex HMAC-SHA256 of "<timestamp>.<body>". Including the
+// timestamp lets receivers reject replayed requests.
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDeliveryID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,187 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soaringk/msg-asst/logic/summary"
+	"github.com/soaringk/msg-asst/pkg/logging"
+)
+
+func testResult() summary.Result {
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	return summary.Result{
+		Text:         "# 🤖 产品群 会议纪要\n\n决定：周一发布",
+		GroupTopic:   "产品群",
+		MessageCount: 12,
+		FirstMsgTime: start,
+		LastMsgTime:  start.Add(30 * time.Minute),
+		Participants: []string{"张三", "李四"},
+		Model:        "gemini-2.5-flash",
+	}
+}
+
+func testWebhookSink(url, deadLetterDir string) *webhookSink {
+	return &webhookSink{
+		url:           url,
+		secret:        "s3cret",
+		client:        &http.Client{Timeout: time.Second},
+		maxRetries:    2,
+		baseDelay:     time.Millisecond,
+		deadLetterDir: deadLetterDir,
+		log:           logging.Named("webhook-test"),
+	}
+}
+
+func TestWebhookSignedPayload(t *testing.T) {
+	var received webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		timestamp := r.Header.Get(headerTimestamp)
+		want := "sha256=" + sign("s3cret", timestamp, body)
+		if got := r.Header.Get(headerSignature); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		if r.Header.Get(headerDelivery) == "" {
+			t.Error("missing delivery ID header")
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := testWebhookSink(server.URL, t.TempDir())
+	if err := sink.Deliver(context.Background(), testResult()); err != nil {
+		t.Fatalf("Deliver() failed: %v", err)
+	}
+
+	want := testResult()
+	if received.Group != want.GroupTopic || received.MessageCount != 12 || received.Summary != want.Text {
+		t.Errorf("unexpected payload: %+v", received)
+	}
+	if len(received.Participants) != 2 || !received.StartTime.Equal(want.FirstMsgTime) || !received.EndTime.Equal(want.LastMsgTime) {
+		t.Errorf("time range or participants not carried: %+v", received)
+	}
+}
+
+func TestWebhookRetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	var firstID, lastID atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n == 1 {
+			firstID.Store(r.Header.Get(headerDelivery))
+		}
+		lastID.Store(r.Header.Get(headerDelivery))
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink := testWebhookSink(server.URL, t.TempDir())
+	if err := sink.Deliver(context.Background(), testResult()); err != nil {
+		t.Fatalf("Deliver() failed: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls.Load())
+	}
+	if firstID.Load() != lastID.Load() {
+		t.Error("Delivery ID changed between retries")
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantAttempts int32
+	}{
+		{"retries exhausted", http.StatusInternalServerError, 3},
+		{"permanent failure", http.StatusBadRequest, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			dir := t.TempDir()
+			sink := testWebhookSink(server.URL, dir)
+			if err := sink.Deliver(context.Background(), testResult()); err == nil {
+				t.Fatal("Deliver() should fail")
+			}
+			if calls.Load() != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", calls.Load(), tt.wantAttempts)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+			if len(files) != 1 {
+				t.Fatalf("Expected 1 dead letter, got %d", len(files))
+			}
+			data, _ := os.ReadFile(files[0])
+			var letter deadLetter
+			if err := json.Unmarshal(data, &letter); err != nil {
+				t.Fatalf("invalid dead letter: %v", err)
+			}
+			var payload webhookPayload
+			if err := json.Unmarshal(letter.Payload, &payload); err != nil || payload.Group != "产品群" {
+				t.Errorf("dead letter payload not preserved: %s", letter.Payload)
+			}
+			if letter.Attempts != int(tt.wantAttempts) || letter.URL != server.URL {
+				t.Errorf("unexpected dead letter: %+v", letter)
+			}
+		})
+	}
+}
+
+func TestWebhookCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	sink := testWebhookSink(server.URL, dir)
+	sink.baseDelay = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := sink.Deliver(ctx, testResult()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Deliver() error = %v, want context.Canceled", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 0 {
+		t.Errorf("Expected no dead letter after cancellation, got %d", len(files))
+	}
+}
+
+func TestWebhookBackoffJitter(t *testing.T) {
+	sink := testWebhookSink("", "")
+	sink.baseDelay = time.Second
+	for attempt := 1; attempt <= 8; attempt++ {
+		full := min(time.Second<<(attempt-1), maxRetryDelay)
+		if d := sink.backoff(attempt); d < full/2 || d > full {
+			t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, d, full/2, full)
+		}
+	}
+}
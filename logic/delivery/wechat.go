@@ -0,0 +1,87 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eatmoreapple/openwechat"
+	"github.com/soaringk/msg-asst/logic/summary"
+)
+
+// fileHelperSink posts to the logged-in user's File Transfer Helper.
+type fileHelperSink struct {
+	self *openwechat.Self
+}
+
+func (s *fileHelperSink) Name() string {
+	return "filehelper"
+}
+
+func (s *fileHelperSink) Deliver(_ context.Context, result summary.Result) error {
+	if s.self == nil {
+		return fmt.Errorf("self user not available")
+	}
+	_, err := s.self.FileHelper().SendText(result.Text)
+	return err
+}
+
+// groupSink posts the minutes back into the group they summarize.
+type groupSink struct {
+	self *openwechat.Self
+}
+
+func (s *groupSink) Name() string {
+	return "group"
+}
+
+func (s *groupSink) Deliver(_ context.Context, result summary.Result) error {
+	if s.self == nil {
+		return fmt.Errorf("self user not available")
+	}
+
+	groups, err := s.self.Groups()
+	if err != nil {
+		return fmt.Errorf("failed to get groups: %w", err)
+	}
+
+	group := groups.SearchByNickName(1, result.GroupTopic).First()
+	if group == nil {
+		return fmt.Errorf("group %q not found", result.GroupTopic)
+	}
+
+	_, err = group.SendText(result.Text)
+	return err
+}
+
+// contactSink sends the minutes to a friend, matched by remark name first and
+// then by nickname.
+type contactSink struct {
+	self *openwechat.Self
+	name string
+}
+
+func (s *contactSink) Name() string {
+	return "contact:" + s.name
+}
+
+func (s *contactSink) Deliver(_ context.Context, result summary.Result) error {
+	if s.self == nil {
+		return fmt.Errorf("self user not available")
+	}
+
+	friends, err := s.self.Friends()
+	if err != nil {
+		return fmt.Errorf("failed to get friends: %w", err)
+	}
+
+	friend := friends.SearchByRemarkName(1, s.name).First()
+	if friend == nil {
+		friend = friends.SearchByNickName(1, s.name).First()
+	}
+	if friend == nil {
+		return fmt.Errorf("contact %q not found", s.name)
+	}
+
+	_, err = friend.SendText(result.Text)
+	return err
+}
@@ -0,0 +1,308 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/entity/llm"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// cacheSize bounds the results remembered by media hash, so a voice
+	// message or image forwarded to several groups is processed once.
+	cacheSize = 256
+
+	// workers and queueSize bound the media processed in the background;
+	// media arriving while the queue is full is buffered as it is.
+	workers   = 4
+	queueSize = 64
+
+	// processTimeout caps the time spent on one message, retries included.
+	processTimeout = 2 * time.Minute
+)
+
+// Pipeline prepares incoming content in the background once it is buffered.
+// Voice messages are transcribed and images captioned once on arrival, so
+// summaries carry their text rather than the media.
+type Pipeline struct {
+	stage atomic.Pointer[stage]
+	log   *zap.Logger
+	// recordUsage charges the LLM usage of a message to its group
+	recordUsage func(groupTopic string, used []llm.Usage)
+
+	mu    sync.Mutex
+	cache map[string]result // media hash -> result
+	order []string          // hashes in insertion order, for eviction
+
+	jobs    chan job
+	wg      sync.WaitGroup
+	closeMu sync.RWMutex // held for reading while submitting, so Close never races a send
+	closed  bool
+}
+
+type job struct {
+	ctx        context.Context
+	groupTopic string
+	content    *chat.Content
+	done       func(*chat.Content)
+}
+
+type stage struct {
+	transcriber  llm.Transcriber // nil when transcription is off
+	local        bool            // audio stays on premises
+	captioner    llm.Captioner   // nil when captioning is off
+	captionLocal bool            // images stay on premises
+}
+
+// result is what was learned from one media file.
+type result struct {
+	transcript string
+	caption    llm.Caption
+}
+
+func New() *Pipeline {
+	p := &Pipeline{
+		cache: make(map[string]result),
+		log:   logging.Named("ingest"),
+	}
+	p.reload()
+	config.OnConfigChange(p.reload)
+	p.start()
+	return p
+}
+
+// SetUsage sets where the LLM usage of transcription and captioning is
+// recorded, charged to the group the message came from.
+func (p *Pipeline) SetUsage(record func(groupTopic string, used []llm.Usage)) {
+	p.recordUsage = record
+}
+
+// reload rebuilds the transcriber and the captioner. Each keeps its
+// previous version when it fails to build, so one bad setting does not
+// turn off the other.
+func (p *Pipeline) reload() {
+	cfg := config.GetConfig()
+	var next stage
+	if prev := p.stage.Load(); prev != nil {
+		next = *prev
+	}
+
+	if t, err := llm.NewTranscriber(cfg); err != nil {
+		p.log.Error("Failed to create transcriber", zap.Error(err))
+	} else {
+		next.transcriber = t
+		next.local = cfg.Transcribe.Local
+	}
+	if c, err := llm.NewCaptioner(cfg); err != nil {
+		p.log.Error("Failed to create captioner", zap.Error(err))
+	} else {
+		pc, _ := cfg.CaptionProvider()
+		next.captioner = c
+		next.captionLocal = pc.IsLocal()
+	}
+	p.stage.Store(&next)
+}
+
+func (p *Pipeline) start() {
+	p.jobs = make(chan job, queueSize)
+	for range workers {
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+// Close waits for the submitted messages. Cancel their context first to
+// skip the work that is left.
+func (p *Pipeline) Close() {
+	p.closeMu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.closeMu.Unlock()
+	p.wg.Wait()
+}
+
+// Submit prepares c, a message of groupTopic, in the background and calls
+// done once with the result. c itself is left alone, since the buffer
+// shares it; done gets a copy when there was anything to do. done is called
+// right away when there is nothing to do or the queue is full.
+func (p *Pipeline) Submit(ctx context.Context, groupTopic string, c *chat.Content, done func(*chat.Content)) {
+	if !p.Wants(c) {
+		done(c)
+		return
+	}
+
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		done(c)
+		return
+	}
+	select {
+	case p.jobs <- job{ctx: ctx, groupTopic: groupTopic, content: c, done: done}:
+	default:
+		p.log.Warn("Ingest queue full, buffering media as it is",
+			zap.String("group", groupTopic),
+			zap.String("type", string(c.Type)))
+		done(c)
+	}
+}
+
+func (p *Pipeline) work() {
+	defer p.wg.Done()
+	for j := range p.jobs {
+		c := *j.content
+		if j.ctx.Err() == nil {
+			ctx, cancel := context.WithTimeout(j.ctx, processTimeout)
+			p.Process(ctx, j.groupTopic, &c)
+			cancel()
+		}
+		j.done(&c)
+	}
+}
+
+// Wants reports whether Submit would process c in the background.
+func (p *Pipeline) Wants(c *chat.Content) bool {
+	st := p.stage.Load()
+	if st == nil || len(c.Data) == 0 {
+		return false
+	}
+	switch c.Type {
+	case chat.ContentTypeAudio:
+		return st.transcriber != nil && c.Transcript == ""
+	case chat.ContentTypeImage:
+		return st.captioner != nil && c.Caption == ""
+	}
+	return false
+}
+
+// Process prepares c, a message of groupTopic, in place. Failures leave c
+// as it is.
+func (p *Pipeline) Process(ctx context.Context, groupTopic string, c *chat.Content) {
+	if len(c.Data) == 0 {
+		return
+	}
+	if p.recordUsage != nil {
+		var used func() []llm.Usage
+		ctx, used = llm.CollectUsage(ctx)
+		defer func() { p.recordUsage(groupTopic, used()) }()
+	}
+	switch {
+	case c.Type == chat.ContentTypeAudio && c.Transcript == "":
+		p.transcribe(ctx, groupTopic, c)
+	case c.Type == chat.ContentTypeImage && c.Caption == "":
+		p.caption(ctx, groupTopic, c)
+	}
+}
+
+func (p *Pipeline) transcribe(ctx context.Context, groupTopic string, c *chat.Content) {
+	st := p.stage.Load()
+	if st == nil || st.transcriber == nil {
+		return
+	}
+	// Groups bound to a local provider keep audio off cloud services
+	if !st.local && config.ForGroup(groupTopic).OnPremOnly() {
+		p.log.Debug("Skipping cloud transcription for on-prem group", zap.String("group", groupTopic))
+		return
+	}
+
+	key := hash(c.Data)
+	if r, ok := p.cached(key); ok {
+		c.Transcript = r.transcript
+		return
+	}
+
+	start := time.Now()
+	text, err := st.transcriber.Transcribe(ctx, c)
+	if errors.Is(err, llm.ErrUnsupportedAudio) {
+		p.log.Info("Voice message not transcribed", zap.String("group", groupTopic), zap.Error(err))
+		return
+	}
+	if err != nil {
+		p.log.Warn("Failed to transcribe voice message", zap.String("group", groupTopic), zap.Error(err))
+		return
+	}
+
+	p.remember(key, result{transcript: text})
+	c.Transcript = text
+	p.log.Info("Voice message transcribed",
+		zap.String("group", groupTopic),
+		zap.Int("length", len([]rune(text))),
+		zap.Duration("took", time.Since(start)))
+}
+
+func (p *Pipeline) caption(ctx context.Context, groupTopic string, c *chat.Content) {
+	st := p.stage.Load()
+	if st == nil || st.captioner == nil {
+		return
+	}
+	// Groups bound to a local provider keep images off cloud services
+	if !st.captionLocal && config.ForGroup(groupTopic).OnPremOnly() {
+		p.log.Debug("Skipping cloud captioning for on-prem group", zap.String("group", groupTopic))
+		return
+	}
+
+	key := hash(c.Data)
+	if r, ok := p.cached(key); ok {
+		c.Caption, c.ImageText = r.caption.Description, r.caption.Text
+		return
+	}
+
+	start := time.Now()
+	caption, err := st.captioner.Caption(ctx, c)
+	if errors.Is(err, llm.ErrUnsupportedImage) {
+		p.log.Info("Image not captioned", zap.String("group", groupTopic), zap.Error(err))
+		return
+	}
+	if err != nil {
+		p.log.Warn("Failed to caption image", zap.String("group", groupTopic), zap.Error(err))
+		return
+	}
+	if caption.Description == "" {
+		return
+	}
+
+	p.remember(key, result{caption: caption})
+	c.Caption, c.ImageText = caption.Description, caption.Text
+	p.log.Info("Image captioned",
+		zap.String("group", groupTopic),
+		zap.Int("size", len(c.Data)),
+		zap.Int("textLength", len([]rune(caption.Text))),
+		zap.Duration("took", time.Since(start)))
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (p *Pipeline) cached(key string) (result, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, ok := p.cache[key]
+	return r, ok
+}
+
+func (p *Pipeline) remember(key string, r result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.cache[key]; ok {
+		return
+	}
+	if len(p.order) >= cacheSize {
+		delete(p.cache, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.cache[key] = r
+	p.order = append(p.order, key)
+}
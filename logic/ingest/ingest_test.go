@@ -0,0 +1,162 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/entity/llm"
+	"github.com/soaringk/msg-asst/pkg/logging"
+)
+
+type fakeTranscriber struct {
+	calls int
+	err   error
+}
+
+func (f *fakeTranscriber) Transcribe(ctx context.Context, audio *chat.Content) (string, error) {
+	f.calls++
+	return fmt.Sprintf("转写 %d", f.calls), f.err
+}
+
+type fakeCaptioner struct {
+	calls int
+}
+
+func (f *fakeCaptioner) Caption(ctx context.Context, image *chat.Content) (llm.Caption, error) {
+	f.calls++
+	return llm.Caption{Description: "登录页报错截图", Text: "用户名或密码错误"}, nil
+}
+
+func newPipeline(t *testing.T, tr llm.Transcriber) *Pipeline {
+	t.Helper()
+	os.Setenv("LLM_API_KEY", "test-key")
+	t.Cleanup(func() { os.Unsetenv("LLM_API_KEY") })
+	if err := config.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Pipeline{cache: make(map[string]result), log: logging.Named("ingest")}
+	p.stage.Store(&stage{transcriber: tr})
+	p.start()
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestTranscribeOnArrival(t *testing.T) {
+	tr := &fakeTranscriber{}
+	p := newPipeline(t, tr)
+
+	voice := &chat.Content{Type: chat.ContentTypeAudio, Data: []byte("voice"), MimeType: "audio/wav"}
+	p.Process(context.Background(), "研发群", voice)
+	if voice.Transcript != "转写 1" {
+		t.Errorf("Transcript = %q", voice.Transcript)
+	}
+
+	// The same audio forwarded elsewhere comes from the cache
+	forwarded := &chat.Content{Type: chat.ContentTypeAudio, Data: []byte("voice"), MimeType: "audio/wav"}
+	p.Process(context.Background(), "产品群", forwarded)
+	if forwarded.Transcript != "转写 1" || tr.calls != 1 {
+		t.Errorf("Transcript = %q after %d calls", forwarded.Transcript, tr.calls)
+	}
+
+	image := &chat.Content{Type: chat.ContentTypeImage, Data: []byte("png"), MimeType: "image/png"}
+	p.Process(context.Background(), "研发群", image)
+	if tr.calls != 1 {
+		t.Error("only voice messages should be transcribed")
+	}
+}
+
+func TestTranscribeFailure(t *testing.T) {
+	tr := &fakeTranscriber{err: errors.New("unavailable")}
+	p := newPipeline(t, tr)
+
+	voice := &chat.Content{Type: chat.ContentTypeAudio, Data: []byte("voice"), MimeType: "audio/wav"}
+	p.Process(context.Background(), "研发群", voice)
+	if voice.Transcript != "" || voice.Data == nil {
+		t.Errorf("a failed transcription should leave the audio as it is: %+v", voice)
+	}
+	if len(p.cache) != 0 {
+		t.Error("failures must not be cached")
+	}
+}
+
+func TestCaptionOnArrival(t *testing.T) {
+	c := &fakeCaptioner{}
+	p := newPipeline(t, nil)
+	p.stage.Store(&stage{captioner: c})
+
+	image := &chat.Content{Type: chat.ContentTypeImage, Data: []byte("png"), MimeType: "image/png"}
+	p.Process(context.Background(), "研发群", image)
+	if image.Caption != "登录页报错截图" || image.ImageText != "用户名或密码错误" {
+		t.Errorf("caption = %q, %q", image.Caption, image.ImageText)
+	}
+
+	forwarded := &chat.Content{Type: chat.ContentTypeImage, Data: []byte("png"), MimeType: "image/png"}
+	p.Process(context.Background(), "产品群", forwarded)
+	if forwarded.Caption != image.Caption || c.calls != 1 {
+		t.Errorf("caption = %q after %d calls", forwarded.Caption, c.calls)
+	}
+}
+
+// blockingTranscriber holds each request until its context ends or release
+// is closed.
+type blockingTranscriber struct {
+	release chan struct{}
+}
+
+func (b *blockingTranscriber) Transcribe(ctx context.Context, audio *chat.Content) (string, error) {
+	select {
+	case <-b.release:
+		return "周三联调", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	tr := &blockingTranscriber{release: make(chan struct{})}
+	p := newPipeline(t, tr)
+
+	results := make(chan *chat.Content, 1)
+	done := func(c *chat.Content) { results <- c }
+
+	// Text has nothing to wait for
+	text := &chat.Content{Type: chat.ContentTypeText, Text: "hello"}
+	p.Submit(context.Background(), "研发群", text, done)
+	if got := <-results; got != text {
+		t.Errorf("Submit() of text = %+v", got)
+	}
+
+	voice := &chat.Content{Type: chat.ContentTypeAudio, Data: []byte("voice"), MimeType: "audio/wav"}
+	p.Submit(context.Background(), "研发群", voice, done)
+	select {
+	case <-results:
+		t.Fatal("Submit() waited for the transcriber")
+	default:
+	}
+	close(tr.release)
+	got := <-results
+	if got.Transcript != "周三联调" || voice.Transcript != "" {
+		t.Errorf("Submit() = %q, original = %q", got.Transcript, voice.Transcript)
+	}
+
+	// Cancelled work still reports the media back
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	other := &chat.Content{Type: chat.ContentTypeAudio, Data: []byte("other"), MimeType: "audio/wav"}
+	p.Submit(ctx, "研发群", other, done)
+	if got := <-results; got.Transcript != "" || got.Data == nil {
+		t.Errorf("Submit() with a cancelled context = %+v", got)
+	}
+
+	p.Close()
+	p.Submit(context.Background(), "研发群", other, done)
+	if got := <-results; got != other {
+		t.Error("Submit() after Close should hand the content back")
+	}
+}
@@ -0,0 +1,207 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/pkg/cron"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const tickInterval = 30 * time.Second
+
+// groupState records when a group's schedule last ran. Expr is kept so a
+// changed schedule starts fresh instead of catching up under the new rule.
+type groupState struct {
+	Expr    string    `json:"expr"`
+	LastRun time.Time `json:"last_run"`
+}
+
+// Scheduler fires per-group summaries on cron schedules from
+// SUMMARY_SCHEDULES. Schedules are re-read on every tick, so .env changes
+// apply without a restart. The last run of each group is persisted; after a
+// restart, runs missed while the bot was down are collapsed into a single
+// catch-up run and a run that already fired is never repeated.
+type Scheduler struct {
+	stateFile string
+	groups    func() []string
+	fire      func(groupTopic string)
+
+	mu      sync.Mutex
+	state   map[string]groupState
+	parsed  map[string]*cron.Schedule
+	invalid map[string]bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	log      *zap.Logger
+}
+
+// New creates a scheduler that checks the groups returned by groups and calls
+// fire for each one that is due.
+func New(stateFile string, groups func() []string, fire func(groupTopic string)) *Scheduler {
+	s := &Scheduler{
+		stateFile: stateFile,
+		groups:    groups,
+		fire:      fire,
+		state:     make(map[string]groupState),
+		parsed:    make(map[string]*cron.Schedule),
+		invalid:   make(map[string]bool),
+		stop:      make(chan struct{}),
+		log:       logging.Named("scheduler"),
+	}
+
+	if err := s.load(); err != nil {
+		s.log.Warn("Failed to load schedule state, starting fresh", zap.Error(err))
+	}
+	return s
+}
+
+func (s *Scheduler) Start() {
+	s.log.Info("Starting summary scheduler", zap.Duration("tick", tickInterval))
+
+	ticker := time.NewTicker(tickInterval)
+	go func() {
+		defer ticker.Stop()
+		s.tick(time.Now())
+		for {
+			select {
+			case now := <-ticker.C:
+				s.tick(now)
+			case <-s.stop:
+				s.log.Info("Summary scheduler stopped")
+				return
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Next returns when a group's schedule fires next, or the zero time if it
+// has none.
+func (s *Scheduler) Next(groupTopic string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched := s.schedule(groupTopic)
+	if sched == nil {
+		return time.Time{}
+	}
+	return sched.Next(time.Now())
+}
+
+func (s *Scheduler) tick(now time.Time) {
+	var due []string
+
+	s.mu.Lock()
+	changed := false
+	for _, group := range s.groups() {
+		sched := s.schedule(group)
+		if sched == nil {
+			continue
+		}
+
+		st, ok := s.state[group]
+		if !ok || st.Expr != sched.String() {
+			// First sighting or a new rule: start counting from now
+			s.state[group] = groupState{Expr: sched.String(), LastRun: now}
+			changed = true
+			continue
+		}
+
+		next := sched.Next(st.LastRun)
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+
+		s.log.Info("Scheduled summary due",
+			zap.String("group", group),
+			zap.String("schedule", st.Expr),
+			zap.Time("scheduledFor", next))
+		s.state[group] = groupState{Expr: st.Expr, LastRun: now}
+		changed = true
+		due = append(due, group)
+	}
+
+	if changed {
+		if err := s.save(); err != nil {
+			s.log.Error("Failed to save schedule state", zap.Error(err))
+		}
+	}
+	s.mu.Unlock()
+
+	for _, group := range due {
+		s.fire(group)
+	}
+}
+
+// schedule returns the parsed schedule of a group. Must be called with mu held.
+func (s *Scheduler) schedule(groupTopic string) *cron.Schedule {
+	expr := config.ForGroup(groupTopic).SummaryTrigger.Schedule
+	if expr == "" {
+		return nil
+	}
+	if sched, ok := s.parsed[expr]; ok {
+		return sched
+	}
+	if s.invalid[expr] {
+		return nil
+	}
+
+	sched, err := cron.Parse(expr)
+	if err != nil {
+		s.invalid[expr] = true
+		s.log.Error("Invalid summary schedule, ignoring",
+			zap.String("group", groupTopic),
+			zap.String("schedule", expr),
+			zap.Error(err))
+		return nil
+	}
+	s.parsed[expr] = sched
+	return sched
+}
+
+func (s *Scheduler) load() error {
+	data, err := os.ReadFile(s.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	state := make(map[string]groupState)
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", s.stateFile, err)
+	}
+	s.state = state
+	s.log.Info("Loaded schedule state", zap.Int("groups", len(s.state)))
+	return nil
+}
+
+// save writes the state atomically. Must be called with mu held.
+func (s *Scheduler) save() error {
+	if s.stateFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule state: %w", err)
+	}
+
+	tmp := s.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schedule state: %w", err)
+	}
+	return os.Rename(tmp, s.stateFile)
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/config"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("LLM_API_KEY", "test-key")
+	os.Setenv("SUMMARY_SCHEDULES", "研发=0 18 * * 1-5;*=0 9-19 * * *")
+	_ = config.Parse()
+	os.Exit(m.Run())
+}
+
+func TestTickFiresOncePerRun(t *testing.T) {
+	var fired []string
+	s := New(filepath.Join(t.TempDir(), "state.json"),
+		func() []string { return []string{"后端研发群"} },
+		func(group string) { fired = append(fired, group) })
+
+	// 2025-03-10 is a Monday
+	day := func(hour, minute int) time.Time {
+		return time.Date(2025, 3, 10, hour, minute, 0, 0, time.Local)
+	}
+
+	s.tick(day(17, 0)) // first sighting only records a baseline
+	s.tick(day(17, 59))
+	if len(fired) != 0 {
+		t.Fatalf("Fired before schedule: %v", fired)
+	}
+
+	s.tick(day(18, 0))
+	s.tick(day(18, 0).Add(30 * time.Second))
+	if len(fired) != 1 {
+		t.Fatalf("Expected exactly 1 run at 18:00, got %d", len(fired))
+	}
+}
+
+func TestStateSurvivesRestart(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	groups := func() []string { return []string{"产品群"} }
+	hour := func(h int) time.Time {
+		return time.Date(2025, 3, 10, h, 0, 0, 0, time.Local)
+	}
+
+	var fired int
+	s := New(stateFile, groups, func(string) { fired++ })
+	s.tick(hour(9).Add(-time.Minute))
+	s.tick(hour(9))
+	if fired != 1 {
+		t.Fatalf("Expected 1 run before restart, got %d", fired)
+	}
+
+	// Restarting within the same hour must not fire the 09:00 run again
+	fired = 0
+	restarted := New(stateFile, groups, func(string) { fired++ })
+	restarted.tick(hour(9).Add(20 * time.Second))
+	if fired != 0 {
+		t.Fatalf("Run repeated after restart")
+	}
+
+	// Being down from 09:30 to 12:10 misses three runs; catch up only once
+	restarted.tick(hour(12).Add(10 * time.Minute))
+	restarted.tick(hour(12).Add(11 * time.Minute))
+	if fired != 1 {
+		t.Fatalf("Expected a single catch-up run, got %d", fired)
+	}
+}
+
+func TestInvalidScheduleIgnored(t *testing.T) {
+	os.Setenv("SUMMARY_SCHEDULES", "坏=not a cron")
+	defer func() {
+		os.Setenv("SUMMARY_SCHEDULES", "研发=0 18 * * 1-5;*=0 9-19 * * *")
+		_ = config.Parse()
+	}()
+	_ = config.Parse()
+
+	var fired int
+	s := New("", func() []string { return []string{"坏群"} }, func(string) { fired++ })
+	s.tick(time.Now())
+	s.tick(time.Now().Add(24 * time.Hour))
+	if fired != 0 || !s.Next("坏群").IsZero() {
+		t.Error("Invalid schedule should never fire")
+	}
+}
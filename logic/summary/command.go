@@ -0,0 +1,122 @@
+package summary
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Command holds the arguments given after the summary keyword, e.g.
+// "@bot 总结 2h @张三 详细". The zero Command summarizes the whole buffer.
+type Command struct {
+	Window   chat.Window
+	Detailed bool
+	Template string // prompt template in PROMPT_DIR, e.g. "模板:周报"
+	// Duration is the requested time span, kept to describe the window.
+	Duration time.Duration
+}
+
+var (
+	durationArg = regexp.MustCompile(`^(?:最近|last)?(\d+)\s*(m|min|mins|分钟|分|h|hr|hrs|小时|个小时|d|天)$`)
+	countArg    = regexp.MustCompile(`^(?:最近|last)?(\d+)(?:条|msgs?|messages?)$`)
+	templateArg = regexp.MustCompile(`^(?:模板|(?i:template|tpl))[:：=](.+)$`)
+)
+
+var detailArgs = map[string]bool{
+	"详细":       true,
+	"详细版":      true,
+	"detail":   true,
+	"detailed": true,
+}
+
+// ParseCommand reports whether text contains keyword and parses the
+// arguments that follow it.
+func ParseCommand(text, keyword string, now time.Time) (Command, bool) {
+	if keyword == "" {
+		return Command{}, false
+	}
+	idx := strings.Index(text, keyword)
+	if idx < 0 {
+		return Command{}, false
+	}
+	return ParseArgs(text[idx+len(keyword):], now), true
+}
+
+// ParseArgs parses summary arguments such as "2h @张三 详细". Unknown
+// arguments, including a number without a unit, are ignored so a typo still
+// produces a summary; "30" could mean minutes as well as messages.
+func ParseArgs(args string, now time.Time) Command {
+	var cmd Command
+	for _, arg := range strings.Fields(args) {
+		lower := strings.ToLower(arg)
+		switch {
+		case detailArgs[lower]:
+			cmd.Detailed = true
+		case templateArg.MatchString(arg):
+			cmd.Template = templateArg.FindStringSubmatch(arg)[1]
+		case strings.HasPrefix(arg, "@") && len(arg) > 1:
+			cmd.Window.Sender = arg[1:]
+		case durationArg.MatchString(lower):
+			m := durationArg.FindStringSubmatch(lower)
+			n, _ := strconv.Atoi(m[1])
+			cmd.Duration = time.Duration(n) * durationUnit(m[2])
+			cmd.Window.Since = now.Add(-cmd.Duration)
+		case countArg.MatchString(lower):
+			n, _ := strconv.Atoi(countArg.FindStringSubmatch(lower)[1])
+			cmd.Window.Last = n
+		default:
+			logging.Debug("Ignoring unknown summary argument", zap.String("arg", arg))
+		}
+	}
+	return cmd
+}
+
+func durationUnit(unit string) time.Duration {
+	switch unit {
+	case "h", "hr", "hrs", "小时", "个小时":
+		return time.Hour
+	case "d", "天":
+		return 24 * time.Hour
+	default:
+		return time.Minute
+	}
+}
+
+// Describe returns a short Chinese description of the command's scope for the
+// summary header, or "" for a full summary.
+func (c Command) Describe() string {
+	var parts []string
+	if c.Duration > 0 {
+		parts = append(parts, "最近"+formatDuration(c.Duration))
+	}
+	if c.Window.Last > 0 {
+		parts = append(parts, fmt.Sprintf("最近%d条", c.Window.Last))
+	}
+	if c.Window.Sender != "" {
+		parts = append(parts, "@"+c.Window.Sender)
+	}
+	if c.Detailed {
+		parts = append(parts, "详细")
+	}
+	if c.Template != "" {
+		parts = append(parts, "模板 "+c.Template)
+	}
+	return strings.Join(parts, " · ")
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d天", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d小时", d/time.Hour)
+	default:
+		return fmt.Sprintf("%d分钟", d/time.Minute)
+	}
+}
@@ -0,0 +1,70 @@
+package summary
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCommand(t *testing.T) {
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.Local)
+	keyword := "@bot 总结"
+
+	tests := []struct {
+		name     string
+		text     string
+		ok       bool
+		since    time.Time
+		last     int
+		sender   string
+		detailed bool
+		template string
+		scope    string
+	}{
+		{name: "no keyword", text: "大家好"},
+		{name: "plain", text: "@bot 总结", ok: true},
+		{name: "hours", text: "@bot 总结 2h", ok: true, since: now.Add(-2 * time.Hour), scope: "最近2小时"},
+		{name: "chinese minutes", text: "@bot 总结 30分钟", ok: true, since: now.Add(-30 * time.Minute), scope: "最近30分钟"},
+		{name: "recent hours", text: "@bot 总结 最近3小时", ok: true, since: now.Add(-3 * time.Hour), scope: "最近3小时"},
+		{name: "count", text: "@bot 总结 最近100条", ok: true, last: 100, scope: "最近100条"},
+		{name: "sender", text: "@bot 总结 @张三", ok: true, sender: "张三", scope: "@张三"},
+		{name: "mention separator", text: "@bot 总结 @张三\u2005", ok: true, sender: "张三", scope: "@张三"},
+		{name: "detailed", text: "@bot 总结 详细", ok: true, detailed: true, scope: "详细"},
+		{
+			name: "combined", text: "请 @bot 总结 1d @李四 50条 详细", ok: true,
+			since: now.Add(-24 * time.Hour), last: 50, sender: "李四", detailed: true,
+			scope: "最近1天 · 最近50条 · @李四 · 详细",
+		},
+		{name: "template", text: "@bot 总结 模板：周报", ok: true, template: "周报", scope: "模板 周报"},
+		{name: "english template", text: "@bot 总结 Template=weekly", ok: true, template: "weekly", scope: "模板 weekly"},
+		{name: "english count", text: "@bot 总结 20msgs", ok: true, last: 20, scope: "最近20条"},
+		{name: "bare number ignored", text: "@bot 总结 30", ok: true},
+		{name: "unknown ignored", text: "@bot 总结 一下", ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok := ParseCommand(tt.text, keyword, now)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !cmd.Window.Since.Equal(tt.since) {
+				t.Errorf("Since = %v, want %v", cmd.Window.Since, tt.since)
+			}
+			if cmd.Window.Last != tt.last {
+				t.Errorf("Last = %d, want %d", cmd.Window.Last, tt.last)
+			}
+			if cmd.Window.Sender != tt.sender {
+				t.Errorf("Sender = %q, want %q", cmd.Window.Sender, tt.sender)
+			}
+			if cmd.Detailed != tt.detailed {
+				t.Errorf("Detailed = %v, want %v", cmd.Detailed, tt.detailed)
+			}
+			if cmd.Template != tt.template {
+				t.Errorf("Template = %q, want %q", cmd.Template, tt.template)
+			}
+			if got := cmd.Describe(); got != tt.scope {
+				t.Errorf("Describe() = %q, want %q", got, tt.scope)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/config"
+	"github.com/soaringk/msg-asst/entity/llm"
+	"github.com/soaringk/msg-asst/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// DigestTopic labels digest results, which belong to no single group.
+const DigestTopic = "每日汇总"
+
+// GenerateDigest merges the group summaries produced on date into one digest,
+// ranked by importance with topics shared across groups deduplicated.
+// Summaries of groups bound to their own provider stay out of the digest,
+// which the primary provider writes.
+func (g *Generator) GenerateDigest(ctx context.Context, date time.Time, results []Result) (Result, error) {
+	results = slices.DeleteFunc(slices.Clone(results), func(r Result) bool {
+		return config.ForGroup(r.GroupTopic).LLMGroupProvider != ""
+	})
+	if len(results) == 0 {
+		return Result{SkipReason: "no_summaries"}, nil
+	}
+
+	sorted := results
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].LastMsgTime.Before(sorted[j].LastMsgTime)
+	})
+
+	digest := Result{GroupTopic: DigestTopic}
+	participants := make(map[string]struct{})
+	groups := make(map[string]struct{})
+	items := make([]llm.DigestItem, 0, len(sorted))
+	for _, r := range sorted {
+		text := r.Body
+		if text == "" {
+			text = r.Text
+		}
+		items = append(items, llm.DigestItem{
+			GroupTopic: r.GroupTopic,
+			TimeRange:  formatRange(r.FirstMsgTime, r.LastMsgTime),
+			Text:       text,
+		})
+
+		groups[r.GroupTopic] = struct{}{}
+		digest.MessageCount += r.MessageCount
+		for _, name := range r.Participants {
+			participants[name] = struct{}{}
+		}
+		if !r.FirstMsgTime.IsZero() && (digest.FirstMsgTime.IsZero() || r.FirstMsgTime.Before(digest.FirstMsgTime)) {
+			digest.FirstMsgTime = r.FirstMsgTime
+		}
+		if r.LastMsgTime.After(digest.LastMsgTime) {
+			digest.LastMsgTime = r.LastMsgTime
+		}
+	}
+
+	logging.Debug("Generating digest",
+		zap.Int("summaries", len(items)),
+		zap.Int("groups", len(groups)))
+
+	summary, err := g.llmService.GenerateDigest(ctx, llm.DigestRequest{
+		Date:  date.Format("2006年1月2日 Monday"),
+		Items: items,
+	})
+	g.RecordUsage(DigestTopic, summary.Usage)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to generate digest: %w", err)
+	}
+
+	trimmed := strings.TrimSpace(summary.Text)
+	if trimmed == "" {
+		return Result{SkipReason: "empty_digest"}, nil
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	header := fmt.Sprintf("# 📰 %s %s\n📋 来源：%d 个群，%d 份纪要\n👥 群聊：%s\n",
+		DigestTopic, date.Format("2006年1月2日 Monday"), len(groups), len(items), strings.Join(names, "、"))
+
+	digest.Text = fmt.Sprintf("%s\n\n%s", header, trimmed)
+	digest.Body = trimmed
+	digest.Participants = make([]string, 0, len(participants))
+	for name := range participants {
+		digest.Participants = append(digest.Participants, name)
+	}
+	sort.Strings(digest.Participants)
+	digest.Model = summary.Model
+	digest.PromptHash = summary.PromptHash
+	return digest, nil
+}
+
+func formatRange(first, last time.Time) string {
+	if first.IsZero() || last.IsZero() {
+		return "N/A"
+	}
+	return fmt.Sprintf("%s - %s", first.Format("15:04"), last.Format("15:04"))
+}
@@ -3,22 +3,52 @@ package summary
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/soaringk/msg-asst/entity/chat"
+	"github.com/soaringk/msg-asst/entity/config"
 	"github.com/soaringk/msg-asst/entity/llm"
+	"github.com/soaringk/msg-asst/entity/usage"
 	"github.com/soaringk/msg-asst/pkg/logging"
 	"go.uber.org/zap"
 )
 
 type Generator struct {
 	llmService *llm.Service
+	previous   sync.Map // group -> previousSummary
+	history    History
+	usage      *usage.Ledger // nil when usage is not recorded
+	owner      string
+}
+
+// SkipBudgetExceeded is the skip reason of a group paused for spending its
+// daily budget. Its messages stay buffered.
+const SkipBudgetExceeded = "budget_exceeded"
+
+// History looks up the last delivered summary of a group, so rolling
+// summaries survive a restart.
+type History func(groupTopic string) (text string, at time.Time, ok bool)
+
+type previousSummary struct {
+	text string
+	at   time.Time
 }
 
 type Result struct {
-	Text       string
-	SkipReason string
+	Text         string
+	Body         string       // Text without the header
+	Minutes      *llm.Minutes // structured form of Body, if the provider returned one
+	SkipReason   string
+	GroupTopic   string
+	MessageCount int
+	FirstMsgTime time.Time
+	LastMsgTime  time.Time
+	Participants []string
+	Model        string
+	PromptHash   string
 }
 
 func New() *Generator {
@@ -27,8 +57,65 @@ func New() *Generator {
 	}
 }
 
-func (g *Generator) Generate(ctx context.Context, buf *chat.MessageBuffer, groupTopic string) (Result, error) {
-	snapshot := buf.GetSnapshot(groupTopic)
+// SetHistory sets where the previous summary of a group is looked up when
+// none has been remembered since startup.
+func (g *Generator) SetHistory(history History) {
+	g.history = history
+}
+
+// SetUsage sets the ledger LLM usage is recorded in and daily budgets are
+// checked against.
+func (g *Generator) SetUsage(ledger *usage.Ledger) {
+	g.usage = ledger
+}
+
+// SetOwner sets the nickname of the logged-in account, available to prompt
+// templates as {{.Owner}}.
+func (g *Generator) SetOwner(name string) {
+	g.owner = name
+}
+
+// Remember records a delivered summary as the context for the group's next
+// rolling summary.
+func (g *Generator) Remember(result Result) {
+	if result.Body == "" {
+		return
+	}
+	g.previous.Store(result.GroupTopic, previousSummary{text: result.Body, at: time.Now()})
+}
+
+// previousSummary returns the summary to carry forward for a group, or "" if
+// rolling summaries are disabled or the last one is too old to be relevant.
+func (g *Generator) previousSummary(groupTopic string) string {
+	cfg := config.ForGroup(groupTopic).SummaryTrigger
+	if !cfg.RollingEnabled {
+		return ""
+	}
+
+	var prev previousSummary
+	if v, ok := g.previous.Load(groupTopic); ok {
+		prev = v.(previousSummary)
+	} else if g.history != nil {
+		text, at, ok := g.history(groupTopic)
+		if !ok {
+			return ""
+		}
+		prev = previousSummary{text: text, at: at}
+		g.previous.Store(groupTopic, prev)
+	}
+
+	if prev.text == "" {
+		return ""
+	}
+	if cfg.RollingMaxAgeHours > 0 && time.Since(prev.at) > time.Duration(cfg.RollingMaxAgeHours)*time.Hour {
+		return ""
+	}
+	return prev.text
+}
+
+// Generate summarizes the messages of a group selected by cmd.
+func (g *Generator) Generate(ctx context.Context, buf *chat.MessageBuffer, groupTopic string, cmd Command) (Result, error) {
+	snapshot := buf.GetWindowSnapshot(groupTopic, cmd.Window)
 
 	if snapshot.Count == 0 || len(snapshot.Contents) == 0 {
 		return Result{SkipReason: "empty_buffer"}, nil
@@ -38,31 +125,160 @@ func (g *Generator) Generate(ctx context.Context, buf *chat.MessageBuffer, group
 		zap.Int("count", snapshot.Count),
 		zap.String("group", groupTopic))
 
+	contents := snapshot.Contents
+	if action := g.budgetAction(groupTopic); action == config.BudgetPause {
+		return Result{SkipReason: SkipBudgetExceeded}, nil
+	} else if action == config.BudgetTextOnly {
+		contents = llm.TextOnly(contents)
+	}
+
 	timeRange := g.buildTimeRange(snapshot)
 
-	summary, err := g.llmService.GenerateSummary(ctx, groupTopic, timeRange, snapshot.Count, snapshot.Contents)
+	// A windowed summary is a one-off view and stands on its own
+	var previous string
+	if cmd.Window.IsZero() {
+		previous = g.previousSummary(groupTopic)
+	}
+
+	summary, err := g.llmService.GenerateSummary(ctx, llm.SummaryRequest{
+		GroupTopic:   groupTopic,
+		TimeRange:    timeRange,
+		MessageCount: snapshot.Count,
+		Messages:     contents,
+		Participants: participants(snapshot),
+		Owner:        g.owner,
+		Template:     cmd.Template,
+		Detailed:     cmd.Detailed,
+		Previous:     previous,
+	})
+	// Failed summaries are billed too, e.g. for the chunks condensed first
+	g.RecordUsage(groupTopic, summary.Usage)
 	if err != nil {
 		return Result{}, fmt.Errorf("failed to generate summary: %w", err)
 	}
 
-	trimmed := strings.TrimSpace(summary)
-	if trimmed == "" || trimmed == "暂无重要更新" {
+	trimmed := strings.TrimSpace(summary.Text)
+	if summary.Minutes != nil {
+		if !summary.Minutes.HasUpdate || summary.Minutes.Empty() {
+			return Result{SkipReason: "no_important_update"}, nil
+		}
+		trimmed = renderMinutes(summary.Minutes)
+	} else if trimmed == "" || trimmed == "暂无重要更新" {
 		return Result{SkipReason: "no_important_update"}, nil
 	}
 
-	header := g.generateHeader(snapshot, groupTopic)
-	return Result{Text: fmt.Sprintf("%s\n\n%s", header, trimmed)}, nil
+	header := g.generateHeader(snapshot, groupTopic, cmd)
+	result := Result{
+		Text:         fmt.Sprintf("%s\n\n%s", header, trimmed),
+		Body:         trimmed,
+		Minutes:      summary.Minutes,
+		GroupTopic:   groupTopic,
+		MessageCount: snapshot.Count,
+		Participants: participants(snapshot),
+		Model:        summary.Model,
+		PromptHash:   summary.PromptHash,
+	}
+	if snapshot.FirstMsgTime != nil && snapshot.LastMsgTime != nil {
+		result.FirstMsgTime = *snapshot.FirstMsgTime
+		result.LastMsgTime = *snapshot.LastMsgTime
+	}
+	return result, nil
+}
+
+// budgetAction returns the action to take for a group that has spent its
+// daily budget, or "" while it is within budget.
+func (g *Generator) budgetAction(groupTopic string) string {
+	cfg := config.ForGroup(groupTopic).Usage
+	if g.usage == nil || cfg.DailyBudget <= 0 {
+		return ""
+	}
+	spent := g.usage.Spent(time.Now(), groupTopic)
+	if spent < cfg.DailyBudget {
+		return ""
+	}
+	logging.Warn("Daily budget exceeded",
+		zap.String("group", groupTopic),
+		zap.Float64("spent", spent),
+		zap.Float64("budget", cfg.DailyBudget),
+		zap.String("action", cfg.BudgetAction))
+	return cfg.BudgetAction
+}
+
+// RecordUsage logs the LLM usage of requests made for a group and adds it to
+// the ledger.
+func (g *Generator) RecordUsage(groupTopic string, used []llm.Usage) {
+	if len(used) == 0 {
+		return
+	}
+	for _, u := range used {
+		logging.Info("LLM usage",
+			zap.String("group", groupTopic),
+			zap.String("model", u.Model),
+			zap.Int("requests", u.Requests),
+			zap.Int("promptTokens", u.PromptTokens),
+			zap.Int("completionTokens", u.CompletionTokens),
+			zap.Int("cachedTokens", u.CachedTokens),
+			zap.Int("mediaTokens", u.MediaTokens))
+	}
+	if g.usage == nil {
+		return
+	}
+
+	now := time.Now()
+	cfg := config.GetConfig().Usage
+	cost, err := g.usage.Record(now, groupTopic, used, &cfg)
+	if err != nil {
+		logging.Error("Failed to record LLM usage", zap.String("group", groupTopic), zap.Error(err))
+		return
+	}
+	logging.Info("LLM cost",
+		zap.String("group", groupTopic),
+		zap.Float64("cost", cost),
+		zap.Float64("spentToday", g.usage.Spent(now, groupTopic)))
+}
+
+func participants(snapshot chat.Snapshot) []string {
+	names := make([]string, 0, len(snapshot.Participants))
+	for name := range snapshot.Participants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReloadPrompts re-reads the system prompt files from disk.
+func (g *Generator) ReloadPrompts() (int, error) {
+	return g.llmService.ReloadPrompts()
 }
 
 func (g *Generator) Close() {
 	g.llmService.Close()
 }
 
-func (g *Generator) generateHeader(snapshot chat.Snapshot, groupTopic string) string {
+// headerPrefix starts the header of every summary Text.
+const headerPrefix = "# 🤖 "
+
+func (g *Generator) generateHeader(snapshot chat.Snapshot, groupTopic string, cmd Command) string {
 	now := time.Now()
 	dateStr := now.Format("2006年1月2日 Monday")
 	timeRange := g.buildTimeRange(snapshot)
-	return fmt.Sprintf("# 🤖 %s 会议纪要\n📅 日期：%s\n⏰ 时间：%s\n", groupTopic, dateStr, timeRange)
+	header := fmt.Sprintf("%s%s 会议纪要\n📅 日期：%s\n⏰ 时间：%s\n", headerPrefix, groupTopic, dateStr, timeRange)
+	if scope := cmd.Describe(); scope != "" {
+		header += fmt.Sprintf("🔍 范围：%s\n", scope)
+	}
+	return header
+}
+
+// StripHeader returns the Body of a summary Text, such as one read back from
+// the archive. Text without a header is returned unchanged.
+func StripHeader(text string) string {
+	if !strings.HasPrefix(text, headerPrefix) {
+		return text
+	}
+	if _, body, ok := strings.Cut(text, "\n\n"); ok {
+		return strings.TrimSpace(body)
+	}
+	return text
 }
 
 func (g *Generator) buildTimeRange(snapshot chat.Snapshot) string {
@@ -0,0 +1,26 @@
+package summary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soaringk/msg-asst/entity/chat"
+)
+
+func TestStripHeader(t *testing.T) {
+	first := time.Date(2025, 3, 5, 9, 0, 0, 0, time.Local)
+	last := first.Add(time.Hour)
+	snapshot := chat.Snapshot{FirstMsgTime: &first, LastMsgTime: &last}
+	body := "📌 关键事项\n- v2 周五发布\n\n👥 参与：张三"
+
+	g := &Generator{}
+	for _, cmd := range []Command{{}, {Detailed: true}} {
+		header := g.generateHeader(snapshot, "产品讨论群", cmd)
+		if got := StripHeader(header + "\n\n" + body); got != body {
+			t.Errorf("StripHeader() with header %q =\n%s\nwant\n%s", header, got, body)
+		}
+	}
+	if got := StripHeader(body); got != body {
+		t.Errorf("StripHeader() changed text without a header:\n%s", got)
+	}
+}
@@ -0,0 +1,49 @@
+package summary
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/soaringk/msg-asst/entity/llm"
+)
+
+// renderMinutes turns structured minutes into the text delivered to chat.
+// Empty sections are left out.
+func renderMinutes(m *llm.Minutes) string {
+	var sections []string
+	add := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		sections = append(sections, title+"\n- "+strings.Join(lines, "\n- "))
+	}
+
+	add("📌 关键事项", m.KeyPoints)
+	add("✅ 决定", m.Decisions)
+
+	items := make([]string, 0, len(m.ActionItems))
+	for _, item := range m.ActionItems {
+		items = append(items, formatActionItem(item))
+	}
+	add("📝 待办", items)
+	add("⚠️ 风险", m.Risks)
+
+	if len(m.Participants) > 0 {
+		sections = append(sections, "👥 参与："+strings.Join(m.Participants, "、"))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+func formatActionItem(item llm.ActionItem) string {
+	var meta []string
+	if item.Owner != "" {
+		meta = append(meta, "@"+item.Owner)
+	}
+	if item.Due != "" {
+		meta = append(meta, "截止："+item.Due)
+	}
+	if len(meta) == 0 {
+		return item.Task
+	}
+	return fmt.Sprintf("%s（%s）", item.Task, strings.Join(meta, "，"))
+}
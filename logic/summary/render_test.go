@@ -0,0 +1,26 @@
+package summary
+
+import (
+	"testing"
+
+	"github.com/soaringk/msg-asst/entity/llm"
+)
+
+func TestRenderMinutes(t *testing.T) {
+	got := renderMinutes(&llm.Minutes{
+		HasUpdate: true,
+		KeyPoints: []string{"【新增】v2 周五发布"},
+		ActionItems: []llm.ActionItem{
+			{Task: "写发布说明", Owner: "李四", Due: "周四"},
+			{Task: "通知客户"},
+		},
+		Participants: []string{"张三", "李四"},
+	})
+
+	want := "📌 关键事项\n- 【新增】v2 周五发布\n\n" +
+		"📝 待办\n- 写发布说明（@李四，截止：周四）\n- 通知客户\n\n" +
+		"👥 参与：张三、李四"
+	if got != want {
+		t.Errorf("renderMinutes() =\n%s\nwant\n%s", got, want)
+	}
+}
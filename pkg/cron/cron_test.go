@@ -0,0 +1,68 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"a * * * *",
+		"* * * foo *",
+	}
+
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) should fail", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2025-03-10 is a Monday
+	base := time.Date(2025, 3, 10, 10, 17, 42, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		expr     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"every minute", "* * * * *", base, time.Date(2025, 3, 10, 10, 18, 0, 0, time.Local)},
+		{"every 15 minutes", "*/15 * * * *", base, time.Date(2025, 3, 10, 10, 30, 0, 0, time.Local)},
+		{"hourly during office hours", "0 9-19 * * *", base, time.Date(2025, 3, 10, 11, 0, 0, 0, time.Local)},
+		{"after office hours rolls to next day", "0 9-19 * * *", time.Date(2025, 3, 10, 19, 30, 0, 0, time.Local), time.Date(2025, 3, 11, 9, 0, 0, 0, time.Local)},
+		{"weekday evening", "0 18 * * 1-5", base, time.Date(2025, 3, 10, 18, 0, 0, 0, time.Local)},
+		{"weekday names skip weekend", "0 18 * * MON-FRI", time.Date(2025, 3, 14, 18, 0, 0, 0, time.Local), time.Date(2025, 3, 17, 18, 0, 0, 0, time.Local)},
+		{"sunday as 7", "30 8 * * 7", base, time.Date(2025, 3, 16, 8, 30, 0, 0, time.Local)},
+		{"list", "0 9,12,18 * * *", base, time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)},
+		{"step from value", "5/20 * * * *", base, time.Date(2025, 3, 10, 10, 25, 0, 0, time.Local)},
+		{"strictly after", "17 10 * * *", time.Date(2025, 3, 10, 10, 17, 0, 0, time.Local), time.Date(2025, 3, 11, 10, 17, 0, 0, time.Local)},
+		{"month rollover", "0 0 1 * *", base, time.Date(2025, 4, 1, 0, 0, 0, 0, time.Local)},
+		{"dom or dow when both set", "0 12 15 * 5", base, time.Date(2025, 3, 14, 12, 0, 0, 0, time.Local)},
+		{"daily descriptor", "@daily", base, time.Date(2025, 3, 11, 0, 0, 0, 0, time.Local)},
+		{"leap day", "0 0 29 2 *", base, time.Date(2028, 2, 29, 0, 0, 0, 0, time.Local)},
+		{"impossible date", "0 0 30 2 *", base, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.expected) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.expected)
+			}
+		})
+	}
+}
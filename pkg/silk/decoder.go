@@ -0,0 +1,576 @@
+// Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package silk
+
+const (
+	maxFrameLength       = 480 // 20ms at 24 kHz
+	frameLengthMs        = 20
+	nbSubfr              = 4
+	ltpOrder             = 5
+	maxLPCOrder          = 16
+	minLPCOrder          = 10
+	maxFramesPerPacket   = 5
+	shellFrameLength     = 16
+	maxPulses            = 18
+	nRateLevels          = 10
+	minDeltaGainQuant    = -4
+	bweAfterLossQ16      = 63570
+	pitchEstMinLagMs     = 2
+	sigTypeVoiced        = 0
+	sigTypeUnvoiced      = 1
+	noVoiceActivity      = 0
+	frameTerminationMore = 1
+)
+
+// Gain quantization, uniform on a log scale between 6 and 86 dB.
+const (
+	gainOffset      = (6*128)/6 + 16*128
+	gainInvScaleQ16 = (65536 * (((86 - 6) * 128) / 6)) / 63
+)
+
+var (
+	lsbCDF                 = []uint16{0, 40000, 65535}
+	ltpScaleCDF            = []uint16{0, 32000, 48000, 65535}
+	vadFlagCDF             = []uint16{0, 22000, 65535}
+	samplingRatesCDF       = []uint16{0, 16000, 32000, 48000, 65535}
+	samplingRatesKHz       = []int{8, 12, 16, 24}
+	nlsfInterpolationCDF   = []uint16{0, 3706, 8703, 19226, 30926, 65535}
+	frameTerminationCDF    = []uint16{0, 20000, 45000, 56000, 65535}
+	seedCDF                = []uint16{0, 16384, 32768, 49152, 65535}
+	ltpGainCDFOffsets      = []int{1, 3, 10}
+	ltpScalesQ14           = []int32{15565, 11469, 8192}
+	quantizationOffsetsQ10 = [2][2]int32{{32, 100}, {100, 256}}
+)
+
+// High-pass filter coefficients in Q13 for each internal rate.
+var (
+	hpB = [3]int32{8000, -16000, 8000}
+	hpA = map[int][2]int32{
+		24: {-16220, 8030},
+		16: {-16127, 7940},
+		12: {-16043, 7859},
+		8:  {-15885, 7710},
+	}
+)
+
+// control holds the parameters decoded for one frame.
+type control struct {
+	pitchL           [nbSubfr]int
+	gainsQ16         [nbSubfr]int32
+	seed             int32
+	predCoefQ12      [2][maxLPCOrder]int16
+	ltpCoefQ14       [ltpOrder * nbSubfr]int16
+	ltpScaleQ14      int32
+	perIndex         int
+	rateLevelIndex   int
+	quantOffsetType  int
+	sigtype          int
+	nlsfInterpCoefQ2 int
+}
+
+// decoder is the state of the SILK SDK decoder between frames.
+type decoder struct {
+	rc rangeDecoder
+
+	prevInvGainQ16 int32
+	sLTPQ16        [2*maxFrameLength + ltpOrder/2]int32 // padded for the short lag after a reset
+	sLPCQ14        [maxFrameLength/nbSubfr + maxLPCOrder]int32
+	excQ10         [maxFrameLength]int32
+	outBuf         [2 * maxFrameLength]int16
+	lagPrev        int
+	lastGainIndex  int32
+	hpState        [2]int32
+	hpA            [2]int32
+
+	fsKHz       int
+	frameLength int
+	subfrLength int
+	lpcOrder    int
+	nlsfCB      [2]*nlsfCodebook
+	prevNLSFQ15 [maxLPCOrder]int32
+
+	firstFrameAfterReset bool
+	nBytesLeft           int
+	nFramesDecoded       int
+	moreFrames           bool
+	frameTermination     int
+	vadFlag              int
+	typeOffsetPrev       int
+	lossCnt              int
+	prevSigtype          int
+
+	plc       plcState
+	cng       cngState
+	resampler resampler
+}
+
+func newDecoder() *decoder {
+	d := &decoder{}
+	d.setFs(24)
+	d.firstFrameAfterReset = true
+	d.prevInvGainQ16 = 65536
+	d.cngReset()
+	d.plcReset()
+	return d
+}
+
+// setFs switches the internal sampling rate, resetting the state that
+// depends on it.
+func (d *decoder) setFs(fsKHz int) {
+	if d.fsKHz == fsKHz {
+		return
+	}
+	d.fsKHz = fsKHz
+	d.frameLength = frameLengthMs * fsKHz
+	d.subfrLength = frameLengthMs / nbSubfr * fsKHz
+	if fsKHz == 8 {
+		d.lpcOrder = minLPCOrder
+		d.nlsfCB = [2]*nlsfCodebook{nlsfCB0_10, nlsfCB1_10}
+	} else {
+		d.lpcOrder = maxLPCOrder
+		d.nlsfCB = [2]*nlsfCodebook{nlsfCB0_16, nlsfCB1_16}
+	}
+	clear(d.sLPCQ14[:maxLPCOrder])
+	clear(d.outBuf[:maxFrameLength])
+	clear(d.prevNLSFQ15[:])
+	d.lagPrev = 100
+	d.lastGainIndex = 1
+	d.prevSigtype = 0
+	d.firstFrameAfterReset = true
+	d.hpA = hpA[fsKHz]
+}
+
+// decode decodes the next frame of a packet, or conceals a lost packet, and
+// appends it to out at the output rate. Packets hold up to five frames;
+// d.moreFrames reports whether another one follows.
+func (d *decoder) decode(out []int16, payload []byte, lost bool) ([]int16, error) {
+	if !d.moreFrames {
+		d.nFramesDecoded = 0
+	}
+	var err error
+	if !d.moreFrames && !lost && len(payload) > maxArithmBytes {
+		lost = true
+		err = errPayloadTooLong
+	}
+
+	prevFsKHz := d.fsKHz
+	var frame [maxFrameLength]int16
+	n, usedBytes, frameErr := d.decodeFrame(frame[:], payload, lost)
+	if err == nil {
+		err = frameErr
+	}
+
+	if usedBytes > 0 {
+		d.moreFrames = d.nBytesLeft > 0 && d.frameTermination == frameTerminationMore &&
+			d.nFramesDecoded < maxFramesPerPacket
+	}
+
+	if d.fsKHz*1000 == SampleRate {
+		return append(out, frame[:n]...), err
+	}
+	if prevFsKHz != d.fsKHz || !d.resampler.ready {
+		d.resampler.init(int32(d.fsKHz*1000), SampleRate)
+	}
+	start := len(out)
+	out = d.resampler.resample(out, frame[:n])
+	return out[:start+n*SampleRate/(d.fsKHz*1000)], err
+}
+
+// decodeFrame decodes one frame into out and returns its length and the
+// payload bytes used. A frame that fails to decode is concealed.
+func (d *decoder) decodeFrame(out []int16, payload []byte, lost bool) (n, decBytes int, err error) {
+	var ctrl control
+	l := d.frameLength
+
+	if !lost {
+		fsKHzOld := d.fsKHz
+		if d.nFramesDecoded == 0 {
+			d.rc.init(payload)
+		}
+
+		var pulses [maxFrameLength]int32
+		d.decodeParameters(&ctrl, pulses[:])
+
+		if d.rc.err != nil {
+			d.nBytesLeft = 0
+			lost = true
+			d.setFs(fsKHzOld)
+			decBytes = d.rc.bufferLength
+			err = d.rc.err
+		} else {
+			decBytes = d.rc.bufferLength - d.nBytesLeft
+			d.nFramesDecoded++
+			l = d.frameLength
+
+			d.decodeCore(&ctrl, out[:l], pulses[:])
+			d.plcFrame(&ctrl, out[:l], false)
+			d.lossCnt = 0
+			d.prevSigtype = ctrl.sigtype
+			d.firstFrameAfterReset = false
+		}
+	}
+	if lost {
+		d.plcFrame(&ctrl, out[:l], true)
+	}
+
+	copy(d.outBuf[:l], out[:l])
+	d.plcGlueFrames(out[:l])
+	d.applyCNG(&ctrl, out[:l])
+	biquad(out[:l], &hpB, &d.hpA, &d.hpState)
+
+	d.lagPrev = ctrl.pitchL[nbSubfr-1]
+	return l, decBytes, err
+}
+
+// decodeParameters reads the frame parameters and the pulse signal q.
+func (d *decoder) decodeParameters(ctrl *control, q []int32) {
+	rc := &d.rc
+
+	if d.nFramesDecoded == 0 {
+		ix := rc.decode(samplingRatesCDF, 2)
+		d.setFs(samplingRatesKHz[ix])
+	}
+
+	var ix int
+	if d.nFramesDecoded == 0 {
+		ix = rc.decode(typeOffsetCDF, 2)
+	} else {
+		ix = rc.decode(typeOffsetJointCDF[d.typeOffsetPrev], 2)
+	}
+	ctrl.sigtype = ix >> 1
+	ctrl.quantOffsetType = ix & 1
+	d.typeOffsetPrev = ix
+
+	var gainIndices [nbSubfr]int
+	if d.nFramesDecoded == 0 {
+		gainIndices[0] = rc.decode(gainCDF[ctrl.sigtype], 32)
+	} else {
+		gainIndices[0] = rc.decode(deltaGainCDF, 5)
+	}
+	for i := 1; i < nbSubfr; i++ {
+		gainIndices[i] = rc.decode(deltaGainCDF, 5)
+	}
+	d.gainsDequant(ctrl, gainIndices, d.nFramesDecoded != 0)
+
+	nlsf := d.nlsfCB[ctrl.sigtype].decodeNLSF(rc, d.lpcOrder)
+
+	ctrl.nlsfInterpCoefQ2 = rc.decode(nlsfInterpolationCDF, 4)
+	if d.firstFrameAfterReset {
+		ctrl.nlsfInterpCoefQ2 = 4
+	}
+
+	nlsf2AStable(ctrl.predCoefQ12[1][:], nlsf)
+	if ctrl.nlsfInterpCoefQ2 < 4 {
+		nlsf0 := make([]int32, d.lpcOrder)
+		for i := range nlsf0 {
+			nlsf0[i] = d.prevNLSFQ15[i] + (int32(ctrl.nlsfInterpCoefQ2)*(nlsf[i]-d.prevNLSFQ15[i]))>>2
+		}
+		nlsf2AStable(ctrl.predCoefQ12[0][:], nlsf0)
+	} else {
+		ctrl.predCoefQ12[0] = ctrl.predCoefQ12[1]
+	}
+	copy(d.prevNLSFQ15[:], nlsf)
+
+	if d.lossCnt != 0 {
+		bwexpander(ctrl.predCoefQ12[0][:d.lpcOrder], bweAfterLossQ16)
+		bwexpander(ctrl.predCoefQ12[1][:d.lpcOrder], bweAfterLossQ16)
+	}
+
+	if ctrl.sigtype == sigTypeVoiced {
+		var lagIndex, contourIndex int
+		switch d.fsKHz {
+		case 8:
+			lagIndex = rc.decode(pitchLagNBCDF, 43)
+		case 12:
+			lagIndex = rc.decode(pitchLagMBCDF, 64)
+		case 16:
+			lagIndex = rc.decode(pitchLagWBCDF, 86)
+		default:
+			lagIndex = rc.decode(pitchLagSWBCDF, 128)
+		}
+		if d.fsKHz == 8 {
+			contourIndex = rc.decode(pitchContourNBCDF, 5)
+		} else {
+			contourIndex = rc.decode(pitchContourCDF, 17)
+		}
+		decodePitch(lagIndex, contourIndex, ctrl.pitchL[:], d.fsKHz)
+
+		ctrl.perIndex = rc.decode(ltpPerIndexCDF, 1)
+		cb := ltpGainVQ[ctrl.perIndex]
+		for k := 0; k < nbSubfr; k++ {
+			ix := rc.decode(ltpGainCDF[ctrl.perIndex], ltpGainCDFOffsets[ctrl.perIndex])
+			copy(ctrl.ltpCoefQ14[k*ltpOrder:(k+1)*ltpOrder], cb[ix*ltpOrder:(ix+1)*ltpOrder])
+		}
+
+		ctrl.ltpScaleQ14 = ltpScalesQ14[rc.decode(ltpScaleCDF, 2)]
+	} else {
+		ctrl.pitchL = [nbSubfr]int{}
+		ctrl.ltpCoefQ14 = [ltpOrder * nbSubfr]int16{}
+		ctrl.perIndex = 0
+		ctrl.ltpScaleQ14 = 0
+	}
+
+	ctrl.seed = int32(rc.decode(seedCDF, 2))
+	d.decodePulses(ctrl, q[:d.frameLength])
+
+	d.vadFlag = rc.decode(vadFlagCDF, 1)
+	d.frameTermination = rc.decode(frameTerminationCDF, 2)
+
+	_, nBytesUsed := rc.length()
+	d.nBytesLeft = rc.bufferLength - nBytesUsed
+	if d.nBytesLeft < 0 {
+		rc.err = errReadBeyondBuffer
+	}
+	if d.nBytesLeft == 0 {
+		rc.checkAfterDecoding()
+	}
+}
+
+// gainsDequant converts gain indices to linear gains. All but the first
+// gain of a packet are coded relative to the previous one.
+func (d *decoder) gainsDequant(ctrl *control, ind [nbSubfr]int, conditional bool) {
+	for k := 0; k < nbSubfr; k++ {
+		if k == 0 && !conditional {
+			d.lastGainIndex = int32(ind[k])
+		} else {
+			d.lastGainIndex += int32(ind[k]) + minDeltaGainQuant
+		}
+		ctrl.gainsQ16[k] = log2lin(min(smulwb(gainInvScaleQ16, d.lastGainIndex)+gainOffset, 3967))
+	}
+}
+
+func decodePitch(lagIndex, contourIndex int, pitchLags []int, fsKHz int) {
+	lag := pitchEstMinLagMs*fsKHz + lagIndex
+	cb := cbLagsStage3
+	if fsKHz == 8 {
+		cb = cbLagsStage2
+	}
+	for i := range pitchLags {
+		pitchLags[i] = lag + int(cb[i][contourIndex])
+	}
+}
+
+// decodePulses reads the quantized excitation into q.
+func (d *decoder) decodePulses(ctrl *control, q []int32) {
+	rc := &d.rc
+	ctrl.rateLevelIndex = rc.decode(rateLevelsCDF[ctrl.sigtype], 4)
+
+	iter := len(q) / shellFrameLength
+	var sumPulses, nLshifts [maxFrameLength / shellFrameLength]int
+	cdf := pulsesPerBlockCDF[ctrl.rateLevelIndex]
+	for i := 0; i < iter; i++ {
+		sumPulses[i] = rc.decode(cdf, 6)
+		// The escape symbol moves one bit per pulse to the LSB pass
+		for sumPulses[i] == maxPulses+1 {
+			nLshifts[i]++
+			sumPulses[i] = rc.decode(pulsesPerBlockCDF[nRateLevels-1], 6)
+		}
+	}
+
+	for i := 0; i < iter; i++ {
+		block := q[i*shellFrameLength : (i+1)*shellFrameLength]
+		if sumPulses[i] > 0 {
+			shellDecoder(block, rc, sumPulses[i])
+		} else {
+			clear(block)
+		}
+	}
+
+	for i := 0; i < iter; i++ {
+		if nLshifts[i] == 0 {
+			continue
+		}
+		block := q[i*shellFrameLength : (i+1)*shellFrameLength]
+		for k := range block {
+			absQ := block[k]
+			for j := 0; j < nLshifts[i]; j++ {
+				absQ = absQ<<1 + int32(rc.decode(lsbCDF, 1))
+			}
+			block[k] = absQ
+		}
+	}
+
+	decodeSigns(rc, q, ctrl.sigtype, ctrl.quantOffsetType, ctrl.rateLevelIndex)
+}
+
+func decodeSigns(rc *rangeDecoder, q []int32, sigtype, quantOffsetType, rateLevelIndex int) {
+	i := (nRateLevels-1)*(sigtype<<1+quantOffsetType) + rateLevelIndex
+	cdf := []uint16{0, signCDF[i], 65535}
+	for i := range q {
+		if q[i] > 0 {
+			q[i] *= int32(rc.decode(cdf, 1)<<1 - 1)
+		}
+	}
+}
+
+// shellDecoder splits the pulses of a 16 sample block down a binary tree.
+func shellDecoder(pulses0 []int32, rc *rangeDecoder, pulses4 int) {
+	var pulses3 [2]int32
+	var pulses2 [4]int32
+	var pulses1 [8]int32
+
+	decodeSplit(pulses3[0:2], rc, int32(pulses4), shellCodeTable3)
+	decodeSplit(pulses2[0:2], rc, pulses3[0], shellCodeTable2)
+	decodeSplit(pulses1[0:2], rc, pulses2[0], shellCodeTable1)
+	decodeSplit(pulses0[0:2], rc, pulses1[0], shellCodeTable0)
+	decodeSplit(pulses0[2:4], rc, pulses1[1], shellCodeTable0)
+	decodeSplit(pulses1[2:4], rc, pulses2[1], shellCodeTable1)
+	decodeSplit(pulses0[4:6], rc, pulses1[2], shellCodeTable0)
+	decodeSplit(pulses0[6:8], rc, pulses1[3], shellCodeTable0)
+	decodeSplit(pulses2[2:4], rc, pulses3[1], shellCodeTable2)
+	decodeSplit(pulses1[4:6], rc, pulses2[2], shellCodeTable1)
+	decodeSplit(pulses0[8:10], rc, pulses1[4], shellCodeTable0)
+	decodeSplit(pulses0[10:12], rc, pulses1[5], shellCodeTable0)
+	decodeSplit(pulses1[6:8], rc, pulses2[3], shellCodeTable1)
+	decodeSplit(pulses0[12:14], rc, pulses1[6], shellCodeTable0)
+	decodeSplit(pulses0[14:16], rc, pulses1[7], shellCodeTable0)
+}
+
+func decodeSplit(children []int32, rc *rangeDecoder, p int32, table []uint16) {
+	if p <= 0 {
+		children[0], children[1] = 0, 0
+		return
+	}
+	// The tables only cover the pulse counts the encoder produces
+	offset := int(shellCodeTableOffsets[min(p, maxPulses)])
+	if offset >= len(table) && rc.err == nil {
+		rc.err = errCDFOutOfRange
+	}
+	if rc.err != nil {
+		children[0], children[1] = 0, p
+		return
+	}
+	children[0] = int32(rc.decode(table[offset:], int(p>>1)))
+	children[1] = p - children[0]
+}
+
+// decodeCore reconstructs the frame from the excitation q by long-term and
+// short-term prediction.
+func (d *decoder) decodeCore(ctrl *control, xq []int16, q []int32) {
+	fl := d.frameLength
+	order := d.lpcOrder
+	offsetQ10 := quantizationOffsetsQ10[ctrl.sigtype][ctrl.quantOffsetType]
+	interpolated := ctrl.nlsfInterpCoefQ2 < 4
+
+	randSeed := ctrl.seed
+	for i := 0; i < fl; i++ {
+		randSeed = rand32(randSeed)
+		dither := randSeed >> 31
+		d.excQ10[i] = q[i]<<10 + offsetQ10
+		d.excQ10[i] = (d.excQ10[i] ^ dither) - dither
+		randSeed += q[i]
+	}
+
+	var sLTP [maxFrameLength]int16
+	var resQ10 [maxFrameLength / nbSubfr]int32
+	sLTPBufIdx := fl
+	lag := 0
+	for k := 0; k < nbSubfr; k++ {
+		a := ctrl.predCoefQ12[k>>1][:order]
+		b := ctrl.ltpCoefQ14[k*ltpOrder : (k+1)*ltpOrder]
+		gainQ16 := ctrl.gainsQ16[k]
+		sigtype := ctrl.sigtype
+		exc := d.excQ10[k*d.subfrLength : (k+1)*d.subfrLength]
+
+		invGainQ16 := min(inverse32VarQ(max(gainQ16, 1), 32), 0x7FFF)
+		gainAdjQ16 := int32(1 << 16)
+		if invGainQ16 != d.prevInvGainQ16 {
+			gainAdjQ16 = div32VarQ(invGainQ16, d.prevInvGainQ16, 16)
+		}
+
+		// Avoid an abrupt transition from voiced concealment to unvoiced decoding
+		if d.lossCnt != 0 && d.prevSigtype == sigTypeVoiced && ctrl.sigtype == sigTypeUnvoiced && k < nbSubfr>>1 {
+			clear(b)
+			b[ltpOrder/2] = 1 << 12
+			sigtype = sigTypeVoiced
+			ctrl.pitchL[k] = d.lagPrev
+		}
+
+		if sigtype == sigTypeVoiced {
+			lag = ctrl.pitchL[k]
+			if interpolated && k&1 == 0 || !interpolated && k == 0 {
+				// Rewhiten the past output with the new coefficients
+				startIdx := fl - lag - order - ltpOrder/2
+				var filtState [maxLPCOrder]int32
+				maPrediction(d.outBuf[startIdx+k*(fl>>2):], a, filtState[:order], sLTP[startIdx:fl])
+
+				invGainQ32 := invGainQ16 << 16
+				if k == 0 {
+					invGainQ32 = smulwb(invGainQ32, ctrl.ltpScaleQ14) << 2
+				}
+				for i := 0; i < lag+ltpOrder/2; i++ {
+					d.sLTPQ16[sLTPBufIdx-i-1] = smulwb(invGainQ32, int32(sLTP[fl-i-1]))
+				}
+			} else if gainAdjQ16 != 1<<16 {
+				for i := 0; i < lag+ltpOrder/2; i++ {
+					d.sLTPQ16[sLTPBufIdx-i-1] = smulww(gainAdjQ16, d.sLTPQ16[sLTPBufIdx-i-1])
+				}
+			}
+		}
+
+		for i := 0; i < maxLPCOrder; i++ {
+			d.sLPCQ14[i] = smulww(gainAdjQ16, d.sLPCQ14[i])
+		}
+		d.prevInvGainQ16 = invGainQ16
+
+		res := resQ10[:d.subfrLength]
+		if sigtype == sigTypeVoiced {
+			for i := range res {
+				predLag := d.sLTPQ16[sLTPBufIdx-lag+ltpOrder/2-ltpOrder+1 : sLTPBufIdx-lag+ltpOrder/2+1]
+				var ltpPredQ14 int32
+				for j := 0; j < ltpOrder; j++ {
+					ltpPredQ14 = smlawb(ltpPredQ14, predLag[ltpOrder-1-j], int32(b[j]))
+				}
+				res[i] = exc[i] + rshiftRound(ltpPredQ14, 4)
+				d.sLTPQ16[sLTPBufIdx] = res[i] << 6
+				sLTPBufIdx++
+			}
+		} else {
+			copy(res, exc)
+		}
+
+		pxq := d.outBuf[fl+k*d.subfrLength : fl+(k+1)*d.subfrLength]
+		for i := range res {
+			var lpcPredQ10 int32
+			for j := 0; j < order; j++ {
+				lpcPredQ10 = smlawb(lpcPredQ10, d.sLPCQ14[maxLPCOrder+i-j-1], int32(a[j]))
+			}
+			vecQ10 := res[i] + lpcPredQ10
+			d.sLPCQ14[maxLPCOrder+i] = vecQ10 << 4
+			pxq[i] = int16(sat16(rshiftRound(smulww(vecQ10, gainQ16), 10)))
+		}
+		copy(d.sLPCQ14[:maxLPCOrder], d.sLPCQ14[d.subfrLength:d.subfrLength+maxLPCOrder])
+	}
+
+	copy(xq, d.outBuf[fl:2*fl])
+}
+
+// maPrediction filters in with the MA coefficients b into out.
+func maPrediction(in []int16, b []int16, s []int32, out []int16) {
+	order := len(b)
+	for k := range out {
+		in16 := int32(in[k])
+		out32 := rshiftRound(in16<<12-s[0], 12)
+		for j := 0; j < order-1; j++ {
+			s[j] = s[j+1] + in16*int32(b[j])
+		}
+		s[order-1] = in16 * int32(b[order-1])
+		out[k] = int16(sat16(out32))
+	}
+}
+
+// biquad is the second order ARMA filter used to high-pass the output.
+func biquad(x []int16, b *[3]int32, a *[2]int32, s *[2]int32) {
+	s0, s1 := s[0], s[1]
+	a0Neg, a1Neg := -a[0], -a[1]
+	for k, v := range x {
+		in16 := int32(v)
+		out32 := s0 + in16*b[0]
+		s0 = s1 + in16*b[1]
+		s0 += smulwb(out32, a0Neg) << 3
+		s1 = smulwb(out32, a1Neg)<<3 + in16*b[2]
+		x[k] = int16(sat16(rshiftRound(out32, 13) + 1))
+	}
+	s[0], s[1] = s0, s1
+}
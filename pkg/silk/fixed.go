@@ -0,0 +1,198 @@
+// Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package silk
+
+import "math/bits"
+
+// Fixed-point helpers with the rounding and wrap-around of the SDK macros
+// they are named after. Arithmetic on int32 wraps like the reference build.
+
+// smulwb is (a * int16(b)) >> 16.
+func smulwb(a, b int32) int32 {
+	return (a>>16)*int32(int16(b)) + ((a&0xFFFF)*int32(int16(b)))>>16
+}
+
+func smlawb(a, b, c int32) int32 { return a + smulwb(b, c) }
+
+func smulbb(a, b int32) int32 { return int32(int16(a)) * int32(int16(b)) }
+
+// smulww is (a * b) >> 16.
+func smulww(a, b int32) int32 { return smulwb(a, b) + a*rshiftRound(b, 16) }
+
+func smlaww(a, b, c int32) int32 { return smlawb(a, b, c) + b*rshiftRound(c, 16) }
+
+// smmul is (a * b) >> 32.
+func smmul(a, b int32) int32 { return int32((int64(a) * int64(b)) >> 32) }
+
+func rshiftRound(a int32, shift uint) int32 {
+	if shift == 1 {
+		return a>>1 + a&1
+	}
+	return (a>>(shift-1) + 1) >> 1
+}
+
+func rshiftRound64(a int64, shift uint) int64 {
+	return (a>>(shift-1) + 1) >> 1
+}
+
+func addSat32(a, b int32) int32 {
+	sum := a + b
+	switch {
+	case a >= 0 && b >= 0 && sum < 0:
+		return 0x7FFFFFFF
+	case a < 0 && b < 0 && sum >= 0:
+		return -0x80000000
+	}
+	return sum
+}
+
+func lshiftSat32(a int32, shift uint) int32 {
+	return limit32(a, -0x80000000>>shift, 0x7FFFFFFF>>shift) << shift
+}
+
+func sat16(a int32) int32 {
+	switch {
+	case a > 0x7FFF:
+		return 0x7FFF
+	case a < -0x8000:
+		return -0x8000
+	}
+	return a
+}
+
+func limit32(a, lo, hi int32) int32 {
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	switch {
+	case a > hi:
+		return hi
+	case a < lo:
+		return lo
+	}
+	return a
+}
+
+func abs32(a int32) int32 {
+	if a > 0 {
+		return a
+	}
+	return -a
+}
+
+func rand32(seed int32) int32 { return 907633515 + seed*196314165 }
+
+func clz32(a int32) int32 { return int32(bits.LeadingZeros32(uint32(a))) }
+
+// clzFrac returns the leading zeros of a and the 7 bits after the leading one.
+func clzFrac(a int32) (lz, fracQ7 int32) {
+	lz = clz32(a)
+	return lz, int32(bits.RotateLeft32(uint32(a), int(lz-24))) & 0x7F
+}
+
+func sqrtApprox(x int32) int32 {
+	if x <= 0 {
+		return 0
+	}
+	lz, fracQ7 := clzFrac(x)
+	y := int32(46214) // sqrt(2) * 32768
+	if lz&1 != 0 {
+		y = 32768
+	}
+	y >>= lz >> 1
+	return smlawb(y, y, smulbb(213, fracQ7))
+}
+
+// div32VarQ approximates (a << qres) / b.
+func div32VarQ(a, b int32, qres int32) int32 {
+	aHeadrm := clz32(abs32(a)) - 1
+	aNrm := a << aHeadrm
+	bHeadrm := clz32(abs32(b)) - 1
+	bNrm := b << bHeadrm
+
+	bInv := (0x7FFFFFFF >> 2) / (bNrm >> 16)
+	result := smulwb(aNrm, bInv)
+	aNrm -= smmul(bNrm, result) << 3
+	result = smlawb(result, aNrm, bInv)
+
+	return shiftQ(result, 29+aHeadrm-bHeadrm-qres)
+}
+
+// inverse32VarQ approximates (1 << qres) / b.
+func inverse32VarQ(b int32, qres int32) int32 {
+	bHeadrm := clz32(abs32(b)) - 1
+	bNrm := b << bHeadrm
+
+	bInv := (0x7FFFFFFF >> 2) / (bNrm >> 16)
+	result := bInv << 16
+	errQ32 := -smulwb(bNrm, bInv) << 3
+	result = smlaww(result, errQ32, bInv)
+
+	return shiftQ(result, 61-bHeadrm-qres)
+}
+
+func shiftQ(result, lshift int32) int32 {
+	switch {
+	case lshift <= 0:
+		return lshiftSat32(result, uint(-lshift))
+	case lshift < 32:
+		return result >> lshift
+	}
+	return 0
+}
+
+// lin2log approximates 128 * log2(in).
+func lin2log(in int32) int32 {
+	lz, fracQ7 := clzFrac(in)
+	return (31-lz)<<7 + smlawb(fracQ7, fracQ7*(128-fracQ7), 179)
+}
+
+// log2lin approximates 2^(in / 128).
+func log2lin(inQ7 int32) int32 {
+	if inQ7 < 0 {
+		return 0
+	} else if inQ7 >= 31<<7 {
+		return 0x7FFFFFFF
+	}
+	out := int32(1) << (inQ7 >> 7)
+	fracQ7 := inQ7 & 0x7F
+	if inQ7 < 2048 {
+		return out + (out*smlawb(fracQ7, fracQ7*(128-fracQ7), -174))>>7
+	}
+	return out + (out>>7)*smlawb(fracQ7, fracQ7*(128-fracQ7), -174)
+}
+
+// sumSqrShift returns the energy of x, shifted right by shift bits so that
+// it fits in 31 bits.
+func sumSqrShift(x []int16) (energy int32, shift int) {
+	n := len(x) - 1
+	var nrg int32
+	i := 0
+	for ; i < n; i += 2 {
+		nrg += int32(x[i])*int32(x[i]) + int32(x[i+1])*int32(x[i+1])
+		if nrg < 0 {
+			nrg = int32(uint32(nrg) >> 2)
+			shift = 2
+			i += 2
+			break
+		}
+	}
+	for ; i < n; i += 2 {
+		tmp := uint32(int32(x[i])*int32(x[i]) + int32(x[i+1])*int32(x[i+1]))
+		nrg = int32(uint32(nrg) + tmp>>shift)
+		if nrg < 0 {
+			nrg = int32(uint32(nrg) >> 2)
+			shift += 2
+		}
+	}
+	if i == n {
+		tmp := uint32(int32(x[i]) * int32(x[i]))
+		nrg = int32(uint32(nrg) + tmp>>shift)
+	}
+	if uint32(nrg)&0xC0000000 != 0 {
+		nrg = int32(uint32(nrg) >> 2)
+		shift += 2
+	}
+	return nrg, shift
+}
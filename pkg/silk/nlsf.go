@@ -0,0 +1,240 @@
+// Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package silk
+
+import "sort"
+
+// nlsfStage is one stage of a multi-stage NLSF vector quantizer.
+type nlsfStage struct {
+	nVectors int
+	cb       []int16 // nVectors vectors of LPC order entries, Q15
+}
+
+// nlsfCodebook is the NLSF quantizer for one signal type and LPC order.
+type nlsfCodebook struct {
+	stages    []nlsfStage
+	nDeltaMin []int32 // minimum spacing between NLSFs, order+1 entries, Q15
+	cdf       []uint16
+	start     []int // offset of each stage's CDF in cdf
+	middle    []int // search start of each stage's CDF
+}
+
+// decodeNLSF reads the codebook path and returns the stabilized NLSF vector.
+func (cb *nlsfCodebook) decodeNLSF(rc *rangeDecoder, order int) []int32 {
+	nlsf := make([]int32, order)
+	for s, stage := range cb.stages {
+		ix := rc.decode(cb.cdf[cb.start[s]:], cb.middle[s])
+		vec := stage.cb[ix*order : (ix+1)*order]
+		for i := range nlsf {
+			nlsf[i] += int32(vec[i])
+		}
+	}
+	nlsfStabilize(nlsf, cb.nDeltaMin)
+	return nlsf
+}
+
+// nlsfStabilize enforces the minimum distance between NLSFs and to the
+// edges of the range.
+func nlsfStabilize(nlsf, nDeltaMin []int32) {
+	const maxLoops = 20
+	l := len(nlsf)
+
+	for loops := 0; loops < maxLoops; loops++ {
+		minDiff := nlsf[0] - nDeltaMin[0]
+		ix := 0
+		for i := 1; i <= l-1; i++ {
+			diff := nlsf[i] - (nlsf[i-1] + nDeltaMin[i])
+			if diff < minDiff {
+				minDiff = diff
+				ix = i
+			}
+		}
+		diff := 1<<15 - (nlsf[l-1] + nDeltaMin[l])
+		if diff < minDiff {
+			minDiff = diff
+			ix = l
+		}
+		if minDiff >= 0 {
+			return
+		}
+
+		switch ix {
+		case 0:
+			nlsf[0] = nDeltaMin[0]
+		case l:
+			nlsf[l-1] = 1<<15 - nDeltaMin[l]
+		default:
+			var minCenter int32
+			for k := 0; k < ix; k++ {
+				minCenter += nDeltaMin[k]
+			}
+			minCenter += nDeltaMin[ix] >> 1
+
+			maxCenter := int32(1 << 15)
+			for k := l; k > ix; k-- {
+				maxCenter -= nDeltaMin[k]
+			}
+			maxCenter -= nDeltaMin[ix] - nDeltaMin[ix]>>1
+
+			center := limit32(rshiftRound(nlsf[ix-1]+nlsf[ix], 1), minCenter, maxCenter)
+			nlsf[ix-1] = center - nDeltaMin[ix]>>1
+			nlsf[ix] = nlsf[ix-1] + nDeltaMin[ix]
+		}
+	}
+
+	// Fall back to sorting and clamping in both directions
+	sort.Slice(nlsf, func(i, j int) bool { return nlsf[i] < nlsf[j] })
+	nlsf[0] = max(nlsf[0], nDeltaMin[0])
+	for i := 1; i < l; i++ {
+		nlsf[i] = max(nlsf[i], nlsf[i-1]+nDeltaMin[i])
+	}
+	nlsf[l-1] = min(nlsf[l-1], 1<<15-nDeltaMin[l])
+	for i := l - 2; i >= 0; i-- {
+		nlsf[i] = min(nlsf[i], nlsf[i+1]-nDeltaMin[i+1])
+	}
+}
+
+// nlsfFindPoly computes the polynomial with the roots in cLSF, taking every
+// second entry.
+func nlsfFindPoly(out []int32, cLSF []int32, dd int) {
+	out[0] = 1 << 20
+	out[1] = -cLSF[0]
+	for k := 1; k < dd; k++ {
+		ftmp := cLSF[2*k]
+		out[k+1] = out[k-1]<<1 - int32(rshiftRound64(int64(ftmp)*int64(out[k]), 20))
+		for n := k; n > 1; n-- {
+			out[n] += out[n-2] - int32(rshiftRound64(int64(ftmp)*int64(out[n-1]), 20))
+		}
+		out[1] -= ftmp
+	}
+}
+
+// nlsf2A converts NLSFs in Q15 to prediction coefficients in Q12.
+func nlsf2A(a []int16, nlsf []int32) {
+	d := len(nlsf)
+	var cosLSF [maxLPCOrder]int32
+	for k, f := range nlsf {
+		fInt := f >> 8
+		fFrac := f - fInt<<8
+		cosVal := lsfCosTabQ12[fInt]
+		delta := lsfCosTabQ12[fInt+1] - cosVal
+		cosLSF[k] = cosVal<<8 + delta*fFrac
+	}
+
+	dd := d >> 1
+	var p, q [maxLPCOrder/2 + 1]int32
+	nlsfFindPoly(p[:], cosLSF[0:], dd)
+	nlsfFindPoly(q[:], cosLSF[1:], dd)
+
+	var a32 [maxLPCOrder]int32
+	for k := 0; k < dd; k++ {
+		ptmp := p[k+1] + p[k]
+		qtmp := q[k+1] - q[k]
+		a32[k] = -rshiftRound(ptmp+qtmp, 9)
+		a32[d-k-1] = rshiftRound(qtmp-ptmp, 9)
+	}
+
+	var i int
+	var idx int32
+	for i = 0; i < 10; i++ {
+		var maxabs int32
+		for k := 0; k < d; k++ {
+			if v := abs32(a32[k]); v > maxabs {
+				maxabs = v
+				idx = int32(k)
+			}
+		}
+		if maxabs <= 0x7FFF {
+			break
+		}
+		maxabs = min(maxabs, 98369)
+		scQ16 := 65470 - (65470>>2)*(maxabs-0x7FFF)/((maxabs*(idx+1))>>2)
+		bwexpander32(a32[:d], scQ16)
+	}
+	if i == 10 {
+		for k := 0; k < d; k++ {
+			a32[k] = sat16(a32[k])
+		}
+	}
+	for k := 0; k < d; k++ {
+		a[k] = int16(a32[k])
+	}
+}
+
+// nlsf2AStable converts NLSFs to prediction coefficients and widens the
+// bandwidth until the filter is stable.
+func nlsf2AStable(a []int16, nlsf []int32) {
+	const maxIterations = 20
+	nlsf2A(a, nlsf)
+	for i := int32(0); i < maxIterations; i++ {
+		if _, unstable := lpcInversePredGain(a[:len(nlsf)]); !unstable {
+			return
+		}
+		bwexpander(a[:len(nlsf)], 65536-smulbb(10+i, i))
+	}
+	for i := range nlsf {
+		a[i] = 0
+	}
+}
+
+// lpcInversePredGain runs the step-down recursion on a in Q12 and returns
+// the inverse prediction gain in Q30, and whether a reflection coefficient
+// got too close to one. The gain is partial for unstable filters.
+func lpcInversePredGain(a []int16) (invGainQ30 int32, unstable bool) {
+	const aLimit = 65520 // 0.99975 in Q16
+	order := len(a)
+
+	var atmp [2][maxLPCOrder]int32
+	aNew := atmp[order&1][:]
+	for k, v := range a {
+		aNew[k] = int32(v) << 4
+	}
+
+	invGainQ30 = 1 << 30
+	for k := order - 1; k > 0; k-- {
+		if aNew[k] > aLimit || aNew[k] < -aLimit {
+			return invGainQ30, true
+		}
+		rcQ31 := -(aNew[k] << 15)
+		rcMult1Q30 := int32(0x7FFFFFFF>>1) - smmul(rcQ31, rcQ31)
+		rcMult2Q16 := inverse32VarQ(rcMult1Q30, 46)
+		invGainQ30 = smmul(invGainQ30, rcMult1Q30) << 2
+
+		aOld := aNew
+		aNew = atmp[k&1][:]
+		headrm := clz32(rcMult2Q16) - 1
+		rcMult2Q16 <<= headrm
+		for n := 0; n < k; n++ {
+			tmp := aOld[n] - smmul(aOld[k-n-1], rcQ31)<<1
+			aNew[n] = smmul(tmp, rcMult2Q16) << (16 - headrm)
+		}
+	}
+	if aNew[0] > aLimit || aNew[0] < -aLimit {
+		return invGainQ30, true
+	}
+	rcQ31 := -(aNew[0] << 15)
+	rcMult1Q30 := int32(0x7FFFFFFF>>1) - smmul(rcQ31, rcQ31)
+	return smmul(invGainQ30, rcMult1Q30) << 2, false
+}
+
+// bwexpander scales the coefficients of ar by successive powers of chirpQ16.
+func bwexpander(ar []int16, chirpQ16 int32) {
+	chirpMinusOneQ16 := chirpQ16 - 65536
+	d := len(ar)
+	for i := 0; i < d-1; i++ {
+		ar[i] = int16(rshiftRound(chirpQ16*int32(ar[i]), 16))
+		chirpQ16 += rshiftRound(chirpQ16*chirpMinusOneQ16, 16)
+	}
+	ar[d-1] = int16(rshiftRound(chirpQ16*int32(ar[d-1]), 16))
+}
+
+func bwexpander32(ar []int32, chirpQ16 int32) {
+	tmpChirpQ16 := chirpQ16
+	d := len(ar)
+	for i := 0; i < d-1; i++ {
+		ar[i] = smulww(ar[i], tmpChirpQ16)
+		tmpChirpQ16 = smulww(chirpQ16, tmpChirpQ16)
+	}
+	ar[d-1] = smulww(ar[d-1], tmpChirpQ16)
+}
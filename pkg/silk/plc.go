@@ -0,0 +1,352 @@
+// Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package silk
+
+// Packet loss concealment and comfort noise.
+
+const (
+	plcBWECoefQ16           = 64880
+	vPitchGainStartMinQ14   = 11469
+	vPitchGainStartMaxQ14   = 15565
+	maxPitchLagMs           = 18
+	randBufSize             = 128
+	randBufMask             = randBufSize - 1
+	log2InvLPCGainHighThres = 3
+	log2InvLPCGainLowThres  = 8
+	pitchDriftFacQ16        = 655
+	cngBufMaskMax           = 255
+	cngGainSmthQ16          = 4634
+	cngNLSFSmthQ16          = 16348
+	cngRandSeedInit         = 3176576
+	plcRandScaleMinQ14      = 3277
+)
+
+var (
+	harmAttQ15            = [2]int32{32440, 31130}
+	plcRandAttenuateVQ15  = [2]int32{31130, 26214}
+	plcRandAttenuateUVQ15 = [2]int32{32440, 29491}
+)
+
+type plcState struct {
+	pitchLQ8        int32
+	ltpCoefQ14      [ltpOrder]int16
+	prevLPCQ12      [maxLPCOrder]int16
+	lastFrameLost   bool
+	randSeed        int32
+	randScaleQ14    int16
+	concEnergy      int32
+	concEnergyShift int
+	prevLTPScaleQ14 int32
+	prevGainQ16     [nbSubfr]int32
+	fsKHz           int
+}
+
+type cngState struct {
+	excBufQ10   [maxFrameLength]int32
+	smthNLSFQ15 [maxLPCOrder]int32
+	synthState  [maxLPCOrder]int32
+	smthGainQ16 int32
+	randSeed    int32
+	fsKHz       int
+}
+
+func (d *decoder) plcReset() {
+	d.plc.pitchLQ8 = int32(d.frameLength >> 1)
+}
+
+// plcFrame conceals a lost frame into signal, or learns from a good one.
+func (d *decoder) plcFrame(ctrl *control, signal []int16, lost bool) {
+	if d.fsKHz != d.plc.fsKHz {
+		d.plcReset()
+		d.plc.fsKHz = d.fsKHz
+	}
+	if lost {
+		d.plcConceal(ctrl, signal)
+		d.lossCnt++
+	} else {
+		d.plcUpdate(ctrl)
+	}
+}
+
+func (d *decoder) plcUpdate(ctrl *control) {
+	plc := &d.plc
+	d.prevSigtype = ctrl.sigtype
+
+	var ltpGainQ14 int32
+	if ctrl.sigtype == sigTypeVoiced {
+		// Use the last subframe that contains a pitch pulse
+		for j := 0; j*d.subfrLength < ctrl.pitchL[nbSubfr-1]; j++ {
+			coef := ctrl.ltpCoefQ14[(nbSubfr-1-j)*ltpOrder : (nbSubfr-j)*ltpOrder]
+			var tmp int32
+			for _, c := range coef {
+				tmp += int32(c)
+			}
+			if tmp > ltpGainQ14 {
+				ltpGainQ14 = tmp
+				copy(plc.ltpCoefQ14[:], coef)
+				plc.pitchLQ8 = int32(ctrl.pitchL[nbSubfr-1-j]) << 8
+			}
+		}
+
+		plc.ltpCoefQ14 = [ltpOrder]int16{}
+		plc.ltpCoefQ14[ltpOrder/2] = int16(ltpGainQ14)
+
+		if ltpGainQ14 < vPitchGainStartMinQ14 {
+			scaleQ10 := int32(vPitchGainStartMinQ14<<10) / max(ltpGainQ14, 1)
+			for i := range plc.ltpCoefQ14 {
+				plc.ltpCoefQ14[i] = int16(smulbb(int32(plc.ltpCoefQ14[i]), scaleQ10) >> 10)
+			}
+		} else if ltpGainQ14 > vPitchGainStartMaxQ14 {
+			scaleQ14 := int32(vPitchGainStartMaxQ14<<14) / max(ltpGainQ14, 1)
+			for i := range plc.ltpCoefQ14 {
+				plc.ltpCoefQ14[i] = int16(smulbb(int32(plc.ltpCoefQ14[i]), scaleQ14) >> 14)
+			}
+		}
+	} else {
+		plc.pitchLQ8 = int32(d.fsKHz*18) << 8
+		plc.ltpCoefQ14 = [ltpOrder]int16{}
+	}
+
+	copy(plc.prevLPCQ12[:d.lpcOrder], ctrl.predCoefQ12[1][:d.lpcOrder])
+	plc.prevLTPScaleQ14 = ctrl.ltpScaleQ14
+	plc.prevGainQ16 = ctrl.gainsQ16
+}
+
+// plcLag returns the concealment pitch lag, limited to the LTP buffer, which
+// a lag carried over from a higher sampling rate can exceed.
+func (d *decoder) plcLag() int {
+	return min(int(rshiftRound(d.plc.pitchLQ8, 8)), d.frameLength-ltpOrder/2)
+}
+
+func (d *decoder) plcConceal(ctrl *control, signal []int16) {
+	plc := &d.plc
+	fl, sub, order := d.frameLength, d.subfrLength, d.lpcOrder
+
+	copy(d.sLTPQ16[:fl], d.sLTPQ16[fl:2*fl])
+	bwexpander(plc.prevLPCQ12[:order], plcBWECoefQ16)
+
+	// Use the quieter of the last two subframes as the noise source
+	var excBuf [maxFrameLength]int16
+	for k := nbSubfr >> 1; k < nbSubfr; k++ {
+		for i := 0; i < sub; i++ {
+			excBuf[(k-nbSubfr/2)*sub+i] = int16(smulww(d.excQ10[i+k*sub], plc.prevGainQ16[k]) >> 10)
+		}
+	}
+	energy1, shift1 := sumSqrShift(excBuf[:sub])
+	energy2, shift2 := sumSqrShift(excBuf[sub : 2*sub])
+	var randBuf []int32
+	if energy1>>shift2 < energy2>>shift1 {
+		randBuf = d.excQ10[max(0, 3*sub-randBufSize):]
+	} else {
+		randBuf = d.excQ10[max(0, fl-randBufSize):]
+	}
+
+	b := &plc.ltpCoefQ14
+	randScaleQ14 := plc.randScaleQ14
+
+	att := min(1, d.lossCnt)
+	harmGainQ15 := harmAttQ15[att]
+	randGainQ15 := plcRandAttenuateUVQ15[att]
+	if d.prevSigtype == sigTypeVoiced {
+		randGainQ15 = plcRandAttenuateVQ15[att]
+	}
+
+	if d.lossCnt == 0 {
+		randScaleQ14 = 1 << 14
+		if d.prevSigtype == sigTypeVoiced {
+			for _, c := range b {
+				randScaleQ14 -= c
+			}
+			randScaleQ14 = max(plcRandScaleMinQ14, randScaleQ14)
+			randScaleQ14 = int16(smulbb(int32(randScaleQ14), plc.prevLTPScaleQ14) >> 14)
+		}
+		// Reduce the noise for unvoiced frames with a high LPC gain
+		if d.prevSigtype == sigTypeUnvoiced {
+			invGainQ30, _ := lpcInversePredGain(plc.prevLPCQ12[:order])
+			downScaleQ30 := min(int32(1<<30)>>log2InvLPCGainHighThres, invGainQ30)
+			downScaleQ30 = max(int32(1<<30)>>log2InvLPCGainLowThres, downScaleQ30)
+			downScaleQ30 <<= log2InvLPCGainHighThres
+			randGainQ15 = smulwb(downScaleQ30, randGainQ15) >> 14
+		}
+	}
+
+	randSeed := plc.randSeed
+	lag := d.plcLag()
+	sLTPBufIdx := fl
+
+	var sigQ10 [maxFrameLength]int32
+	for k := 0; k < nbSubfr; k++ {
+		for i := 0; i < sub; i++ {
+			randSeed = rand32(randSeed)
+			idx := (randSeed >> 25) & randBufMask
+
+			var ltpPredQ14 int32
+			for j := 0; j < ltpOrder; j++ {
+				ltpPredQ14 = smlawb(ltpPredQ14, d.sLTPQ16[sLTPBufIdx-lag+ltpOrder/2-j], int32(b[j]))
+			}
+
+			lpcExcQ10 := smulwb(randBuf[idx], int32(randScaleQ14)) << 2
+			lpcExcQ10 += rshiftRound(ltpPredQ14, 4)
+
+			d.sLTPQ16[sLTPBufIdx] = lpcExcQ10 << 6
+			sLTPBufIdx++
+			sigQ10[k*sub+i] = lpcExcQ10
+		}
+
+		for j := range b {
+			b[j] = int16(smulbb(harmGainQ15, int32(b[j])) >> 15)
+		}
+		randScaleQ14 = int16(smulbb(int32(randScaleQ14), randGainQ15) >> 15)
+
+		// Slowly increase the pitch lag
+		plc.pitchLQ8 += smulwb(plc.pitchLQ8, pitchDriftFacQ16)
+		plc.pitchLQ8 = min(plc.pitchLQ8, int32(maxPitchLagMs*d.fsKHz)<<8)
+		lag = d.plcLag()
+	}
+
+	a := plc.prevLPCQ12[:order]
+	for k := 0; k < nbSubfr; k++ {
+		sig := sigQ10[k*sub : (k+1)*sub]
+		for i := range sig {
+			var lpcPredQ10 int32
+			for j := 0; j < order; j++ {
+				lpcPredQ10 = smlawb(lpcPredQ10, d.sLPCQ14[maxLPCOrder+i-j-1], int32(a[j]))
+			}
+			sig[i] += lpcPredQ10
+			d.sLPCQ14[maxLPCOrder+i] = sig[i] << 4
+		}
+		copy(d.sLPCQ14[:maxLPCOrder], d.sLPCQ14[sub:sub+maxLPCOrder])
+	}
+
+	for i := 0; i < fl; i++ {
+		signal[i] = int16(sat16(rshiftRound(smulww(sigQ10[i], plc.prevGainQ16[nbSubfr-1]), 10)))
+	}
+
+	plc.randSeed = randSeed
+	plc.randScaleQ14 = randScaleQ14
+	for i := range ctrl.pitchL {
+		ctrl.pitchL[i] = lag
+	}
+}
+
+// plcGlueFrames fades in the first good frame after a loss when it is
+// louder than the concealment was.
+func (d *decoder) plcGlueFrames(signal []int16) {
+	plc := &d.plc
+	if d.lossCnt != 0 {
+		plc.concEnergy, plc.concEnergyShift = sumSqrShift(signal)
+		plc.lastFrameLost = true
+		return
+	}
+
+	if plc.lastFrameLost {
+		energy, energyShift := sumSqrShift(signal)
+		if energyShift > plc.concEnergyShift {
+			plc.concEnergy >>= energyShift - plc.concEnergyShift
+		} else if energyShift < plc.concEnergyShift {
+			energy >>= plc.concEnergyShift - energyShift
+		}
+
+		if energy > plc.concEnergy {
+			lz := clz32(plc.concEnergy) - 1
+			plc.concEnergy <<= lz
+			energy >>= max(24-lz, 0)
+			fracQ24 := plc.concEnergy / max(energy, 1)
+
+			gainQ12 := sqrtApprox(fracQ24)
+			slopeQ12 := (1<<12 - gainQ12) / int32(len(signal))
+			for i := range signal {
+				signal[i] = int16((gainQ12 * int32(signal[i])) >> 12)
+				gainQ12 += slopeQ12
+				gainQ12 = min(gainQ12, 1<<12)
+			}
+		}
+	}
+	plc.lastFrameLost = false
+}
+
+func (d *decoder) cngReset() {
+	stepQ15 := int32(0x7FFF / (d.lpcOrder + 1))
+	var acc int32
+	for i := 0; i < d.lpcOrder; i++ {
+		acc += stepQ15
+		d.cng.smthNLSFQ15[i] = acc
+	}
+	d.cng.smthGainQ16 = 0
+	d.cng.randSeed = cngRandSeedInit
+}
+
+// applyCNG tracks the background noise during silence and adds comfort
+// noise to concealed frames.
+func (d *decoder) applyCNG(ctrl *control, signal []int16) {
+	cng := &d.cng
+	if d.fsKHz != cng.fsKHz {
+		d.cngReset()
+		cng.fsKHz = d.fsKHz
+	}
+	order, sub := d.lpcOrder, d.subfrLength
+
+	if d.lossCnt == 0 && d.vadFlag == noVoiceActivity {
+		for i := 0; i < order; i++ {
+			cng.smthNLSFQ15[i] += smulwb(d.prevNLSFQ15[i]-cng.smthNLSFQ15[i], cngNLSFSmthQ16)
+		}
+
+		var maxGainQ16 int32
+		subfr := 0
+		for i, g := range ctrl.gainsQ16 {
+			if g > maxGainQ16 {
+				maxGainQ16 = g
+				subfr = i
+			}
+		}
+		copy(cng.excBufQ10[sub:nbSubfr*sub], cng.excBufQ10[:(nbSubfr-1)*sub])
+		copy(cng.excBufQ10[:sub], d.excQ10[subfr*sub:(subfr+1)*sub])
+
+		for _, g := range ctrl.gainsQ16 {
+			cng.smthGainQ16 += smulwb(g-cng.smthGainQ16, cngGainSmthQ16)
+		}
+	}
+
+	if d.lossCnt == 0 {
+		clear(cng.synthState[:order])
+		return
+	}
+
+	var cngSig [maxFrameLength]int16
+	sig := cngSig[:len(signal)]
+	excMask := int32(cngBufMaskMax)
+	for excMask > int32(len(signal)) {
+		excMask >>= 1
+	}
+	seed := cng.randSeed
+	for i := range sig {
+		seed = rand32(seed)
+		idx := (seed >> 24) & excMask
+		sig[i] = int16(sat16(rshiftRound(smulww(cng.excBufQ10[idx], cng.smthGainQ16), 10)))
+	}
+	cng.randSeed = seed
+
+	var lpc [maxLPCOrder]int16
+	nlsf2AStable(lpc[:order], cng.smthNLSFQ15[:order])
+	lpcSynthesis(sig, lpc[:order], 1<<26, cng.synthState[:order])
+
+	for i := range signal {
+		signal[i] = int16(sat16(int32(signal[i]) + int32(sig[i])))
+	}
+}
+
+// lpcSynthesis runs the all-pole filter a over x in place.
+func lpcSynthesis(x []int16, a []int16, gainQ26 int32, s []int32) {
+	order := len(a)
+	for k, v := range x {
+		var out32Q10 int32
+		for j := 0; j < order; j++ {
+			out32Q10 = smlawb(out32Q10, s[order-1-j], int32(a[j]))
+		}
+		copy(s[:order-1], s[1:])
+		out32Q10 = addSat32(out32Q10, smulwb(gainQ26, int32(v)))
+		x[k] = int16(sat16(rshiftRound(out32Q10, 10)))
+		s[order-1] = lshiftSat32(out32Q10, 4)
+	}
+}
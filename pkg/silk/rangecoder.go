@@ -0,0 +1,145 @@
+// Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package silk
+
+import "errors"
+
+const maxArithmBytes = 1024
+
+var (
+	errPayloadTooLong     = errors.New("payload too long")
+	errCDFOutOfRange      = errors.New("symbol outside the CDF")
+	errNormalization      = errors.New("range normalization failed")
+	errZeroInterval       = errors.New("zero interval width")
+	errCheckAfterDecoding = errors.New("trailing bits are not set")
+	errReadBeyondBuffer   = errors.New("read beyond the end of the payload")
+)
+
+// rangeDecoder reads symbols from a packet. The buffer outlives the packet:
+// the decoder looks up to four bytes past the payload, so those bytes are
+// whatever an earlier, longer packet left there, as in the reference decoder.
+type rangeDecoder struct {
+	buffer       [maxArithmBytes + 4]byte
+	bufferLength int
+	bufferIx     int
+	baseQ32      uint32
+	rangeQ16     uint32
+	err          error
+}
+
+func (rc *rangeDecoder) init(payload []byte) {
+	if len(payload) > maxArithmBytes {
+		rc.err = errPayloadTooLong
+		return
+	}
+	copy(rc.buffer[:], payload)
+	rc.bufferLength = len(payload)
+	rc.bufferIx = 0
+	rc.baseQ32 = uint32(rc.buffer[0])<<24 | uint32(rc.buffer[1])<<16 |
+		uint32(rc.buffer[2])<<8 | uint32(rc.buffer[3])
+	rc.rangeQ16 = 0xFFFF
+	rc.err = nil
+}
+
+// decode returns the next symbol coded with cdf, searching from probIx.
+// After an error every symbol decodes as zero.
+func (rc *rangeDecoder) decode(cdf []uint16, probIx int) int {
+	if rc.err != nil {
+		return 0
+	}
+	base := rc.baseQ32
+	rng := rc.rangeQ16
+	bufferIx := rc.bufferIx
+
+	var low, high uint32
+	high = uint32(cdf[probIx])
+	if rng*high > base {
+		for {
+			probIx--
+			low = uint32(cdf[probIx])
+			if rng*low <= base {
+				break
+			}
+			high = low
+			if high == 0 {
+				rc.err = errCDFOutOfRange
+				return 0
+			}
+		}
+	} else {
+		for {
+			low = high
+			probIx++
+			high = uint32(cdf[probIx])
+			if rng*high > base {
+				probIx--
+				break
+			}
+			if high == 0xFFFF {
+				rc.err = errCDFOutOfRange
+				return 0
+			}
+		}
+	}
+	base -= rng * low
+	rangeQ32 := rng * (high - low)
+
+	if rangeQ32&0xFF000000 != 0 {
+		rng = rangeQ32 >> 16
+	} else {
+		if rangeQ32&0xFFFF0000 != 0 {
+			rng = rangeQ32 >> 8
+			if base>>24 != 0 {
+				rc.err = errNormalization
+				return 0
+			}
+		} else {
+			rng = rangeQ32
+			if base>>16 != 0 {
+				rc.err = errNormalization
+				return 0
+			}
+			base <<= 8
+			if bufferIx < rc.bufferLength {
+				base |= uint32(rc.buffer[4+bufferIx])
+				bufferIx++
+			}
+		}
+		base <<= 8
+		if bufferIx < rc.bufferLength {
+			base |= uint32(rc.buffer[4+bufferIx])
+			bufferIx++
+		}
+	}
+	if rng == 0 {
+		rc.err = errZeroInterval
+		return 0
+	}
+
+	rc.baseQ32 = base
+	rc.rangeQ16 = rng
+	rc.bufferIx = bufferIx
+	return probIx
+}
+
+// length returns the number of bits and whole bytes read so far.
+func (rc *rangeDecoder) length() (nBits, nBytes int) {
+	nBits = rc.bufferIx<<3 + int(clz32(int32(rc.rangeQ16-1))) - 14
+	return nBits, (nBits + 7) >> 3
+}
+
+// checkAfterDecoding verifies the encoder's padding of the last byte.
+func (rc *rangeDecoder) checkAfterDecoding() {
+	nBits, nBytes := rc.length()
+	if nBytes-1 >= rc.bufferLength {
+		rc.err = errCheckAfterDecoding
+		return
+	}
+	if nBits&7 != 0 {
+		mask := byte(0xFF >> (nBits & 7))
+		if rc.buffer[nBytes-1]&mask != mask {
+			rc.err = errCheckAfterDecoding
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package silk
+
+// The SDK resampler, reduced to the upsampling the decoder needs to reach
+// SampleRate from its internal rates of 8, 12 and 16 kHz.
+
+const resamplerOrderFIR144 = 6
+
+var (
+	up2HQ0     = [2]int32{4280, 33727 - 65536}
+	up2HQ1     = [2]int32{16295, 54015 - 65536}
+	up2HQNotch = [4]int32{7864, -3604, 13107, 28508}
+)
+
+type resampler struct {
+	ready       bool
+	sIIR        [6]int32
+	sFIR        [resamplerOrderFIR144]int16
+	up2Only     bool // the output rate is exactly twice the input rate
+	batchSize   int
+	invRatioQ16 int32
+}
+
+func (r *resampler) init(fsIn, fsOut int32) {
+	*r = resampler{ready: true}
+	if fsOut == 2*fsIn {
+		r.up2Only = true
+		return
+	}
+	// Upsample by 2 and interpolate with a FIR filter in 10ms batches
+	r.batchSize = int(fsIn / 100)
+	r.invRatioQ16 = ((fsIn << 15) / fsOut) << 2
+	for smulww(r.invRatioQ16, fsOut) < fsIn<<1 {
+		r.invRatioQ16++
+	}
+}
+
+// resample appends in, resampled, to out.
+func (r *resampler) resample(out, in []int16) []int16 {
+	if r.up2Only {
+		start := len(out)
+		out = append(out, make([]int16, 2*len(in))...)
+		up2HQ(&r.sIIR, out[start:], in)
+		return out
+	}
+
+	buf := make([]int16, resamplerOrderFIR144+2*r.batchSize)
+	copy(buf, r.sFIR[:])
+	for len(in) > 0 {
+		n := min(len(in), r.batchSize)
+		up2HQ(&r.sIIR, buf[resamplerOrderFIR144:], in[:n])
+
+		maxIndexQ16 := int32(n) << 17
+		for indexQ16 := int32(0); indexQ16 < maxIndexQ16; indexQ16 += r.invRatioQ16 {
+			tableIndex := smulwb(indexQ16&0xFFFF, 144)
+			x := buf[indexQ16>>16:]
+			f, g := resamplerFracFIR144[tableIndex], resamplerFracFIR144[143-tableIndex]
+			resQ15 := int32(x[0])*int32(f[0]) + int32(x[1])*int32(f[1]) + int32(x[2])*int32(f[2]) +
+				int32(x[3])*int32(g[2]) + int32(x[4])*int32(g[1]) + int32(x[5])*int32(g[0])
+			out = append(out, int16(sat16(rshiftRound(resQ15, 15))))
+		}
+
+		in = in[n:]
+		copy(buf, buf[n<<1:n<<1+resamplerOrderFIR144])
+	}
+	copy(r.sFIR[:], buf)
+	return out
+}
+
+// up2HQ upsamples by two with allpass filters and a notch just above the
+// input Nyquist frequency.
+func up2HQ(s *[6]int32, out, in []int16) {
+	for k, v := range in {
+		in32 := int32(v) << 10
+
+		y := in32 - s[0]
+		x := smulwb(y, up2HQ0[0])
+		out32a := s[0] + x
+		s[0] = in32 + x
+
+		y = out32a - s[1]
+		x = smlawb(y, y, up2HQ0[1])
+		out32b := s[1] + x
+		s[1] = out32a + x
+
+		out32b = smlawb(out32b, s[5], up2HQNotch[2])
+		out32b = smlawb(out32b, s[4], up2HQNotch[1])
+		out32a = smlawb(out32b, s[4], up2HQNotch[0])
+		s[5] = out32b - s[5]
+		out[2*k] = int16(sat16(smlawb(256, out32a, up2HQNotch[3]) >> 9))
+
+		y = in32 - s[2]
+		x = smulwb(y, up2HQ1[0])
+		out32a = s[2] + x
+		s[2] = in32 + x
+
+		y = out32a - s[3]
+		x = smlawb(y, y, up2HQ1[1])
+		out32b = s[3] + x
+		s[3] = out32a + x
+
+		out32b = smlawb(out32b, s[4], up2HQNotch[2])
+		out32b = smlawb(out32b, s[5], up2HQNotch[1])
+		out32a = smlawb(out32b, s[5], up2HQNotch[0])
+		s[4] = out32b - s[4]
+		out[2*k+1] = int16(sat16(smlawb(256, out32a, up2HQNotch[3]) >> 9))
+	}
+}
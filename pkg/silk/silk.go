@@ -0,0 +1,72 @@
+// Copyright (c) 2006-2012, Skype Limited. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package silk decodes SILK v3 audio, the codec of WeChat voice messages, to
+// PCM. It is a port of the fixed-point decoder in the Skype SILK SDK 1.0.9.6
+// and produces the same samples; NOTICE lists the SDK sources it follows and
+// LICENSE holds the SDK license.
+package silk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// SampleRate is the rate of the PCM that Decode produces.
+const SampleRate = 24000
+
+// header starts a SILK v3 stream. WeChat voice messages prefix it with a
+// 0x02 byte.
+var header = []byte("#!SILK_V3")
+
+// IsSilk reports whether data starts like a SILK v3 stream.
+func IsSilk(data []byte) bool {
+	return bytes.HasPrefix(data, header) ||
+		len(data) > 0 && data[0] == 0x02 && bytes.HasPrefix(data[1:], header)
+}
+
+// Decode decodes a SILK v3 stream to 16-bit mono little-endian PCM at
+// SampleRate. Packets are a little-endian int16 byte count followed by the
+// payload; a zero count marks a lost packet and a negative one or a
+// truncated packet ends the stream. Frames that fail to decode are
+// concealed, so Decode only fails when the stream is not SILK or holds no
+// packets.
+func Decode(data []byte) ([]byte, error) {
+	if !IsSilk(data) {
+		return nil, errors.New("missing SILK v3 header")
+	}
+	if data[0] == 0x02 {
+		data = data[1:]
+	}
+	data = data[len(header):]
+
+	d := newDecoder()
+	var samples []int16
+	packets := 0
+	for len(data) >= 2 {
+		n := int16(binary.LittleEndian.Uint16(data))
+		if n < 0 || int(n) > len(data)-2 {
+			break
+		}
+		payload := data[2 : 2+n]
+		data = data[2+n:]
+		packets++
+
+		for {
+			samples, _ = d.decode(samples, payload, n == 0)
+			if !d.moreFrames {
+				break
+			}
+		}
+	}
+	if packets == 0 {
+		return nil, errors.New("no packets")
+	}
+
+	pcm := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(s))
+	}
+	return pcm, nil
+}
@@ -0,0 +1,104 @@
+package silk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsSilk(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"bare header", []byte("#!SILK_V3\x00\x00"), true},
+		{"wechat prefix", []byte("\x02#!SILK_V3\x00\x00"), true},
+		{"amr", []byte("#!AMR\n"), false},
+		{"short", []byte("#!SILK"), false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSilk(tt.data); got != tt.want {
+				t.Errorf("IsSilk() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The fixtures were encoded with the SILK SDK at internal rates of 8, 12, 16
+// and 24 kHz, the last with the WeChat prefix. The digests are of what the
+// SDK decoder produces for them at 24 kHz.
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		file    string
+		samples int
+		sha256  string
+	}{
+		{"sdk8.silk", 144000, "d3f36e6476a7c9fdac7226a59b1da131db590c518aa16e558f7bbbdf3cd899a5"},
+		{"sdk12.silk", 96000, "b9baef23257054f230e135d7bfe4fef4dd018733067b5b80bbfe791ee32426e7"},
+		{"sdk16_60ms.silk", 116640, "91046422f8f51121fc61bb5250c2bbc271307a643e957c31f267ff1b7968c402"},
+		{"wechat24.silk", 144000, "704858837658e00190a73bb2fdcea8eb80a1a8257d1e2ae8f16e441c32df1b30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pcm, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if len(pcm) != 2*tt.samples {
+				t.Fatalf("Decode() = %d samples, want %d", len(pcm)/2, tt.samples)
+			}
+			if sum := sha256.Sum256(pcm); hex.EncodeToString(sum[:]) != tt.sha256 {
+				t.Errorf("Decode() sha256 = %x, want %s", sum, tt.sha256)
+			}
+		})
+	}
+}
+
+// Corrupt and lost packets are concealed rather than failing the stream.
+func TestDecodeConceals(t *testing.T) {
+	data, err := os.ReadFile("testdata/wechat24.silk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append([]byte(nil), data...)
+	for i := 100; i < len(data); i += 97 {
+		data[i] ^= 0x5A
+	}
+	// A zero length packet marks a lost one
+	data = append(data[:10:10], append([]byte{0, 0}, data[10:]...)...)
+
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(got) == 0 {
+		t.Error("Decode() returned no audio")
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not silk", []byte("RIFF....WAVE")},
+		{"no packets", []byte("\x02#!SILK_V3")},
+		{"truncated packet", []byte("#!SILK_V3\x10\x00\x01\x02")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); err == nil {
+				t.Error("Decode() error = nil, want an error")
+			}
+		})
+	}
+}